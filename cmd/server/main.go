@@ -3,6 +3,7 @@ package main
 import (
 	"github.com/lazerion/outbox-relayer/internal/api"
 	"github.com/lazerion/outbox-relayer/internal/cache"
+	"github.com/lazerion/outbox-relayer/internal/callback"
 	"github.com/lazerion/outbox-relayer/internal/gateway"
 	"github.com/lazerion/outbox-relayer/internal/http"
 	"github.com/lazerion/outbox-relayer/internal/infra"
@@ -19,6 +20,7 @@ func main() {
 		config.Module,
 		repository.Module,
 		gateway.Module,
+		callback.Module,
 		service.Module,
 		schedule.Module,
 		api.Module,
@@ -15,9 +15,588 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/api/v1/clients/{clientId}/callback": {
+            "get": {
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "callbacks"
+                ],
+                "summary": "Get a client callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Producer identifier, as sent in X-Client-ID",
+                        "name": "clientId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ClientCallback"
+                        }
+                    },
+                    "404": {
+                        "description": "No callback registered for the client",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "description": "Pushes every status change (` + "`" + `sent` + "`" + `, ` + "`" + `failed` + "`" + `, ` + "`" + `delivered` + "`" + `, ` + "`" + `undelivered` + "`" + `, ` + "`" + `expired` + "`" + `, ` + "`" + `cancelled` + "`" + `)\nof messages enqueued with this ` + "`" + `X-Client-ID` + "`" + ` and without their own ` + "`" + `callback_url` + "`" + ` to ` + "`" + `url` + "`" + `.\nReplaces a URL registered before; status changes already queued keep the URL they were queued with.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "callbacks"
+                ],
+                "summary": "Register a client callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Producer identifier, as sent in X-Client-ID",
+                        "name": "clientId",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Callback URL",
+                        "name": "callback",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.ClientCallbackRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.ClientCallback"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation failure",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Messages with their own ` + "`" + `callback_url` + "`" + ` keep being pushed; status changes already queued are still sent.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "callbacks"
+                ],
+                "summary": "Delete a client callback",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Producer identifier, as sent in X-Client-ID",
+                        "name": "clientId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "404": {
+                        "description": "No callback registered for the client",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/delivery-receipts": {
+            "post": {
+                "description": "Callback for the SMS gateway. Moves the ` + "`" + `sent` + "`" + ` message the gateway accepted as ` + "`" + `messageId` + "`" + ` to\n` + "`" + `delivered` + "`" + ` or ` + "`" + `undelivered` + "`" + ` and stores ` + "`" + `deliveredAt` + "`" + ` as its ` + "`" + `delivered_time` + "`" + `. A receipt carrying the\n` + "`" + `Idempotency-Key` + "`" + ` of the send as ` + "`" + `reference` + "`" + ` also resolves an ` + "`" + `unknown` + "`" + ` message. The body must be\nsigned with the shared secret (` + "`" + `deliveryReceipts.secret` + "`" + `): ` + "`" + `X-Signature` + "`" + ` carries the hex encoded\nHMAC-SHA256 of the raw body. Repeating a receipt the message already reflects succeeds.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "delivery"
+                ],
+                "summary": "Receive a delivery receipt",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Hex encoded HMAC-SHA256 of the body",
+                        "name": "X-Signature",
+                        "in": "header",
+                        "required": true
+                    },
+                    {
+                        "description": "Delivery receipt",
+                        "name": "receipt",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.DeliveryReceiptRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation failure",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Missing or invalid signature",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "No message with this external ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Message is neither sent nor unknown",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "413": {
+                        "description": "Receipt too large",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages": {
+            "get": {
+                "description": "Returns messages matching all given filters, ordered by ` + "`" + `id` + "`" + ` ascending.\nSupports keyset pagination: pass the returned ` + "`" + `next_after_id` + "`" + ` as ` + "`" + `after_id` + "`" + ` to fetch the next page.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Search messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated statuses (pending, sent, failed, cancelled, expired, unknown, delivered, undelivered)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated failure reasons (max_attempts, unrecoverable_error, unexpected_response, unknown_outcome)",
+                        "name": "failure_reason",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient phone number",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Smallest message ID (inclusive)",
+                        "name": "min_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Largest message ID (inclusive)",
+                        "name": "max_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after this timestamp (RFC3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before this timestamp (RFC3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sent at or after this timestamp (RFC3339)",
+                        "name": "sent_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sent before this timestamp (RFC3339)",
+                        "name": "sent_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Minimum attempt count",
+                        "name": "min_attempts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Maximum attempt count",
+                        "name": "max_attempts",
+                        "in": "query"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Only messages with a future send_at (true) or only due messages (false)",
+                        "name": "scheduled",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Return messages with an ID greater than this cursor",
+                        "name": "after_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Number of messages to return (1–50), defaults to 42",
+                        "name": "limit",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.MessagesPage"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "description": "Validates and stores a new ` + "`" + `pending` + "`" + ` message. Phone numbers may only contain digits and ` + "`" + `+` + "`" + `\n(max 20 characters) and content must be 1–160 characters. An optional ` + "`" + `send_at` + "`" + ` defers delivery\nuntil that time, and an optional ` + "`" + `expires_at` + "`" + ` moves the message to ` + "`" + `expired` + "`" + ` instead of sending it late.\n` + "`" + `priority` + "`" + ` (low, normal, high) decides how soon the message is relayed relative to the backlog,\nand ` + "`" + `max_attempts` + "`" + ` overrides how often a failing send is retried. ` + "`" + `callback_url` + "`" + ` receives a signed\nPOST for every status change of the message, taking precedence over the client's registered callback.\nRequests carrying an ` + "`" + `Idempotency-Key` + "`" + ` already used by the same ` + "`" + `X-Client-ID` + "`" + ` return the originally\ncreated message with status 200 and ` + "`" + `Idempotent-Replayed: true` + "`" + ` instead of inserting a new one.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Enqueue a message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Key deduplicating retried requests",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Producer identifier scoping the idempotency key",
+                        "name": "X-Client-ID",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Message to enqueue",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.EnqueueMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Replay of an earlier request with the same idempotency key",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "201": {
+                        "description": "Message created",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation failure",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Idempotency key reused with a different payload",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/by-external/{externalId}": {
+            "get": {
+                "description": "Returns the message the gateway acknowledged with the given ` + "`" + `messageId` + "`" + `.\nLookups are served from the Redis cache when possible and fall back to the database.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Get a message by gateway ID",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Gateway assigned message ID",
+                        "name": "externalId",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/cancel": {
+            "post": {
+                "description": "Moves all ` + "`" + `pending` + "`" + ` messages matching the filter to ` + "`" + `cancelled` + "`" + `. Messages being relayed at that\nmoment are skipped. An empty filter is rejected.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Bulk cancel messages",
+                "parameters": [
+                    {
+                        "description": "Messages to cancel",
+                        "name": "cancel",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.CancelMessagesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/handler.CancelMessagesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation failure",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/import": {
+            "post": {
+                "description": "Streams an upload into the message table using Postgres ` + "`" + `COPY` + "`" + `. The format is selected by ` + "`" + `Content-Type` + "`" + `:\n` + "`" + `application/x-ndjson` + "`" + ` expects one JSON object per line with ` + "`" + `phone_number` + "`" + ` and ` + "`" + `content` + "`" + `,\n` + "`" + `text/csv` + "`" + ` expects a header row naming the ` + "`" + `phone_number` + "`" + ` and ` + "`" + `content` + "`" + ` columns.\nLines may also set ` + "`" + `send_at` + "`" + `, ` + "`" + `expires_at` + "`" + ` (RFC3339), ` + "`" + `priority` + "`" + `, ` + "`" + `max_attempts` + "`" + ` and ` + "`" + `callback_url` + "`" + `\nas on enqueue; idempotency keys are not supported. Imported messages belong to ` + "`" + `X-Client-ID` + "`" + `.\nLines failing validation are reported as rejected; all accepted lines are stored atomically.",
+                "consumes": [
+                    "text/csv",
+                    "application/x-ndjson"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Bulk import messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Producer the imported messages belong to",
+                        "name": "X-Client-ID",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ImportReport"
+                        }
+                    },
+                    "400": {
+                        "description": "Malformed upload",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "415": {
+                        "description": "Unsupported content type",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/replay": {
+            "post": {
+                "description": "Moves the ` + "`" + `failed` + "`" + ` messages selected by ` + "`" + `ids` + "`" + ` and/or ` + "`" + `filter` + "`" + ` back to ` + "`" + `pending` + "`" + ` with their attempt count\nreset, optionally replacing their phone number or content. Each replayed message is recorded with\n` + "`" + `replayed_by` + "`" + ` (defaulting to ` + "`" + `X-Client-ID` + "`" + `) and is listed by ` + "`" + `GET /api/v1/messages/{id}/replays` + "`" + `.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Replay failed messages",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Caller recorded as replayed_by when the body omits it",
+                        "name": "X-Client-ID",
+                        "in": "header"
+                    },
+                    {
+                        "description": "Messages to replay",
+                        "name": "replay",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.ReplayMessagesRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.ReplayResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation failure",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
         "/api/v1/messages/sent": {
             "get": {
-                "description": "Returns a list of messages with status ` + "`" + `sent` + "`" + `, ordered by ` + "`" + `sent_time` + "`" + ` ascending.\nSupports cursor-based pagination via the ` + "`" + `after` + "`" + ` cursor.",
+                "description": "Returns a list of messages with status ` + "`" + `sent` + "`" + `, ordered by ` + "`" + `sent_time` + "`" + ` and ` + "`" + `id` + "`" + ` ascending.\nSupports cursor-based pagination: pass ` + "`" + `next_cursor` + "`" + ` or ` + "`" + `prev_cursor` + "`" + ` from a previous response as ` + "`" + `cursor` + "`" + `\nto page forward or backward. Cursors are opaque. The legacy ` + "`" + `after` + "`" + ` parameter still accepts a timestamp,\nand also a ` + "`" + `next_cursor` + "`" + ` passed back by clients written against the former timestamp cursor.",
                 "consumes": [
                     "application/json"
                 ],
@@ -31,7 +610,13 @@ const docTemplate = `{
                 "parameters": [
                     {
                         "type": "string",
-                        "description": "Return messages sent after this timestamp (RFC3339)",
+                        "description": "Opaque cursor returned by a previous page",
+                        "name": "cursor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Return messages sent after this timestamp (RFC3339) or cursor, deprecated in favor of cursor",
                         "name": "after",
                         "in": "query"
                     },
@@ -42,18 +627,354 @@ const docTemplate = `{
                         "in": "query"
                     }
                 ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.SentMessagesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/stats": {
+            "get": {
+                "description": "Returns the number of messages per status among those matching the filters, e.g. how many messages\ncreated in a time range ended up ` + "`" + `expired` + "`" + `.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Message statistics",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Comma separated statuses (pending, sent, failed, cancelled, expired, unknown, delivered, undelivered)",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Comma separated failure reasons (max_attempts, unrecoverable_error, unexpected_response, unknown_outcome)",
+                        "name": "failure_reason",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Recipient phone number",
+                        "name": "phone_number",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created at or after this timestamp (RFC3339)",
+                        "name": "created_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Created before this timestamp (RFC3339)",
+                        "name": "created_to",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sent at or after this timestamp (RFC3339)",
+                        "name": "sent_from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Sent before this timestamp (RFC3339)",
+                        "name": "sent_to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/service.MessageStats"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid filter",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{id}": {
+            "get": {
+                "description": "Returns the message with the given ID in any status, including its attempt count.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Get a message",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid message ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "description": "Replaces the phone number and/or content of a ` + "`" + `pending` + "`" + ` message. Fails with 409 when the message\nis no longer pending or is being relayed at that moment.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Edit a message",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Fields to change",
+                        "name": "message",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/handler.EditMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid request format or validation failure",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Message is no longer pending",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{id}/attempts": {
+            "get": {
+                "description": "Returns every attempt the relayer made to send the message with the gateway's HTTP status, error,\nrecoverability, external ID and duration, oldest first.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "List message send attempts",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/model.MessageAttempt"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid message ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{id}/cancel": {
+            "post": {
+                "description": "Moves a ` + "`" + `pending` + "`" + ` message to ` + "`" + `cancelled` + "`" + ` so it is never relayed. Fails with 409 when the message\nis no longer pending or is being relayed at that moment.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "Cancel a message",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/model.Message"
+                        }
+                    },
+                    "400": {
+                        "description": "Invalid message ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Message already left pending or is being relayed",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal server error",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/v1/messages/{id}/replays": {
+            "get": {
+                "description": "Returns who moved the message back to ` + "`" + `pending` + "`" + ` and when, together with the values it had before.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "messages"
+                ],
+                "summary": "List message replays",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
                             "type": "array",
                             "items": {
-                                "$ref": "#/definitions/service.SentMessagesResponse"
+                                "$ref": "#/definitions/model.MessageReplay"
                             }
                         }
                     },
                     "400": {
-                        "description": "Invalid request format",
+                        "description": "Invalid message ID",
+                        "schema": {
+                            "$ref": "#/definitions/handler.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Message not found",
                         "schema": {
                             "$ref": "#/definitions/handler.ErrorResponse"
                         }
@@ -89,6 +1010,108 @@ const docTemplate = `{
         }
     },
     "definitions": {
+        "handler.CancelMessagesRequest": {
+            "type": "object",
+            "properties": {
+                "filter": {
+                    "$ref": "#/definitions/handler.MessageFilterRequest"
+                }
+            }
+        },
+        "handler.CancelMessagesResponse": {
+            "type": "object",
+            "properties": {
+                "cancelled": {
+                    "type": "integer"
+                }
+            }
+        },
+        "handler.ClientCallbackRequest": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "description": "URL receives a signed POST for every status change of the client's messages",
+                    "type": "string"
+                }
+            }
+        },
+        "handler.DeliveryReceiptRequest": {
+            "type": "object",
+            "properties": {
+                "deliveredAt": {
+                    "description": "DeliveredAt is when the outcome was determined (RFC3339); defaults to the time of receipt",
+                    "type": "string"
+                },
+                "error": {
+                    "description": "Error is the reason an undelivered message did not reach the handset",
+                    "type": "string"
+                },
+                "messageId": {
+                    "description": "MessageID is the ID the gateway accepted the message with",
+                    "type": "string"
+                },
+                "reference": {
+                    "description": "Reference is the Idempotency-Key the message was sent with. It lets a receipt resolve a message whose send\ntimed out, which has no messageId recorded yet.",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "delivered",
+                        "undelivered"
+                    ]
+                }
+            }
+        },
+        "handler.EditMessageRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "phone_number": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.EnqueueMessageRequest": {
+            "type": "object",
+            "properties": {
+                "callback_url": {
+                    "description": "CallbackURL receives the message's status changes instead of the URL registered for X-Client-ID",
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "description": "ExpiresAt drops the message instead of sending it after the given time (RFC3339)",
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "description": "MaxAttempts overrides the relayer's maximum number of send attempts for this message",
+                    "type": "integer",
+                    "maximum": 100,
+                    "minimum": 1
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "Priority is one of low, normal (default) or high",
+                    "type": "string",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ]
+                },
+                "send_at": {
+                    "description": "SendAt defers delivery until the given time (RFC3339); omit to send as soon as possible",
+                    "type": "string"
+                }
+            }
+        },
         "handler.ErrorResponse": {
             "type": "object",
             "properties": {
@@ -97,6 +1120,70 @@ const docTemplate = `{
                 }
             }
         },
+        "handler.MessageFilterRequest": {
+            "type": "object",
+            "properties": {
+                "created_from": {
+                    "type": "string"
+                },
+                "created_to": {
+                    "type": "string"
+                },
+                "failure_reason": {
+                    "description": "FailureReasons selects failed messages by why they were given up on",
+                    "type": "array",
+                    "items": {
+                        "enum": [
+                            "max_attempts",
+                            "unrecoverable_error",
+                            "unexpected_response",
+                            "unknown_outcome"
+                        ],
+                        "$ref": "#/definitions/model.FailureReason"
+                    }
+                },
+                "max_attempts": {
+                    "type": "integer"
+                },
+                "max_id": {
+                    "type": "integer"
+                },
+                "min_attempts": {
+                    "type": "integer"
+                },
+                "min_id": {
+                    "type": "integer"
+                },
+                "phone_number": {
+                    "type": "string"
+                }
+            }
+        },
+        "handler.ReplayMessagesRequest": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "filter": {
+                    "$ref": "#/definitions/handler.MessageFilterRequest"
+                },
+                "ids": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "phone_number": {
+                    "description": "PhoneNumber and Content replace the stored values of every replayed message when set",
+                    "type": "string"
+                },
+                "replayed_by": {
+                    "description": "ReplayedBy defaults to the X-Client-ID header",
+                    "type": "string"
+                }
+            }
+        },
         "handler.StatusResponse": {
             "type": "object",
             "properties": {
@@ -105,24 +1192,120 @@ const docTemplate = `{
                 }
             }
         },
+        "model.ClientCallback": {
+            "type": "object",
+            "properties": {
+                "client_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "model.FailureReason": {
+            "type": "string",
+            "enum": [
+                "max_attempts",
+                "unrecoverable_error",
+                "unexpected_response",
+                "unknown_outcome"
+            ],
+            "x-enum-varnames": [
+                "FailureMaxAttempts",
+                "FailureUnrecoverable",
+                "FailureUnexpectedResponse",
+                "FailureUnknownOutcome"
+            ]
+        },
         "model.Message": {
             "type": "object",
             "properties": {
                 "attempt_count": {
                     "type": "integer"
                 },
+                "callback_url": {
+                    "description": "CallbackURL receives the message's status changes instead of the URL registered for its client",
+                    "type": "string"
+                },
+                "claimed_by": {
+                    "description": "ClaimedBy and LeaseUntil identify the relayer currently sending the message",
+                    "type": "string"
+                },
+                "client_id": {
+                    "type": "string"
+                },
                 "content": {
                     "type": "string"
                 },
+                "created_at": {
+                    "type": "string"
+                },
+                "delivered_time": {
+                    "description": "DeliveredTime is when the gateway's delivery receipt reported the handset outcome",
+                    "type": "string"
+                },
+                "delivery_generation": {
+                    "description": "DeliveryGeneration is bumped by every replay or edit and is part of the gateway idempotency key",
+                    "type": "integer"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
                 "external_id": {
                     "type": "string"
                 },
+                "failure_reason": {
+                    "description": "FailureReason tells why a failed message was given up on",
+                    "enum": [
+                        "max_attempts",
+                        "unrecoverable_error",
+                        "unexpected_response",
+                        "unknown_outcome"
+                    ],
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/model.FailureReason"
+                        }
+                    ]
+                },
                 "id": {
                     "type": "integer"
                 },
+                "idempotency_key": {
+                    "type": "string"
+                },
+                "last_error": {
+                    "description": "LastError is the error of the most recent failed attempt",
+                    "type": "string"
+                },
+                "lease_until": {
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "description": "MaxAttempts overrides the relayer's configured maximum when set",
+                    "type": "integer"
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
                 "phone_number": {
                     "type": "string"
                 },
+                "priority": {
+                    "type": "string",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ]
+                },
+                "send_at": {
+                    "type": "string"
+                },
                 "sent_time": {
                     "type": "string"
                 },
@@ -131,19 +1314,165 @@ const docTemplate = `{
                 }
             }
         },
+        "model.MessageAttempt": {
+            "type": "object",
+            "properties": {
+                "attempted_at": {
+                    "type": "string"
+                },
+                "duration_ms": {
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "external_id": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "message_id": {
+                    "type": "integer"
+                },
+                "recoverable": {
+                    "type": "boolean"
+                },
+                "status_code": {
+                    "description": "StatusCode is the HTTP status returned by the gateway, 0 when no response was received",
+                    "type": "integer"
+                }
+            }
+        },
+        "model.MessageReplay": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer"
+                },
+                "message_id": {
+                    "type": "integer"
+                },
+                "phone_number": {
+                    "type": "string"
+                },
+                "previous_attempt_count": {
+                    "type": "integer"
+                },
+                "previous_content": {
+                    "type": "string"
+                },
+                "previous_phone_number": {
+                    "type": "string"
+                },
+                "replayed_at": {
+                    "type": "string"
+                },
+                "replayed_by": {
+                    "type": "string"
+                }
+            }
+        },
         "model.MessageStatus": {
             "type": "string",
             "enum": [
-                "PENDING",
-                "SENT",
-                "FAILED"
+                "pending",
+                "sent",
+                "failed",
+                "cancelled",
+                "expired",
+                "unknown",
+                "delivered",
+                "undelivered"
             ],
             "x-enum-varnames": [
                 "StatusPending",
                 "StatusSent",
-                "StatusFailed"
+                "StatusFailed",
+                "StatusCancelled",
+                "StatusExpired",
+                "StatusUnknown",
+                "StatusDelivered",
+                "StatusUndelivered"
             ]
         },
+        "service.ImportLineResult": {
+            "type": "object",
+            "properties": {
+                "error": {
+                    "type": "string"
+                },
+                "line": {
+                    "type": "integer"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "service.ImportReport": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "type": "integer"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/service.ImportLineResult"
+                    }
+                },
+                "rejected": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.MessageStats": {
+            "type": "object",
+            "properties": {
+                "by_status": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "integer",
+                        "format": "int64"
+                    }
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.MessagesPage": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Message"
+                    }
+                },
+                "next_after_id": {
+                    "type": "integer"
+                }
+            }
+        },
+        "service.ReplayResult": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/model.Message"
+                    }
+                },
+                "replayed": {
+                    "type": "integer"
+                }
+            }
+        },
         "service.SentMessagesResponse": {
             "type": "object",
             "properties": {
@@ -155,6 +1484,9 @@ const docTemplate = `{
                 },
                 "next_cursor": {
                     "type": "string"
+                },
+                "prev_cursor": {
+                    "type": "string"
                 }
             }
         }
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+)
+
+type CallbackHandler struct {
+	service service.CallbackServiceInterface
+}
+
+func NewCallbackHandler(cs service.CallbackServiceInterface) *CallbackHandler {
+	return &CallbackHandler{service: cs}
+}
+
+// ClientCallbackRequest registers the callback URL of a producer
+type ClientCallbackRequest struct {
+	// URL receives a signed POST for every status change of the client's messages
+	URL string `json:"url"`
+}
+
+// SetClientCallback registers the callback URL of a producer.
+//
+// @Summary      Register a client callback
+// @Description  Pushes every status change (`sent`, `failed`, `delivered`, `undelivered`, `expired`, `cancelled`)
+// @Description  of messages enqueued with this `X-Client-ID` and without their own `callback_url` to `url`.
+// @Description  Replaces a URL registered before; status changes already queued keep the URL they were queued with.
+// @Tags         callbacks
+// @Accept       json
+// @Produce      json
+//
+// @Param        clientId  path      string                 true  "Producer identifier, as sent in X-Client-ID"
+// @Param        callback  body      ClientCallbackRequest  true  "Callback URL"
+//
+// @Success      200  {object}  model.ClientCallback
+// @Failure      400  {object}  ErrorResponse  "Invalid request format or validation failure"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/clients/{clientId}/callback [put]
+func (h *CallbackHandler) SetClientCallback(w http.ResponseWriter, r *http.Request) {
+	var req ClientCallbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	cb, err := h.service.SetClientCallback(r.Context(), model.ClientCallback{
+		ClientID: mux.Vars(r)["clientId"],
+		URL:      req.URL,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, cb)
+}
+
+// GetClientCallback returns the callback URL registered for a producer.
+//
+// @Summary      Get a client callback
+// @Tags         callbacks
+// @Produce      json
+//
+// @Param        clientId  path      string  true  "Producer identifier, as sent in X-Client-ID"
+//
+// @Success      200  {object}  model.ClientCallback
+// @Failure      404  {object}  ErrorResponse  "No callback registered for the client"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/clients/{clientId}/callback [get]
+func (h *CallbackHandler) GetClientCallback(w http.ResponseWriter, r *http.Request) {
+	cb, err := h.service.GetClientCallback(r.Context(), mux.Vars(r)["clientId"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, cb)
+}
+
+// DeleteClientCallback stops pushing status changes to a producer.
+//
+// @Summary      Delete a client callback
+// @Description  Messages with their own `callback_url` keep being pushed; status changes already queued are still sent.
+// @Tags         callbacks
+// @Produce      json
+//
+// @Param        clientId  path      string  true  "Producer identifier, as sent in X-Client-ID"
+//
+// @Success      204
+// @Failure      404  {object}  ErrorResponse  "No callback registered for the client"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/clients/{clientId}/callback [delete]
+func (h *CallbackHandler) DeleteClientCallback(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteClientCallback(r.Context(), mux.Vars(r)["clientId"]); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
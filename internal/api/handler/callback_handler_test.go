@@ -0,0 +1,85 @@
+package handler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/lazerion/outbox-relayer/internal/api/handler"
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+type MockCallbackService struct {
+	err error
+	got model.ClientCallback
+}
+
+func (m *MockCallbackService) SetClientCallback(ctx context.Context, cb model.ClientCallback) (*model.ClientCallback, error) {
+	m.got = cb
+	return &cb, m.err
+}
+
+func (m *MockCallbackService) GetClientCallback(ctx context.Context, clientID string) (*model.ClientCallback, error) {
+	m.got = model.ClientCallback{ClientID: clientID}
+	return &model.ClientCallback{ClientID: clientID, URL: "https://billing.example.com/sms"}, m.err
+}
+
+func (m *MockCallbackService) DeleteClientCallback(ctx context.Context, clientID string) error {
+	m.got = model.ClientCallback{ClientID: clientID}
+	return m.err
+}
+
+func TestClientCallback(t *testing.T) {
+	notFound := fmt.Errorf("get callback of client %q: %w", "billing", model.ErrCallbackNotFound)
+
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"Set", http.MethodPut, `{"url":"https://billing.example.com/sms"}`, nil, http.StatusOK, `"url":"https://billing.example.com/sms"`},
+		{"Set invalid body", http.MethodPut, `{`, nil, http.StatusBadRequest, "invalid request body"},
+		{"Set invalid URL", http.MethodPut, `{"url":"sms"}`, &model.ValidationError{Field: "url", Reason: "must be an absolute http or https URL"}, http.StatusBadRequest, "invalid url"},
+		{"Get", http.MethodGet, "", nil, http.StatusOK, `"client_id":"billing"`},
+		{"Get not registered", http.MethodGet, "", notFound, http.StatusNotFound, "client callback not found"},
+		{"Delete", http.MethodDelete, "", nil, http.StatusNoContent, ""},
+		{"Delete not registered", http.MethodDelete, "", notFound, http.StatusNotFound, "client callback not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &MockCallbackService{err: tt.mockErr}
+			h := handler.NewCallbackHandler(mockSvc)
+
+			req := httptest.NewRequest(tt.method, "/clients/billing/callback", strings.NewReader(tt.body))
+			req = mux.SetURLVars(req, map[string]string{"clientId": "billing"})
+			w := httptest.NewRecorder()
+
+			switch tt.method {
+			case http.MethodPut:
+				h.SetClientCallback(w, req)
+			case http.MethodGet:
+				h.GetClientCallback(w, req)
+			case http.MethodDelete:
+				h.DeleteClientCallback(w, req)
+			}
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+			if w.Code < http.StatusBadRequest && mockSvc.got.ClientID != "billing" {
+				t.Errorf("expected client ID billing, got %q", mockSvc.got.ClientID)
+			}
+		})
+	}
+}
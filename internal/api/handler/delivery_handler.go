@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/gateway"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+	"github.com/lazerion/outbox-relayer/internal/signature"
+)
+
+// maxReceiptSize bounds the body read before its signature is checked
+const maxReceiptSize = 64 << 10
+
+type DeliveryHandler struct {
+	service service.DeliveryServiceInterface
+	secret  string
+}
+
+// NewDeliveryHandler creates a handler accepting receipts signed with secret; an empty secret rejects every receipt
+func NewDeliveryHandler(ds service.DeliveryServiceInterface, secret string) *DeliveryHandler {
+	return &DeliveryHandler{service: ds, secret: secret}
+}
+
+// DeliveryReceiptRequest is the delivery receipt (DLR) posted by the gateway
+type DeliveryReceiptRequest struct {
+	// MessageID is the ID the gateway accepted the message with
+	MessageID string `json:"messageId"`
+	Status    string `json:"status" enums:"delivered,undelivered"`
+	// DeliveredAt is when the outcome was determined (RFC3339); defaults to the time of receipt
+	DeliveredAt time.Time `json:"deliveredAt"`
+	// Error is the reason an undelivered message did not reach the handset
+	Error string `json:"error"`
+	// Reference is the Idempotency-Key the message was sent with. It lets a receipt resolve a message whose send
+	// timed out, which has no messageId recorded yet.
+	Reference string `json:"reference"`
+}
+
+// ReceiveDeliveryReceipt records the handset delivery outcome reported by the gateway.
+//
+// @Summary      Receive a delivery receipt
+// @Description  Callback for the SMS gateway. Moves the `sent` message the gateway accepted as `messageId` to
+// @Description  `delivered` or `undelivered` and stores `deliveredAt` as its `delivered_time`. A receipt carrying the
+// @Description  `Idempotency-Key` of the send as `reference` also resolves an `unknown` message. The body must be
+// @Description  signed with the shared secret (`deliveryReceipts.secret`): `X-Signature` carries the hex encoded
+// @Description  HMAC-SHA256 of the raw body. Repeating a receipt the message already reflects succeeds.
+// @Tags         delivery
+// @Accept       json
+// @Produce      json
+//
+// @Param        X-Signature  header    string                  true  "Hex encoded HMAC-SHA256 of the body"
+// @Param        receipt      body      DeliveryReceiptRequest  true  "Delivery receipt"
+//
+// @Success      200  {object}  model.Message
+// @Failure      400  {object}  ErrorResponse  "Invalid request format or validation failure"
+// @Failure      401  {object}  ErrorResponse  "Missing or invalid signature"
+// @Failure      404  {object}  ErrorResponse  "No message with this external ID"
+// @Failure      409  {object}  ErrorResponse  "Message is neither sent nor unknown"
+// @Failure      413  {object}  ErrorResponse  "Receipt too large"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/delivery-receipts [post]
+func (h *DeliveryHandler) ReceiveDeliveryReceipt(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxReceiptSize))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		WriteError(w, http.StatusRequestEntityTooLarge, "delivery receipt too large")
+		return
+	}
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if !signature.Verify(h.secret, body, r.Header.Get(signature.Header)) {
+		WriteError(w, http.StatusUnauthorized, "invalid signature")
+		return
+	}
+
+	var req DeliveryReceiptRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	receipt := model.DeliveryReceipt{
+		ExternalID:  req.MessageID,
+		Status:      model.MessageStatus(req.Status),
+		DeliveredAt: req.DeliveredAt,
+		Error:       req.Error,
+	}
+	if req.Reference != "" {
+		id, generation, ok := gateway.ParseDeliveryKey(req.Reference)
+		if !ok {
+			WriteError(w, http.StatusBadRequest, "invalid 'reference'")
+			return
+		}
+		receipt.MessageID, receipt.DeliveryGeneration = id, generation
+	}
+
+	msg, err := h.service.RecordReceipt(r.Context(), receipt)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, msg)
+}
@@ -0,0 +1,127 @@
+package handler_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lazerion/outbox-relayer/internal/api/handler"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/signature"
+)
+
+type MockDeliveryService struct {
+	msg *model.Message
+	err error
+	got model.DeliveryReceipt
+}
+
+func (m *MockDeliveryService) RecordReceipt(ctx context.Context, r model.DeliveryReceipt) (*model.Message, error) {
+	m.got = r
+	return m.msg, m.err
+}
+
+func TestReceiveDeliveryReceipt(t *testing.T) {
+	const secret = "s3cret"
+	body := `{"messageId":"ext-1","status":"delivered","deliveredAt":"2026-01-02T03:04:05Z"}`
+
+	tests := []struct {
+		name           string
+		secret         string
+		body           string
+		sig            string
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"Valid receipt", secret, body, signature.Sign(secret, []byte(body)), nil, http.StatusOK, `"status":"delivered"`},
+		{"Missing signature", secret, body, "", nil, http.StatusUnauthorized, "invalid signature"},
+		{"Wrong secret", secret, body, signature.Sign("other", []byte(body)), nil, http.StatusUnauthorized, "invalid signature"},
+		{"Not configured", "", body, signature.Sign("", []byte(body)), nil, http.StatusUnauthorized, "invalid signature"},
+		{"Malformed JSON", secret, `{`, signature.Sign(secret, []byte(`{`)), nil, http.StatusBadRequest, "invalid request body"},
+		{"Invalid status", secret, body, signature.Sign(secret, []byte(body)), &model.ValidationError{Field: "status", Reason: "must be delivered or undelivered"}, http.StatusBadRequest, "status"},
+		{"Unknown message", secret, body, signature.Sign(secret, []byte(body)), fmt.Errorf("record delivery receipt: %w", model.ErrNotFound), http.StatusNotFound, "message not found"},
+		{"Not sent", secret, body, signature.Sign(secret, []byte(body)), &model.TransitionError{MessageID: 3, From: model.StatusPending, To: model.StatusDelivered}, http.StatusConflict, "cannot move from pending to delivered"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &MockDeliveryService{msg: &model.Message{ID: 3, ExternalID: "ext-1", Status: model.StatusDelivered}, err: tt.mockErr}
+			h := handler.NewDeliveryHandler(mockSvc, tt.secret)
+
+			req := httptest.NewRequest(http.MethodPost, "/delivery-receipts", strings.NewReader(tt.body))
+			if tt.sig != "" {
+				req.Header.Set(signature.Header, tt.sig)
+			}
+			w := httptest.NewRecorder()
+
+			h.ReceiveDeliveryReceipt(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReceiveDeliveryReceipt_MapsBody(t *testing.T) {
+	body := `{"messageId":"ext-9","status":"undelivered","error":"handset unreachable"}`
+	mockSvc := &MockDeliveryService{msg: &model.Message{ID: 9}}
+	h := handler.NewDeliveryHandler(mockSvc, "k")
+
+	req := httptest.NewRequest(http.MethodPost, "/delivery-receipts", strings.NewReader(body))
+	req.Header.Set(signature.Header, signature.Sign("k", []byte(body)))
+	w := httptest.NewRecorder()
+
+	h.ReceiveDeliveryReceipt(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	want := model.DeliveryReceipt{ExternalID: "ext-9", Status: model.StatusUndelivered, Error: "handset unreachable"}
+	if mockSvc.got != want {
+		t.Errorf("expected receipt %+v, got %+v", want, mockSvc.got)
+	}
+}
+
+func TestReceiveDeliveryReceipt_Reference(t *testing.T) {
+	tests := []struct {
+		name           string
+		reference      string
+		wantStatusCode int
+		want           model.DeliveryReceipt
+	}{
+		{"first generation", "message-9", http.StatusOK,
+			model.DeliveryReceipt{ExternalID: "ext-9", Status: model.StatusDelivered, MessageID: 9}},
+		{"replayed message", "message-9-2", http.StatusOK,
+			model.DeliveryReceipt{ExternalID: "ext-9", Status: model.StatusDelivered, MessageID: 9, DeliveryGeneration: 2}},
+		{"not a delivery key", "order-9", http.StatusBadRequest, model.DeliveryReceipt{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := fmt.Sprintf(`{"messageId":"ext-9","status":"delivered","reference":%q}`, tt.reference)
+			mockSvc := &MockDeliveryService{msg: &model.Message{ID: 9}}
+			h := handler.NewDeliveryHandler(mockSvc, "k")
+
+			req := httptest.NewRequest(http.MethodPost, "/delivery-receipts", strings.NewReader(body))
+			req.Header.Set(signature.Header, signature.Sign("k", []byte(body)))
+			w := httptest.NewRecorder()
+
+			h.ReceiveDeliveryReceipt(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Fatalf("expected status %d, got %d: %s", tt.wantStatusCode, w.Code, w.Body.String())
+			}
+			if mockSvc.got != tt.want {
+				t.Errorf("expected receipt %+v, got %+v", tt.want, mockSvc.got)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+)
+
+const (
+	// IdempotencyKeyHeader lets producers retry an enqueue without creating duplicates
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader tells the caller whether the response was replayed from an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// ClientIDHeader identifies the producer and scopes its idempotency keys
+	ClientIDHeader = "X-Client-ID"
+)
+
+type MessageHandler struct {
+	service service.MessageServiceInterface
+}
+
+func NewMessageHandler(ms service.MessageServiceInterface) *MessageHandler {
+	return &MessageHandler{service: ms}
+}
+
+// EnqueueMessageRequest is the payload accepted by the enqueue endpoint
+type EnqueueMessageRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Content     string `json:"content"`
+	// SendAt defers delivery until the given time (RFC3339); omit to send as soon as possible
+	SendAt time.Time `json:"send_at"`
+	// ExpiresAt drops the message instead of sending it after the given time (RFC3339)
+	ExpiresAt time.Time `json:"expires_at"`
+	// Priority is one of low, normal (default) or high
+	Priority model.MessagePriority `json:"priority" swaggertype:"string" enums:"low,normal,high"`
+	// MaxAttempts overrides the relayer's maximum number of send attempts for this message
+	MaxAttempts int `json:"max_attempts" minimum:"1" maximum:"100"`
+	// CallbackURL receives the message's status changes instead of the URL registered for X-Client-ID
+	CallbackURL string `json:"callback_url"`
+}
+
+// EnqueueMessage stores a new outbound message to be relayed by the scheduler.
+//
+// @Summary      Enqueue a message
+// @Description  Validates and stores a new `pending` message. Phone numbers may only contain digits and `+`
+// @Description  (max 20 characters) and content must be 1–160 characters. An optional `send_at` defers delivery
+// @Description  until that time, and an optional `expires_at` moves the message to `expired` instead of sending it late.
+// @Description  `priority` (low, normal, high) decides how soon the message is relayed relative to the backlog,
+// @Description  and `max_attempts` overrides how often a failing send is retried. `callback_url` receives a signed
+// @Description  POST for every status change of the message, taking precedence over the client's registered callback.
+// @Description  Requests carrying an `Idempotency-Key` already used by the same `X-Client-ID` return the originally
+// @Description  created message with status 200 and `Idempotent-Replayed: true` instead of inserting a new one.
+// @Tags         messages
+// @Accept       json
+// @Produce      json
+//
+// @Param        Idempotency-Key  header    string                 false  "Key deduplicating retried requests"
+// @Param        X-Client-ID      header    string                 false  "Producer identifier scoping the idempotency key"
+// @Param        message          body      EnqueueMessageRequest  true   "Message to enqueue"
+//
+// @Success      201  {object}  model.Message  "Message created"
+// @Success      200  {object}  model.Message  "Replay of an earlier request with the same idempotency key"
+// @Failure      400  {object}  ErrorResponse  "Invalid request format or validation failure"
+// @Failure      422  {object}  ErrorResponse  "Idempotency key reused with a different payload"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages [post]
+func (h *MessageHandler) EnqueueMessage(w http.ResponseWriter, r *http.Request) {
+	var req EnqueueMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	msg, replayed, err := h.service.Enqueue(r.Context(), model.NewMessage{
+		PhoneNumber:    req.PhoneNumber,
+		Content:        req.Content,
+		ClientID:       r.Header.Get(ClientIDHeader),
+		IdempotencyKey: r.Header.Get(IdempotencyKeyHeader),
+		SendAt:         req.SendAt,
+		ExpiresAt:      req.ExpiresAt,
+		Priority:       req.Priority,
+		MaxAttempts:    req.MaxAttempts,
+		CallbackURL:    req.CallbackURL,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	if replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+		WriteJSON(w, http.StatusOK, msg)
+		return
+	}
+	if msg.IdempotencyKey != "" {
+		w.Header().Set(IdempotentReplayedHeader, "false")
+	}
+	WriteJSON(w, http.StatusCreated, msg)
+}
+
+// ImportMessages bulk loads messages from an NDJSON or CSV upload.
+//
+// @Summary      Bulk import messages
+// @Description  Streams an upload into the message table using Postgres `COPY`. The format is selected by `Content-Type`:
+// @Description  `application/x-ndjson` expects one JSON object per line with `phone_number` and `content`,
+// @Description  `text/csv` expects a header row naming the `phone_number` and `content` columns.
+// @Description  Lines may also set `send_at`, `expires_at` (RFC3339), `priority`, `max_attempts` and `callback_url`
+// @Description  as on enqueue; idempotency keys are not supported. Imported messages belong to `X-Client-ID`.
+// @Description  Lines failing validation are reported as rejected; all accepted lines are stored atomically.
+// @Tags         messages
+// @Accept       text/csv
+// @Accept       application/x-ndjson
+// @Produce      json
+//
+// @Param        X-Client-ID  header  string  false  "Producer the imported messages belong to"
+//
+// @Success      200  {object}  service.ImportReport
+// @Failure      400  {object}  ErrorResponse  "Malformed upload"
+// @Failure      415  {object}  ErrorResponse  "Unsupported content type"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/import [post]
+func (h *MessageHandler) ImportMessages(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		WriteError(w, http.StatusUnsupportedMediaType, "missing or invalid Content-Type")
+		return
+	}
+
+	var format service.ImportFormat
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson", "application/jsonl":
+		format = service.ImportFormatNDJSON
+	case "text/csv":
+		format = service.ImportFormatCSV
+	default:
+		WriteError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/x-ndjson or text/csv")
+		return
+	}
+
+	report, err := h.service.Import(r.Context(), r.Header.Get(ClientIDHeader), format, r.Body)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, report)
+}
+
+// ReplayMessagesRequest selects failed messages to move back to pending
+type ReplayMessagesRequest struct {
+	IDs    []int64              `json:"ids"`
+	Filter MessageFilterRequest `json:"filter"`
+	// PhoneNumber and Content replace the stored values of every replayed message when set
+	PhoneNumber string `json:"phone_number"`
+	Content     string `json:"content"`
+	// ReplayedBy defaults to the X-Client-ID header
+	ReplayedBy string `json:"replayed_by"`
+}
+
+// MessageFilterRequest narrows the messages a bulk operation applies to; unset fields match every message
+type MessageFilterRequest struct {
+	PhoneNumber string    `json:"phone_number"`
+	MinID       int64     `json:"min_id"`
+	MaxID       int64     `json:"max_id"`
+	CreatedFrom time.Time `json:"created_from"`
+	CreatedTo   time.Time `json:"created_to"`
+	MinAttempts *int      `json:"min_attempts"`
+	MaxAttempts *int      `json:"max_attempts"`
+	// FailureReasons selects failed messages by why they were given up on
+	FailureReasons []model.FailureReason `json:"failure_reason" enums:"max_attempts,unrecoverable_error,unexpected_response,unknown_outcome"`
+}
+
+func (f MessageFilterRequest) toFilter() model.MessageFilter {
+	return model.MessageFilter{
+		PhoneNumber:    f.PhoneNumber,
+		MinID:          f.MinID,
+		MaxID:          f.MaxID,
+		CreatedFrom:    f.CreatedFrom,
+		CreatedTo:      f.CreatedTo,
+		MinAttempts:    f.MinAttempts,
+		MaxAttempts:    f.MaxAttempts,
+		FailureReasons: f.FailureReasons,
+	}
+}
+
+// ReplayMessages moves failed messages back to pending.
+//
+// @Summary      Replay failed messages
+// @Description  Moves the `failed` messages selected by `ids` and/or `filter` back to `pending` with their attempt count
+// @Description  reset, optionally replacing their phone number or content. Each replayed message is recorded with
+// @Description  `replayed_by` (defaulting to `X-Client-ID`) and is listed by `GET /api/v1/messages/{id}/replays`.
+// @Tags         messages
+// @Accept       json
+// @Produce      json
+//
+// @Param        X-Client-ID  header    string                 false  "Caller recorded as replayed_by when the body omits it"
+// @Param        replay       body      ReplayMessagesRequest  true   "Messages to replay"
+//
+// @Success      200  {object}  service.ReplayResult
+// @Failure      400  {object}  ErrorResponse  "Invalid request format or validation failure"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/replay [post]
+func (h *MessageHandler) ReplayMessages(w http.ResponseWriter, r *http.Request) {
+	var req ReplayMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	replayedBy := req.ReplayedBy
+	if replayedBy == "" {
+		replayedBy = r.Header.Get(ClientIDHeader)
+	}
+
+	result, err := h.service.Replay(r.Context(), model.ReplayRequest{
+		IDs:         req.IDs,
+		Filter:      req.Filter.toFilter(),
+		PhoneNumber: req.PhoneNumber,
+		Content:     req.Content,
+		ReplayedBy:  replayedBy,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, result)
+}
+
+// CancelMessage withdraws a pending message.
+//
+// @Summary      Cancel a message
+// @Description  Moves a `pending` message to `cancelled` so it is never relayed. Fails with 409 when the message
+// @Description  is no longer pending or is being relayed at that moment.
+// @Tags         messages
+// @Produce      json
+//
+// @Param        id   path      int  true  "Message ID"
+//
+// @Success      200  {object}  model.Message
+// @Failure      400  {object}  ErrorResponse  "Invalid message ID"
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      409  {object}  ErrorResponse  "Message already left pending or is being relayed"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/{id}/cancel [post]
+func (h *MessageHandler) CancelMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMessageID(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msg, err := h.service.Cancel(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, msg)
+}
+
+// EditMessageRequest carries the fields to change on a pending message; omitted fields are kept
+type EditMessageRequest struct {
+	PhoneNumber string `json:"phone_number"`
+	Content     string `json:"content"`
+}
+
+// EditMessage changes a pending message.
+//
+// @Summary      Edit a message
+// @Description  Replaces the phone number and/or content of a `pending` message. Fails with 409 when the message
+// @Description  is no longer pending or is being relayed at that moment.
+// @Tags         messages
+// @Accept       json
+// @Produce      json
+//
+// @Param        id       path      int                 true  "Message ID"
+// @Param        message  body      EditMessageRequest  true  "Fields to change"
+//
+// @Success      200  {object}  model.Message
+// @Failure      400  {object}  ErrorResponse  "Invalid request format or validation failure"
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      409  {object}  ErrorResponse  "Message is no longer pending"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/{id} [patch]
+func (h *MessageHandler) EditMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMessageID(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var req EditMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	msg, err := h.service.Edit(r.Context(), id, model.MessageEdit{
+		PhoneNumber: req.PhoneNumber,
+		Content:     req.Content,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, msg)
+}
+
+// CancelMessagesRequest selects the pending messages to cancel
+type CancelMessagesRequest struct {
+	Filter MessageFilterRequest `json:"filter"`
+}
+
+// CancelMessagesResponse reports how many messages a bulk cancel affected
+type CancelMessagesResponse struct {
+	Cancelled int64 `json:"cancelled"`
+}
+
+// CancelMessages withdraws every pending message matching a filter.
+//
+// @Summary      Bulk cancel messages
+// @Description  Moves all `pending` messages matching the filter to `cancelled`. Messages being relayed at that
+// @Description  moment are skipped. An empty filter is rejected.
+// @Tags         messages
+// @Accept       json
+// @Produce      json
+//
+// @Param        cancel  body      CancelMessagesRequest  true  "Messages to cancel"
+//
+// @Success      200  {object}  CancelMessagesResponse
+// @Failure      400  {object}  ErrorResponse  "Invalid request format or validation failure"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/cancel [post]
+func (h *MessageHandler) CancelMessages(w http.ResponseWriter, r *http.Request) {
+	var req CancelMessagesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	n, err := h.service.CancelMatching(r.Context(), req.Filter.toFilter())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, CancelMessagesResponse{Cancelled: n})
+}
+
+// writeServiceError maps domain errors to their HTTP status codes
+func writeServiceError(w http.ResponseWriter, err error) {
+	var (
+		vErr *model.ValidationError
+		tErr *model.TransitionError
+	)
+	switch {
+	case errors.As(err, &vErr):
+		WriteError(w, http.StatusBadRequest, vErr.Error())
+		return
+	case errors.Is(err, model.ErrIdempotencyKeyReused):
+		WriteError(w, http.StatusUnprocessableEntity, model.ErrIdempotencyKeyReused.Error())
+		return
+	case errors.Is(err, model.ErrNotFound):
+		WriteError(w, http.StatusNotFound, model.ErrNotFound.Error())
+		return
+	case errors.Is(err, model.ErrCallbackNotFound):
+		WriteError(w, http.StatusNotFound, model.ErrCallbackNotFound.Error())
+		return
+	case errors.Is(err, model.ErrNotPending):
+		WriteError(w, http.StatusConflict, model.ErrNotPending.Error())
+		return
+	case errors.As(err, &tErr):
+		WriteError(w, http.StatusConflict, tErr.Error())
+		return
+	}
+	WriteError(w, http.StatusInternalServerError, err.Error())
+}
@@ -0,0 +1,395 @@
+package handler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lazerion/outbox-relayer/internal/api/handler"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+)
+
+type MockMessageService struct {
+	msg      *model.Message
+	replayed bool
+	err      error
+	got      model.NewMessage
+
+	report    *service.ImportReport
+	gotFormat service.ImportFormat
+	gotClient string
+
+	replayResult *service.ReplayResult
+	gotReplay    model.ReplayRequest
+
+	cancelled int64
+	gotID     int64
+	gotEdit   model.MessageEdit
+	gotFilter model.MessageFilter
+}
+
+func (m *MockMessageService) Cancel(ctx context.Context, id int64) (*model.Message, error) {
+	m.gotID = id
+	return m.msg, m.err
+}
+
+func (m *MockMessageService) Edit(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error) {
+	m.gotID, m.gotEdit = id, edit
+	return m.msg, m.err
+}
+
+func (m *MockMessageService) CancelMatching(ctx context.Context, filter model.MessageFilter) (int64, error) {
+	m.gotFilter = filter
+	return m.cancelled, m.err
+}
+
+func (m *MockMessageService) Replay(ctx context.Context, req model.ReplayRequest) (*service.ReplayResult, error) {
+	m.gotReplay = req
+	return m.replayResult, m.err
+}
+
+func (m *MockMessageService) Enqueue(ctx context.Context, msg model.NewMessage) (*model.Message, bool, error) {
+	m.got = msg
+	return m.msg, m.replayed, m.err
+}
+
+func (m *MockMessageService) Import(ctx context.Context, clientID string, format service.ImportFormat, body io.Reader) (*service.ImportReport, error) {
+	m.gotFormat = format
+	m.gotClient = clientID
+	return m.report, m.err
+}
+
+func TestEnqueueMessage(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		mockMsg        *model.Message
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{
+			name:           "Valid request",
+			body:           `{"phone_number":"+905551112233","content":"hello"}`,
+			mockMsg:        &model.Message{ID: 11, PhoneNumber: "+905551112233", Content: "hello", Status: "pending"},
+			wantStatusCode: http.StatusCreated,
+			wantBodySubstr: `"id":11`,
+		},
+		{
+			name:           "Malformed JSON",
+			body:           `{"phone_number":`,
+			wantStatusCode: http.StatusBadRequest,
+			wantBodySubstr: "invalid request body",
+		},
+		{
+			name:           "Validation failure",
+			body:           `{"phone_number":"abc","content":"hello"}`,
+			mockErr:        fmt.Errorf("enqueue message: %w", &model.ValidationError{Field: "phone_number", Reason: "must contain only digits and '+'"}),
+			wantStatusCode: http.StatusBadRequest,
+			wantBodySubstr: "invalid phone_number",
+		},
+		{
+			name:           "Service error",
+			body:           `{"phone_number":"+90555","content":"hello"}`,
+			mockErr:        errors.New("db down"),
+			wantStatusCode: http.StatusInternalServerError,
+			wantBodySubstr: "db down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &MockMessageService{msg: tt.mockMsg, err: tt.mockErr}
+			h := handler.NewMessageHandler(mockSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(tt.body))
+			w := httptest.NewRecorder()
+
+			h.EnqueueMessage(w, req)
+
+			resp := w.Result()
+			body := w.Body.String()
+
+			if resp.StatusCode != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, resp.StatusCode)
+			}
+
+			if !strings.Contains(body, tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, body)
+			}
+		})
+	}
+}
+
+func TestEnqueueMessage_Scheduling(t *testing.T) {
+	mockSvc := &MockMessageService{msg: &model.Message{ID: 12}}
+	h := handler.NewMessageHandler(mockSvc)
+
+	req := httptest.NewRequest(http.MethodPost, "/messages",
+		strings.NewReader(`{"phone_number":"+90555","content":"reminder","send_at":"2025-12-01T09:00:00+03:00","priority":"high","max_attempts":10}`))
+	w := httptest.NewRecorder()
+
+	h.EnqueueMessage(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected status %d, got %d", http.StatusCreated, w.Code)
+	}
+	want := time.Date(2025, 12, 1, 6, 0, 0, 0, time.UTC)
+	if !mockSvc.got.SendAt.Equal(want) {
+		t.Errorf("expected send_at %v, got %v", want, mockSvc.got.SendAt)
+	}
+	if mockSvc.got.Priority != model.PriorityHigh {
+		t.Errorf("expected priority high, got %v", mockSvc.got.Priority)
+	}
+	if mockSvc.got.MaxAttempts != 10 {
+		t.Errorf("expected max attempts 10, got %d", mockSvc.got.MaxAttempts)
+	}
+}
+
+func TestEnqueueMessage_Idempotency(t *testing.T) {
+	tests := []struct {
+		name           string
+		replayed       bool
+		err            error
+		wantStatusCode int
+		wantReplayed   string
+	}{
+		{"First request", false, nil, http.StatusCreated, "false"},
+		{"Replayed request", true, nil, http.StatusOK, "true"},
+		{"Key reused with other payload", false, fmt.Errorf("enqueue message: %w", model.ErrIdempotencyKeyReused), http.StatusUnprocessableEntity, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &MockMessageService{
+				msg:      &model.Message{ID: 3, IdempotencyKey: "key-1"},
+				replayed: tt.replayed,
+				err:      tt.err,
+			}
+			h := handler.NewMessageHandler(mockSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/messages", strings.NewReader(`{"phone_number":"+90555","content":"hi"}`))
+			req.Header.Set(handler.IdempotencyKeyHeader, "key-1")
+			req.Header.Set(handler.ClientIDHeader, "billing")
+			w := httptest.NewRecorder()
+
+			h.EnqueueMessage(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if got := w.Header().Get(handler.IdempotentReplayedHeader); got != tt.wantReplayed {
+				t.Errorf("expected %s=%q, got %q", handler.IdempotentReplayedHeader, tt.wantReplayed, got)
+			}
+			if mockSvc.got.IdempotencyKey != "key-1" || mockSvc.got.ClientID != "billing" {
+				t.Errorf("headers not passed to service: %+v", mockSvc.got)
+			}
+		})
+	}
+}
+
+func TestImportMessages(t *testing.T) {
+	report := &service.ImportReport{
+		Accepted: 1,
+		Rejected: 1,
+		Lines: []service.ImportLineResult{
+			{Line: 1, Status: service.ImportLineAccepted},
+			{Line: 2, Status: service.ImportLineRejected, Error: "invalid phone_number"},
+		},
+	}
+
+	tests := []struct {
+		name           string
+		contentType    string
+		wantFormat     service.ImportFormat
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"NDJSON", "application/x-ndjson", service.ImportFormatNDJSON, http.StatusOK, `"rejected":1`},
+		{"CSV with charset", "text/csv; charset=utf-8", service.ImportFormatCSV, http.StatusOK, `"accepted":1`},
+		{"Unsupported type", "application/json", "", http.StatusUnsupportedMediaType, "Content-Type must be"},
+		{"Missing type", "", "", http.StatusUnsupportedMediaType, "Content-Type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &MockMessageService{report: report}
+			h := handler.NewMessageHandler(mockSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/messages/import", strings.NewReader("ignored"))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			req.Header.Set("X-Client-ID", "billing")
+			w := httptest.NewRecorder()
+
+			h.ImportMessages(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if mockSvc.gotFormat != tt.wantFormat {
+				t.Errorf("expected format %q, got %q", tt.wantFormat, mockSvc.gotFormat)
+			}
+			if tt.wantFormat != "" && mockSvc.gotClient != "billing" {
+				t.Errorf("expected client ID to be passed to service, got %q", mockSvc.gotClient)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReplayMessages(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		clientID       string
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+		wantReplayedBy string
+	}{
+		{
+			name:           "By IDs",
+			body:           `{"ids":[4,5],"content":"fixed","replayed_by":"alice"}`,
+			clientID:       "ops",
+			wantStatusCode: http.StatusOK,
+			wantBodySubstr: `"replayed":1`,
+			wantReplayedBy: "alice",
+		},
+		{
+			name:           "By filter with client fallback",
+			body:           `{"filter":{"phone_number":"+90555","created_from":"2025-12-01T00:00:00Z"}}`,
+			clientID:       "ops",
+			wantStatusCode: http.StatusOK,
+			wantBodySubstr: `"replayed":1`,
+			wantReplayedBy: "ops",
+		},
+		{
+			name:           "Malformed JSON",
+			body:           `{"ids":`,
+			wantStatusCode: http.StatusBadRequest,
+			wantBodySubstr: "invalid request body",
+		},
+		{
+			name:           "Validation failure",
+			body:           `{}`,
+			mockErr:        &model.ValidationError{Field: "ids", Reason: "either ids or a filter is required"},
+			wantStatusCode: http.StatusBadRequest,
+			wantBodySubstr: "invalid ids",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &MockMessageService{
+				replayResult: &service.ReplayResult{Replayed: 1, Messages: []model.Message{{ID: 4}}},
+				err:          tt.mockErr,
+			}
+			h := handler.NewMessageHandler(mockSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/messages/replay", strings.NewReader(tt.body))
+			if tt.clientID != "" {
+				req.Header.Set(handler.ClientIDHeader, tt.clientID)
+			}
+			w := httptest.NewRecorder()
+
+			h.ReplayMessages(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+			if tt.wantReplayedBy != "" && mockSvc.gotReplay.ReplayedBy != tt.wantReplayedBy {
+				t.Errorf("expected replayed_by %q, got %q", tt.wantReplayedBy, mockSvc.gotReplay.ReplayedBy)
+			}
+		})
+	}
+}
+
+func TestCancelMessage(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"Cancelled", "5", nil, http.StatusOK, `"status":"cancelled"`},
+		{"Invalid ID", "0", nil, http.StatusBadRequest, "positive integer"},
+		{"Not pending", "6", fmt.Errorf("cancel message 6: %w", model.ErrNotPending), http.StatusConflict, "no longer pending"},
+		{"Already sent", "6", fmt.Errorf("cancel message 6: %w", &model.TransitionError{MessageID: 6, From: model.StatusSent, To: model.StatusCancelled}), http.StatusConflict, "cannot move from sent to cancelled"},
+		{"Not found", "7", fmt.Errorf("cancel message 7: %w", model.ErrNotFound), http.StatusNotFound, "message not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockSvc := &MockMessageService{msg: &model.Message{ID: 5, Status: model.StatusCancelled}, err: tt.mockErr}
+			h := handler.NewMessageHandler(mockSvc)
+
+			req := httptest.NewRequest(http.MethodPost, "/messages/"+tt.id+"/cancel", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			h.CancelMessage(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestEditMessage(t *testing.T) {
+	mockSvc := &MockMessageService{msg: &model.Message{ID: 5, Content: "updated", Status: model.StatusPending}}
+	h := handler.NewMessageHandler(mockSvc)
+
+	req := httptest.NewRequest(http.MethodPatch, "/messages/5", strings.NewReader(`{"content":"updated"}`))
+	req = mux.SetURLVars(req, map[string]string{"id": "5"})
+	w := httptest.NewRecorder()
+
+	h.EditMessage(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if mockSvc.gotID != 5 || mockSvc.gotEdit.Content != "updated" || mockSvc.gotEdit.PhoneNumber != "" {
+		t.Errorf("unexpected edit passed to service: id=%d %+v", mockSvc.gotID, mockSvc.gotEdit)
+	}
+}
+
+func TestCancelMessages(t *testing.T) {
+	mockSvc := &MockMessageService{cancelled: 12}
+	h := handler.NewMessageHandler(mockSvc)
+
+	req := httptest.NewRequest(http.MethodPost, "/messages/cancel",
+		strings.NewReader(`{"filter":{"phone_number":"+90555","max_id":100}}`))
+	w := httptest.NewRecorder()
+
+	h.CancelMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"cancelled":12`) {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+	if mockSvc.gotFilter.PhoneNumber != "+90555" || mockSvc.gotFilter.MaxID != 100 {
+		t.Errorf("unexpected filter passed to service: %+v", mockSvc.gotFilter)
+	}
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+const (
+	defaultPageLimit = 42
+	maxPageLimit     = 50
+)
+
+// parseLimit reads the `limit` query parameter, falling back to defaultPageLimit
+func parseLimit(q url.Values) (int, error) {
+	limit := defaultPageLimit
+	if v := q.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 {
+			return 0, fmt.Errorf("'limit' must be a positive integer")
+		}
+		limit = l
+	}
+
+	if limit > maxPageLimit {
+		return 0, fmt.Errorf("'limit' cannot exceed %d", maxPageLimit)
+	}
+	return limit, nil
+}
+
+// parseMessageID reads the `{id}` path variable of the message routes
+func parseMessageID(r *http.Request) (int64, error) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errors.New("message ID must be a positive integer")
+	}
+	return id, nil
+}
+
+// parseMessageFilter reads the search criteria shared by the message listing endpoints
+func parseMessageFilter(q url.Values) (model.MessageFilter, error) {
+	var (
+		f   model.MessageFilter
+		err error
+	)
+
+	for _, v := range q["status"] {
+		statuses, err := model.ParseStatuses(v)
+		if err != nil {
+			return f, err
+		}
+		f.Statuses = append(f.Statuses, statuses...)
+	}
+
+	for _, v := range q["failure_reason"] {
+		reasons, err := model.ParseFailureReasons(v)
+		if err != nil {
+			return f, err
+		}
+		f.FailureReasons = append(f.FailureReasons, reasons...)
+	}
+
+	f.PhoneNumber = q.Get("phone_number")
+
+	if f.MinID, err = parseInt64Param(q, "min_id"); err != nil {
+		return f, err
+	}
+	if f.MaxID, err = parseInt64Param(q, "max_id"); err != nil {
+		return f, err
+	}
+	if f.CreatedFrom, err = parseTimeParam(q, "created_from"); err != nil {
+		return f, err
+	}
+	if f.CreatedTo, err = parseTimeParam(q, "created_to"); err != nil {
+		return f, err
+	}
+	if f.SentFrom, err = parseTimeParam(q, "sent_from"); err != nil {
+		return f, err
+	}
+	if f.SentTo, err = parseTimeParam(q, "sent_to"); err != nil {
+		return f, err
+	}
+	if f.MinAttempts, err = parseOptionalIntParam(q, "min_attempts"); err != nil {
+		return f, err
+	}
+	if f.MaxAttempts, err = parseOptionalIntParam(q, "max_attempts"); err != nil {
+		return f, err
+	}
+	if f.Scheduled, err = parseOptionalBoolParam(q, "scheduled"); err != nil {
+		return f, err
+	}
+
+	return f, nil
+}
+
+func parseInt64Param(q url.Values, name string) (int64, error) {
+	v := q.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 {
+		return 0, &model.ValidationError{Field: name, Reason: "must be a non-negative integer"}
+	}
+	return n, nil
+}
+
+func parseOptionalIntParam(q url.Values, name string) (*int, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return nil, &model.ValidationError{Field: name, Reason: "must be a non-negative integer"}
+	}
+	return &n, nil
+}
+
+func parseOptionalBoolParam(q url.Values, name string) (*bool, error) {
+	v := q.Get(name)
+	if v == "" {
+		return nil, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return nil, &model.ValidationError{Field: name, Reason: "must be true or false"}
+	}
+	return &b, nil
+}
+
+func parseTimeParam(q url.Values, name string) (time.Time, error) {
+	v := q.Get(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, &model.ValidationError{Field: name, Reason: "must be an RFC3339 timestamp"}
+	}
+	return t, nil
+}
@@ -1,11 +1,10 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/lazerion/outbox-relayer/internal/service"
 )
 
@@ -20,59 +19,272 @@ func NewQueryHandler(qs service.QueryServiceInterface) *QueryHandler {
 // ListSentMessages retrieves sent SMS messages using cursor-based pagination.
 //
 // @Summary      List sent messages
-// @Description  Returns a list of messages with status `sent`, ordered by `sent_time` ascending.
-// @Description  Supports cursor-based pagination via the `after` cursor.
+// @Description  Returns a list of messages with status `sent`, ordered by `sent_time` and `id` ascending.
+// @Description  Supports cursor-based pagination: pass `next_cursor` or `prev_cursor` from a previous response as `cursor`
+// @Description  to page forward or backward. Cursors are opaque. The legacy `after` parameter still accepts a timestamp,
+// @Description  and also a `next_cursor` passed back by clients written against the former timestamp cursor.
 // @Tags         messages
 // @Accept       json
 // @Produce      json
 //
-// @Param        after  query     string  false  "Return messages sent after this timestamp (RFC3339)"
-// @Param        limit  query     int     false  "Number of messages to return (1–50), defaults to 42"
+// @Param        cursor          query     string  false  "Opaque cursor returned by a previous page"
+// @Param        after           query     string  false  "Return messages sent after this timestamp (RFC3339) or cursor, deprecated in favor of cursor"
+// @Param        limit           query     int     false  "Number of messages to return (1–50), defaults to 42"
 //
-// @Success      200  {array}  service.SentMessagesResponse
+// @Success      200  {object}  service.SentMessagesResponse
 // @Failure      400  {object}  ErrorResponse  "Invalid request format"
 // @Failure      500  {object}  ErrorResponse  "Internal server error"
 //
 // @Router       /api/v1/messages/sent [get]
 func (h *QueryHandler) ListSentMessages(w http.ResponseWriter, r *http.Request) {
-	const (
-		defaultLimit = 42
-		maxLimit     = 50
-	)
-
 	ctx := r.Context()
 	q := r.URL.Query()
 
+	cursor := q.Get("cursor")
 	var after time.Time
 	if v := q.Get("after"); v != "" {
+		// next_cursor used to be a timestamp, so clients still pass it back as 'after'
 		t, err := time.Parse(time.RFC3339, v)
-		if err != nil {
-			WriteError(w, http.StatusBadRequest, "invalid 'after' timestamp")
+		switch {
+		case err == nil:
+			after = t
+		case cursor == "":
+			cursor = v
+		default:
+			WriteError(w, http.StatusBadRequest, "'after' cannot be combined with 'cursor'")
 			return
 		}
-		after = t
 	}
 
-	limit := defaultLimit
-	if v := q.Get("limit"); v != "" {
-		l, err := strconv.Atoi(v)
-		if err != nil || l <= 0 {
-			WriteError(w, http.StatusBadRequest, "'limit' must be a positive integer")
-			return
-		}
-		limit = l
+	limit, err := parseLimit(q)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := h.service.ListSentMessages(ctx, service.SentMessagesQuery{
+		Cursor: cursor,
+		After:  after,
+		Limit:  limit,
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, resp)
+}
+
+// SearchMessages lists messages in any status matching the given filters.
+//
+// @Summary      Search messages
+// @Description  Returns messages matching all given filters, ordered by `id` ascending.
+// @Description  Supports keyset pagination: pass the returned `next_after_id` as `after_id` to fetch the next page.
+// @Tags         messages
+// @Produce      json
+//
+// @Param        status          query     string  false  "Comma separated statuses (pending, sent, failed, cancelled, expired, unknown, delivered, undelivered)"
+// @Param        failure_reason  query     string  false  "Comma separated failure reasons (max_attempts, unrecoverable_error, unexpected_response, unknown_outcome)"
+// @Param        phone_number    query     string  false  "Recipient phone number"
+// @Param        min_id          query     int     false  "Smallest message ID (inclusive)"
+// @Param        max_id          query     int     false  "Largest message ID (inclusive)"
+// @Param        created_from    query     string  false  "Created at or after this timestamp (RFC3339)"
+// @Param        created_to      query     string  false  "Created before this timestamp (RFC3339)"
+// @Param        sent_from       query     string  false  "Sent at or after this timestamp (RFC3339)"
+// @Param        sent_to         query     string  false  "Sent before this timestamp (RFC3339)"
+// @Param        min_attempts    query     int     false  "Minimum attempt count"
+// @Param        max_attempts    query     int     false  "Maximum attempt count"
+// @Param        scheduled       query     bool    false  "Only messages with a future send_at (true) or only due messages (false)"
+// @Param        after_id        query     int     false  "Return messages with an ID greater than this cursor"
+// @Param        limit           query     int     false  "Number of messages to return (1–50), defaults to 42"
+//
+// @Success      200  {object}  service.MessagesPage
+// @Failure      400  {object}  ErrorResponse  "Invalid filter"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages [get]
+func (h *QueryHandler) SearchMessages(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter, err := parseMessageFilter(q)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	if limit > maxLimit {
-		WriteError(w, http.StatusBadRequest, fmt.Sprintf("'limit' cannot exceed %d", maxLimit))
+	afterID, err := parseInt64Param(q, "after_id")
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	resp, err := h.service.ListSentMessages(ctx, after, limit)
+	limit, err := parseLimit(q)
 	if err != nil {
-		WriteError(w, http.StatusInternalServerError, err.Error())
+		WriteError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	WriteJSON(w, http.StatusOK, resp)
+	page, err := h.service.SearchMessages(r.Context(), filter, afterID, limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, page)
+}
+
+// GetMessage retrieves a single message by its ID.
+//
+// @Summary      Get a message
+// @Description  Returns the message with the given ID in any status, including its attempt count.
+// @Tags         messages
+// @Produce      json
+//
+// @Param        id   path      int  true  "Message ID"
+//
+// @Success      200  {object}  model.Message
+// @Failure      400  {object}  ErrorResponse  "Invalid message ID"
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/{id} [get]
+func (h *QueryHandler) GetMessage(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMessageID(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	msg, err := h.service.GetMessage(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, msg)
+}
+
+// GetMessageByExternalID retrieves a single message by the ID assigned by the SMS gateway.
+//
+// @Summary      Get a message by gateway ID
+// @Description  Returns the message the gateway acknowledged with the given `messageId`.
+// @Description  Lookups are served from the Redis cache when possible and fall back to the database.
+// @Tags         messages
+// @Produce      json
+//
+// @Param        externalId  path      string  true  "Gateway assigned message ID"
+//
+// @Success      200  {object}  model.Message
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/by-external/{externalId} [get]
+func (h *QueryHandler) GetMessageByExternalID(w http.ResponseWriter, r *http.Request) {
+	msg, err := h.service.GetMessageByExternalID(r.Context(), mux.Vars(r)["externalId"])
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, msg)
+}
+
+// GetMessageReplays lists the replays of a single message.
+//
+// @Summary      List message replays
+// @Description  Returns who moved the message back to `pending` and when, together with the values it had before.
+// @Tags         messages
+// @Produce      json
+//
+// @Param        id   path      int  true  "Message ID"
+//
+// @Success      200  {array}   model.MessageReplay
+// @Failure      400  {object}  ErrorResponse  "Invalid message ID"
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/{id}/replays [get]
+func (h *QueryHandler) GetMessageReplays(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMessageID(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	replays, err := h.service.ListReplays(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, replays)
+}
+
+// GetMessageAttempts lists the send attempts of a single message.
+//
+// @Summary      List message send attempts
+// @Description  Returns every attempt the relayer made to send the message with the gateway's HTTP status, error,
+// @Description  recoverability, external ID and duration, oldest first.
+// @Tags         messages
+// @Produce      json
+//
+// @Param        id   path      int  true  "Message ID"
+//
+// @Success      200  {array}   model.MessageAttempt
+// @Failure      400  {object}  ErrorResponse  "Invalid message ID"
+// @Failure      404  {object}  ErrorResponse  "Message not found"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/{id}/attempts [get]
+func (h *QueryHandler) GetMessageAttempts(w http.ResponseWriter, r *http.Request) {
+	id, err := parseMessageID(r)
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	attempts, err := h.service.ListAttempts(r.Context(), id)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, attempts)
+}
+
+// GetMessageStats counts messages per status.
+//
+// @Summary      Message statistics
+// @Description  Returns the number of messages per status among those matching the filters, e.g. how many messages
+// @Description  created in a time range ended up `expired`.
+// @Tags         messages
+// @Produce      json
+//
+// @Param        status          query     string  false  "Comma separated statuses (pending, sent, failed, cancelled, expired, unknown, delivered, undelivered)"
+// @Param        failure_reason  query     string  false  "Comma separated failure reasons (max_attempts, unrecoverable_error, unexpected_response, unknown_outcome)"
+// @Param        phone_number    query     string  false  "Recipient phone number"
+// @Param        created_from    query     string  false  "Created at or after this timestamp (RFC3339)"
+// @Param        created_to      query     string  false  "Created before this timestamp (RFC3339)"
+// @Param        sent_from       query     string  false  "Sent at or after this timestamp (RFC3339)"
+// @Param        sent_to         query     string  false  "Sent before this timestamp (RFC3339)"
+//
+// @Success      200  {object}  service.MessageStats
+// @Failure      400  {object}  ErrorResponse  "Invalid filter"
+// @Failure      500  {object}  ErrorResponse  "Internal server error"
+//
+// @Router       /api/v1/messages/stats [get]
+func (h *QueryHandler) GetMessageStats(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseMessageFilter(r.URL.Query())
+	if err != nil {
+		WriteError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stats, err := h.service.Stats(r.Context(), filter)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	WriteJSON(w, http.StatusOK, stats)
 }
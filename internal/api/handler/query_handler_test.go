@@ -2,30 +2,72 @@ package handler_test
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/lazerion/outbox-relayer/internal/api/handler"
 	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
 	"github.com/lazerion/outbox-relayer/internal/service"
 )
 
 type MockQueryService struct {
-	resp *service.SentMessagesResponse
-	err  error
+	resp     *service.SentMessagesResponse
+	msg      *model.Message
+	page     *service.MessagesPage
+	replays  []model.MessageReplay
+	attempts []model.MessageAttempt
+	stats    *service.MessageStats
+	err      error
+
+	gotSentQuery service.SentMessagesQuery
+	gotFilter    model.MessageFilter
+	gotAfterID   int64
+	gotLimit     int
+}
+
+func (m *MockQueryService) SearchMessages(ctx context.Context, filter model.MessageFilter, afterID int64, limit int) (*service.MessagesPage, error) {
+	m.gotFilter, m.gotAfterID, m.gotLimit = filter, afterID, limit
+	return m.page, m.err
 }
 
-func (m *MockQueryService) ListSentMessages(ctx context.Context, after time.Time, limit int) (*service.SentMessagesResponse, error) {
+func (m *MockQueryService) ListSentMessages(ctx context.Context, q service.SentMessagesQuery) (*service.SentMessagesResponse, error) {
+	m.gotSentQuery = q
 	return m.resp, m.err
 }
 
+func (m *MockQueryService) GetMessage(ctx context.Context, id int64) (*model.Message, error) {
+	return m.msg, m.err
+}
+
+func (m *MockQueryService) GetMessageByExternalID(ctx context.Context, externalID string) (*model.Message, error) {
+	return m.msg, m.err
+}
+
+func (m *MockQueryService) ListReplays(ctx context.Context, messageID int64) ([]model.MessageReplay, error) {
+	return m.replays, m.err
+}
+
+func (m *MockQueryService) ListAttempts(ctx context.Context, messageID int64) ([]model.MessageAttempt, error) {
+	return m.attempts, m.err
+}
+
+func (m *MockQueryService) Stats(ctx context.Context, filter model.MessageFilter) (*service.MessageStats, error) {
+	m.gotFilter = filter
+	return m.stats, m.err
+}
+
 func TestListSentMessages(t *testing.T) {
 	msgTime := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
-	nextCursor := msgTime.Add(time.Minute)
+	nextCursor := "opaque-next"
 
 	sentResp := &service.SentMessagesResponse{
 		Messages: []model.Message{
@@ -75,12 +117,12 @@ func TestListSentMessages(t *testing.T) {
 			wantBodySubstr: "'limit' cannot exceed",
 		},
 		{
-			name:           "Invalid after timestamp",
-			query:          "limit=1&after=invalid",
+			name:           "After token combined with cursor",
+			query:          "limit=1&after=opaque&cursor=abc",
 			mockResp:       nil,
 			mockErr:        nil,
 			wantStatusCode: http.StatusBadRequest,
-			wantBodySubstr: "invalid 'after' timestamp",
+			wantBodySubstr: "'after' cannot be combined with 'cursor'",
 		},
 		{
 			name:           "Service error",
@@ -122,7 +164,7 @@ func TestListSentMessages(t *testing.T) {
 
 func TestListSentMessages_NextCursorIncluded(t *testing.T) {
 	msgTime := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
-	nextCursor := msgTime.Add(time.Minute)
+	nextCursor := "opaque-next"
 
 	mockResp := &service.SentMessagesResponse{
 		Messages: []model.Message{
@@ -153,8 +195,314 @@ func TestListSentMessages_NextCursorIncluded(t *testing.T) {
 		t.Errorf("expected body to contain message ID, got %s", body)
 	}
 
-	expectedCursor := nextCursor.Format(time.RFC3339)
-	if !strings.Contains(body, expectedCursor) {
-		t.Errorf("expected body to contain NextCursor %q, got %s", expectedCursor, body)
+	if !strings.Contains(body, `"next_cursor":"opaque-next"`) {
+		t.Errorf("expected body to contain NextCursor %q, got %s", nextCursor, body)
+	}
+}
+
+func TestListSentMessages_PassesCursorAndAfter(t *testing.T) {
+	mockSvc := &MockQueryService{resp: &service.SentMessagesResponse{}}
+	h := handler.NewQueryHandler(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages/sent?cursor=abc&after=2025-12-01T00:00:00Z&limit=5", nil)
+	w := httptest.NewRecorder()
+
+	h.ListSentMessages(w, req)
+
+	q := mockSvc.gotSentQuery
+	if q.Cursor != "abc" || q.Limit != 5 || !q.After.Equal(time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected query passed to service: %+v", q)
+	}
+}
+
+// sentRepository serves sent messages in (sent_time, id) order
+type sentRepository struct {
+	repository.QueryRepository
+	msgs []model.Message
+}
+
+func (r *sentRepository) ListSentMessages(ctx context.Context, key repository.SentKey, backward bool, limit int) ([]model.Message, error) {
+	var page []model.Message
+	for _, m := range r.msgs {
+		if m.SentTime.After(key.SentTime) || (m.SentTime.Equal(key.SentTime) && m.ID > key.ID) {
+			page = append(page, m)
+		}
+	}
+	return page[:min(limit, len(page))], nil
+}
+
+func TestListSentMessages_NextCursorAsAfter(t *testing.T) {
+	sentAt := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	repo := &sentRepository{msgs: []model.Message{
+		{ID: 1, Status: model.StatusSent, SentTime: sentAt},
+		{ID: 2, Status: model.StatusSent, SentTime: sentAt},
+		{ID: 3, Status: model.StatusSent, SentTime: sentAt.Add(time.Second)},
+	}}
+	h := handler.NewQueryHandler(service.NewQueryService(repo, nil))
+
+	list := func(query string) (int, service.SentMessagesResponse) {
+		w := httptest.NewRecorder()
+		h.ListSentMessages(w, httptest.NewRequest(http.MethodGet, "/messages/sent?"+query, nil))
+		var resp service.SentMessagesResponse
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+		}
+		return w.Code, resp
+	}
+
+	code, first := list("limit=1")
+	if code != http.StatusOK || first.NextCursor == nil {
+		t.Fatalf("expected a first page with next_cursor, got status %d", code)
+	}
+
+	// a client of the former timestamp cursor passes next_cursor back as 'after'
+	code, second := list("limit=1&after=" + url.QueryEscape(*first.NextCursor))
+	if code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", code)
+	}
+	if len(second.Messages) != 1 || second.Messages[0].ID != 2 {
+		t.Errorf("expected the second page to continue with message 2, got %+v", second.Messages)
+	}
+}
+
+func TestListSentMessages_InvalidCursor(t *testing.T) {
+	mockSvc := &MockQueryService{
+		err: &model.ValidationError{Field: "cursor", Reason: "malformed pagination cursor"},
+	}
+	h := handler.NewQueryHandler(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages/sent?cursor=garbage", nil)
+	w := httptest.NewRecorder()
+
+	h.ListSentMessages(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", w.Code)
+	}
+}
+
+func TestGetMessage(t *testing.T) {
+	tests := []struct {
+		name           string
+		id             string
+		mockMsg        *model.Message
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"Found", "5", &model.Message{ID: 5, Status: model.StatusPending, AttemptCount: 2}, nil, http.StatusOK, `"attempt_count":2`},
+		{"Invalid ID", "0", nil, nil, http.StatusBadRequest, "positive integer"},
+		{"Not found", "6", nil, fmt.Errorf("fetch message 6: %w", model.ErrNotFound), http.StatusNotFound, "message not found"},
+		{"Service error", "7", nil, errors.New("db down"), http.StatusInternalServerError, "db down"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewQueryHandler(&MockQueryService{msg: tt.mockMsg, err: tt.mockErr})
+
+			req := httptest.NewRequest(http.MethodGet, "/messages/"+tt.id, nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			h.GetMessage(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetMessageByExternalID(t *testing.T) {
+	tests := []struct {
+		name           string
+		mockMsg        *model.Message
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"Found", &model.Message{ID: 5, ExternalID: "ext-5", Status: model.StatusSent}, nil, http.StatusOK, `"external_id":"ext-5"`},
+		{"Not found", nil, model.ErrNotFound, http.StatusNotFound, "message not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewQueryHandler(&MockQueryService{msg: tt.mockMsg, err: tt.mockErr})
+
+			req := httptest.NewRequest(http.MethodGet, "/messages/by-external/ext-5", nil)
+			req = mux.SetURLVars(req, map[string]string{"externalId": "ext-5"})
+			w := httptest.NewRecorder()
+
+			h.GetMessageByExternalID(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSearchMessages(t *testing.T) {
+	nextAfterID := int64(9)
+	page := &service.MessagesPage{
+		Messages:    []model.Message{{ID: 9, Status: model.StatusFailed}},
+		NextAfterID: &nextAfterID,
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"No filters", "", http.StatusOK, `"next_after_id":9`},
+		{"All filters", "status=pending,failed&phone_number=%2B123&min_id=1&max_id=100&created_from=2025-12-01T00:00:00Z&sent_to=2025-12-02T00:00:00Z&min_attempts=1&max_attempts=3&after_id=4&limit=10", http.StatusOK, `"id":9`},
+		{"Unknown status", "status=bogus", http.StatusBadRequest, "unknown status"},
+		{"Unknown failure reason", "failure_reason=bogus", http.StatusBadRequest, "unknown failure reason"},
+		{"Invalid time", "created_from=yesterday", http.StatusBadRequest, "invalid created_from"},
+		{"Invalid attempts", "min_attempts=-1", http.StatusBadRequest, "invalid min_attempts"},
+		{"Invalid after_id", "after_id=x", http.StatusBadRequest, "invalid after_id"},
+		{"Limit exceeds max", "limit=100", http.StatusBadRequest, "'limit' cannot exceed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewQueryHandler(&MockQueryService{page: page})
+
+			req := httptest.NewRequest(http.MethodGet, "/messages?"+tt.query, nil)
+			w := httptest.NewRecorder()
+
+			h.SearchMessages(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSearchMessages_ParsesFilter(t *testing.T) {
+	mockSvc := &MockQueryService{page: &service.MessagesPage{Messages: []model.Message{}}}
+	h := handler.NewQueryHandler(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages?status=pending&status=failed&failure_reason=max_attempts,unexpected_response&phone_number=%2B123&max_attempts=2&scheduled=true&after_id=4&limit=10", nil)
+	w := httptest.NewRecorder()
+
+	h.SearchMessages(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	f := mockSvc.gotFilter
+	if len(f.Statuses) != 2 || len(f.FailureReasons) != 2 || f.PhoneNumber != "+123" || f.MaxAttempts == nil || *f.MaxAttempts != 2 ||
+		f.Scheduled == nil || !*f.Scheduled {
+		t.Errorf("unexpected filter: %+v", f)
+	}
+	if mockSvc.gotAfterID != 4 || mockSvc.gotLimit != 10 {
+		t.Errorf("unexpected pagination: after_id=%d limit=%d", mockSvc.gotAfterID, mockSvc.gotLimit)
+	}
+}
+
+func TestGetMessageReplays(t *testing.T) {
+	replays := []model.MessageReplay{{ID: 1, MessageID: 5, ReplayedBy: "ops", PreviousAttemptCount: 3}}
+
+	tests := []struct {
+		name           string
+		id             string
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"Found", "5", nil, http.StatusOK, `"replayed_by":"ops"`},
+		{"Invalid ID", "0", nil, http.StatusBadRequest, "positive integer"},
+		{"Not found", "6", fmt.Errorf("fetch message 6: %w", model.ErrNotFound), http.StatusNotFound, "message not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewQueryHandler(&MockQueryService{replays: replays, err: tt.mockErr})
+
+			req := httptest.NewRequest(http.MethodGet, "/messages/"+tt.id+"/replays", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			h.GetMessageReplays(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetMessageAttempts(t *testing.T) {
+	attempts := []model.MessageAttempt{{ID: 1, MessageID: 5, StatusCode: 503, Recoverable: true, DurationMS: 180}}
+
+	tests := []struct {
+		name           string
+		id             string
+		mockErr        error
+		wantStatusCode int
+		wantBodySubstr string
+	}{
+		{"Found", "5", nil, http.StatusOK, `"status_code":503`},
+		{"Invalid ID", "0", nil, http.StatusBadRequest, "positive integer"},
+		{"Not found", "6", fmt.Errorf("fetch message 6: %w", model.ErrNotFound), http.StatusNotFound, "message not found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := handler.NewQueryHandler(&MockQueryService{attempts: attempts, err: tt.mockErr})
+
+			req := httptest.NewRequest(http.MethodGet, "/messages/"+tt.id+"/attempts", nil)
+			req = mux.SetURLVars(req, map[string]string{"id": tt.id})
+			w := httptest.NewRecorder()
+
+			h.GetMessageAttempts(w, req)
+
+			if w.Code != tt.wantStatusCode {
+				t.Errorf("expected status %d, got %d", tt.wantStatusCode, w.Code)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBodySubstr) {
+				t.Errorf("expected body to contain %q, got %s", tt.wantBodySubstr, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestGetMessageStats(t *testing.T) {
+	mockSvc := &MockQueryService{stats: &service.MessageStats{
+		Total:    3,
+		ByStatus: map[model.MessageStatus]int64{model.StatusSent: 2, model.StatusExpired: 1},
+	}}
+	h := handler.NewQueryHandler(mockSvc)
+
+	req := httptest.NewRequest(http.MethodGet, "/messages/stats?created_from=2025-12-01T00:00:00Z", nil)
+	w := httptest.NewRecorder()
+
+	h.GetMessageStats(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), `"expired":1`) {
+		t.Errorf("unexpected body: %s", w.Body.String())
+	}
+	if mockSvc.gotFilter.CreatedFrom.IsZero() {
+		t.Error("expected created_from to be passed to the service")
 	}
 }
@@ -32,6 +32,8 @@ func (m *MockScheduler) IsRunning() bool {
 	return m.runningState
 }
 
+func (m *MockScheduler) Trigger() {}
+
 func TestToggleScheduler_Multiple(t *testing.T) {
 	mock := &MockScheduler{}
 	h := handler.NewSchedulerHandler(mock)
@@ -6,6 +6,7 @@ import (
 	"go.uber.org/fx"
 
 	"github.com/lazerion/outbox-relayer/internal/api/handler"
+	"github.com/lazerion/outbox-relayer/internal/config"
 	"github.com/lazerion/outbox-relayer/internal/schedule"
 	"github.com/lazerion/outbox-relayer/internal/service"
 )
@@ -20,14 +21,26 @@ var Module = fx.Module(
 		func(s schedule.SchedulerInterface) *handler.SchedulerHandler {
 			return handler.NewSchedulerHandler(s)
 		},
+		func(s service.MessageServiceInterface) *handler.MessageHandler {
+			return handler.NewMessageHandler(s)
+		},
+		func(s service.DeliveryServiceInterface, cfg *config.Config) *handler.DeliveryHandler {
+			return handler.NewDeliveryHandler(s, cfg.DeliveryReceipts.Secret)
+		},
+		func(s service.CallbackServiceInterface) *handler.CallbackHandler {
+			return handler.NewCallbackHandler(s)
+		},
 	),
 
 	fx.Provide(
 		func(
 			schedHandler *handler.SchedulerHandler,
 			queryHandler *handler.QueryHandler,
+			messageHandler *handler.MessageHandler,
+			deliveryHandler *handler.DeliveryHandler,
+			callbackHandler *handler.CallbackHandler,
 		) http.Handler {
-			return NewRouter(schedHandler, queryHandler)
+			return NewRouter(schedHandler, queryHandler, messageHandler, deliveryHandler, callbackHandler)
 		},
 	),
 )
@@ -12,6 +12,9 @@ import (
 func NewRouter(
 	schedHandler *handler.SchedulerHandler,
 	queryHandler *handler.QueryHandler,
+	messageHandler *handler.MessageHandler,
+	deliveryHandler *handler.DeliveryHandler,
+	callbackHandler *handler.CallbackHandler,
 ) http.Handler {
 
 	r := mux.NewRouter()
@@ -25,9 +28,47 @@ func NewRouter(
 	v1.HandleFunc("/scheduler/toggle", schedHandler.ToggleScheduler).
 		Methods(http.MethodPost)
 
+	// Message endpoints
+	v1.HandleFunc("/messages", messageHandler.EnqueueMessage).
+		Methods(http.MethodPost)
+	v1.HandleFunc("/messages/import", messageHandler.ImportMessages).
+		Methods(http.MethodPost)
+	v1.HandleFunc("/messages/replay", messageHandler.ReplayMessages).
+		Methods(http.MethodPost)
+	v1.HandleFunc("/messages/cancel", messageHandler.CancelMessages).
+		Methods(http.MethodPost)
+	v1.HandleFunc("/messages/{id:[0-9]+}", messageHandler.EditMessage).
+		Methods(http.MethodPatch)
+	v1.HandleFunc("/messages/{id:[0-9]+}/cancel", messageHandler.CancelMessage).
+		Methods(http.MethodPost)
+
 	// Query endpoints
+	v1.HandleFunc("/messages", queryHandler.SearchMessages).
+		Methods(http.MethodGet)
 	v1.HandleFunc("/messages/sent", queryHandler.ListSentMessages).
 		Methods(http.MethodGet)
+	v1.HandleFunc("/messages/stats", queryHandler.GetMessageStats).
+		Methods(http.MethodGet)
+	v1.HandleFunc("/messages/{id:[0-9]+}", queryHandler.GetMessage).
+		Methods(http.MethodGet)
+	v1.HandleFunc("/messages/{id:[0-9]+}/replays", queryHandler.GetMessageReplays).
+		Methods(http.MethodGet)
+	v1.HandleFunc("/messages/{id:[0-9]+}/attempts", queryHandler.GetMessageAttempts).
+		Methods(http.MethodGet)
+	v1.HandleFunc("/messages/by-external/{externalId}", queryHandler.GetMessageByExternalID).
+		Methods(http.MethodGet)
+
+	// Producer callbacks
+	v1.HandleFunc("/clients/{clientId}/callback", callbackHandler.SetClientCallback).
+		Methods(http.MethodPut)
+	v1.HandleFunc("/clients/{clientId}/callback", callbackHandler.GetClientCallback).
+		Methods(http.MethodGet)
+	v1.HandleFunc("/clients/{clientId}/callback", callbackHandler.DeleteClientCallback).
+		Methods(http.MethodDelete)
+
+	// Gateway callbacks
+	v1.HandleFunc("/delivery-receipts", deliveryHandler.ReceiveDeliveryReceipt).
+		Methods(http.MethodPost)
 
 	// Swagger endpoint
 	v1.PathPrefix("/swagger/").Handler(httpSwagger.WrapHandler)
@@ -2,16 +2,20 @@ package cache
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
+	"github.com/lazerion/outbox-relayer/internal/model"
 	"github.com/lazerion/outbox-relayer/internal/service"
 	"github.com/redis/go-redis/v9"
 )
 
 type MessageCache interface {
-	CacheMessage(ctx context.Context, messageID string, sentAt time.Time) error
+	CacheMessage(ctx context.Context, msg model.Message) error
+	GetMessage(ctx context.Context, externalID string) (*model.Message, error)
 	StartConsumer(ctx context.Context, cacheCh <-chan service.SentMessageEvent)
 }
 
@@ -34,7 +38,7 @@ func (r *RedisMessageCache) StartConsumer(ctx context.Context, cacheCh <-chan se
 				if !ok {
 					return
 				}
-				if err := r.CacheMessage(ctx, evt.MessageID, evt.SentAt); err != nil {
+				if err := r.CacheMessage(ctx, evt.Message); err != nil {
 					log.Printf("failed to cache message %s: %v", evt.MessageID, err)
 				}
 			}
@@ -42,7 +46,69 @@ func (r *RedisMessageCache) StartConsumer(ctx context.Context, cacheCh <-chan se
 	}()
 }
 
-func (r *RedisMessageCache) CacheMessage(ctx context.Context, messageID string, sentAt time.Time) error {
-	key := fmt.Sprintf("message:%s", messageID)
-	return r.client.Set(ctx, key, sentAt.Format(time.RFC3339), r.ttl).Err()
+func messageKey(externalID string) string {
+	return fmt.Sprintf("message:%s", externalID)
+}
+
+// cacheWriteRetries bounds how often a write is retried when the entry changes while it is being compared
+const cacheWriteRetries = 3
+
+// CacheMessage stores the message as JSON under its gateway assigned ID. Writes are ordered by status: an entry
+// whose status the message could not have moved on from to msg.Status is newer and kept, so a late sent event
+// does not overwrite a delivery receipt that was cached first.
+func (r *RedisMessageCache) CacheMessage(ctx context.Context, msg model.Message) error {
+	if msg.ExternalID == "" {
+		return fmt.Errorf("message %d has no external ID", msg.ID)
+	}
+
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	key := messageKey(msg.ExternalID)
+	write := func(tx *redis.Tx) error {
+		cached, err := tx.Get(ctx, key).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			var prev model.Message
+			if json.Unmarshal(cached, &prev) == nil && prev.Status != msg.Status &&
+				!prev.Status.CanTransitionTo(msg.Status) {
+				return nil
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, payload, r.ttl)
+			return nil
+		})
+		return err
+	}
+
+	for range cacheWriteRetries {
+		err = r.client.Watch(ctx, write, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return err
+}
+
+// GetMessage returns the cached message or service.ErrCacheMiss
+func (r *RedisMessageCache) GetMessage(ctx context.Context, externalID string) (*model.Message, error) {
+	payload, err := r.client.Get(ctx, messageKey(externalID)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, service.ErrCacheMiss
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var msg model.Message
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		// entries written in an older format are treated as absent
+		return nil, service.ErrCacheMiss
+	}
+	return &msg, nil
 }
@@ -2,6 +2,8 @@ package cache_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"testing"
 	"time"
 
@@ -9,6 +11,7 @@ import (
 	"github.com/redis/go-redis/v9"
 
 	"github.com/lazerion/outbox-relayer/internal/cache"
+	"github.com/lazerion/outbox-relayer/internal/model"
 	"github.com/lazerion/outbox-relayer/internal/service"
 )
 
@@ -28,6 +31,32 @@ func newTestRedis(t *testing.T) (*miniredis.Miniredis, *redis.Client) {
 	return s, rdb
 }
 
+func sentMessage(externalID string, sentAt time.Time) model.Message {
+	return model.Message{
+		ID:          1,
+		PhoneNumber: "+123456789",
+		Content:     "hello",
+		Status:      model.StatusSent,
+		ExternalID:  externalID,
+		SentTime:    sentAt,
+	}
+}
+
+func getCached(t *testing.T, rdb *redis.Client, key string) model.Message {
+	t.Helper()
+
+	v, err := rdb.Get(context.Background(), key).Bytes()
+	if err != nil {
+		t.Fatalf("redis GET failed: %v", err)
+	}
+
+	var msg model.Message
+	if err := json.Unmarshal(v, &msg); err != nil {
+		t.Fatalf("unexpected redis value %s: %v", v, err)
+	}
+	return msg
+}
+
 func TestCacheMessage(t *testing.T) {
 	s, rdb := newTestRedis(t)
 	defer s.Close()
@@ -36,18 +65,97 @@ func TestCacheMessage(t *testing.T) {
 
 	ctx := context.Background()
 	sentAt := time.Now().UTC()
-	err := mc.CacheMessage(ctx, "abc123", sentAt)
+	err := mc.CacheMessage(ctx, sentMessage("abc123", sentAt))
 	if err != nil {
 		t.Fatalf("CacheMessage failed: %v", err)
 	}
 
-	v, err := rdb.Get(ctx, "message:abc123").Result()
+	got := getCached(t, rdb, "message:abc123")
+	if got.ExternalID != "abc123" || !got.SentTime.Equal(sentAt) {
+		t.Fatalf("unexpected cached message: %+v", got)
+	}
+
+	if ttl := s.TTL("message:abc123"); ttl != 5*time.Minute {
+		t.Fatalf("unexpected ttl: %v", ttl)
+	}
+}
+
+func TestCacheMessage_KeepsNewerStatus(t *testing.T) {
+	s, rdb := newTestRedis(t)
+	defer s.Close()
+
+	mc := cache.NewRedisMessageCache(rdb, 5*time.Minute)
+
+	ctx := context.Background()
+	sent := sentMessage("abc123", time.Now().UTC())
+	delivered := sent
+	delivered.Status = model.StatusDelivered
+
+	if err := mc.CacheMessage(ctx, sent); err != nil {
+		t.Fatalf("CacheMessage failed: %v", err)
+	}
+	if err := mc.CacheMessage(ctx, delivered); err != nil {
+		t.Fatalf("CacheMessage failed: %v", err)
+	}
+	// the sent event arriving after the receipt is dropped
+	if err := mc.CacheMessage(ctx, sent); err != nil {
+		t.Fatalf("CacheMessage failed: %v", err)
+	}
+
+	if got := getCached(t, rdb, "message:abc123"); got.Status != model.StatusDelivered {
+		t.Fatalf("expected cached status delivered, got %s", got.Status)
+	}
+}
+
+func TestCacheMessage_RequiresExternalID(t *testing.T) {
+	s, rdb := newTestRedis(t)
+	defer s.Close()
+
+	mc := cache.NewRedisMessageCache(rdb, 5*time.Minute)
+
+	if err := mc.CacheMessage(context.Background(), model.Message{ID: 1}); err == nil {
+		t.Fatal("expected error for message without external ID")
+	}
+}
+
+func TestGetMessage(t *testing.T) {
+	s, rdb := newTestRedis(t)
+	defer s.Close()
+
+	mc := cache.NewRedisMessageCache(rdb, 5*time.Minute)
+	ctx := context.Background()
+
+	if _, err := mc.GetMessage(ctx, "missing"); !errors.Is(err, service.ErrCacheMiss) {
+		t.Fatalf("expected ErrCacheMiss, got %v", err)
+	}
+
+	sentAt := time.Now().UTC()
+	if err := mc.CacheMessage(ctx, sentMessage("abc123", sentAt)); err != nil {
+		t.Fatalf("CacheMessage failed: %v", err)
+	}
+
+	got, err := mc.GetMessage(ctx, "abc123")
 	if err != nil {
-		t.Fatalf("redis GET failed: %v", err)
+		t.Fatalf("GetMessage failed: %v", err)
+	}
+	if got.ID != 1 || got.Status != model.StatusSent || !got.SentTime.Equal(sentAt) {
+		t.Fatalf("unexpected cached message: %+v", got)
 	}
+}
+
+func TestGetMessage_LegacyValueIsMiss(t *testing.T) {
+	s, rdb := newTestRedis(t)
+	defer s.Close()
 
-	if v != sentAt.Format(time.RFC3339) {
-		t.Fatalf("unexpected redis value: got %s want %s", v, sentAt.Format(time.RFC3339))
+	mc := cache.NewRedisMessageCache(rdb, 5*time.Minute)
+
+	// values written before messages were cached as JSON only held the sent timestamp
+	if err := s.Set("message:old", time.Now().UTC().Format(time.RFC3339)); err != nil {
+		t.Fatalf("miniredis SET failed: %v", err)
+	}
+
+	if _, err := mc.GetMessage(context.Background(), "old"); !errors.Is(err, service.ErrCacheMiss) {
+		t.Fatalf("expected ErrCacheMiss, got %v", err)
 	}
 }
 
@@ -67,17 +175,14 @@ func TestStartConsumer_ConsumesEvents(t *testing.T) {
 	cacheCh <- service.SentMessageEvent{
 		MessageID: "xyz789",
 		SentAt:    sentAt,
+		Message:   sentMessage("xyz789", sentAt),
 	}
 
 	time.Sleep(50 * time.Millisecond)
 
-	v, err := rdb.Get(ctx, "message:xyz789").Result()
-	if err != nil {
-		t.Fatalf("redis GET failed: %v", err)
-	}
-
-	if v != sentAt.Format(time.RFC3339) {
-		t.Fatalf("unexpected redis value: got %s want %s", v, sentAt.Format(time.RFC3339))
+	got := getCached(t, rdb, "message:xyz789")
+	if !got.SentTime.Equal(sentAt) {
+		t.Fatalf("unexpected cached message: %+v", got)
 	}
 }
 
@@ -97,6 +202,7 @@ func TestStartConsumer_StopsOnContextCancel(t *testing.T) {
 	cacheCh <- service.SentMessageEvent{
 		MessageID: "should_not_write",
 		SentAt:    time.Now().UTC(),
+		Message:   sentMessage("should_not_write", time.Now().UTC()),
 	}
 
 	time.Sleep(50 * time.Millisecond)
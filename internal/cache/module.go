@@ -29,6 +29,11 @@ func NewMessageCacheProvider(redis *redis.Client, cfg *config.Config) MessageCac
 	return NewRedisMessageCache(redis, cfg.Redis.TTL)
 }
 
+// NewReadThroughCacheProvider exposes the cache to the query service for external ID lookups
+func NewReadThroughCacheProvider(cache MessageCache) service.MessageCache {
+	return cache
+}
+
 func StartCacheConsumer(lc fx.Lifecycle, cacheCh chan service.SentMessageEvent, cache MessageCache) {
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -48,6 +53,7 @@ var Module = fx.Module(
 	fx.Provide(
 		NewRedisClient,
 		NewMessageCacheProvider,
+		NewReadThroughCacheProvider,
 	),
 	fx.Invoke(StartCacheConsumer),
 )
@@ -0,0 +1,25 @@
+package callback
+
+import (
+	"log"
+
+	"go.uber.org/fx"
+
+	"github.com/lazerion/outbox-relayer/internal/config"
+)
+
+// NewHTTPNotifierProvider returns nil when no secret is configured, since notifications are never sent unsigned
+func NewHTTPNotifierProvider(cfg *config.Config) Notifier {
+	if cfg.Callbacks.Secret == "" {
+		log.Println("callbacks.secret is not configured, status callbacks stay queued until it is set")
+		return nil
+	}
+	return NewHTTPNotifier(cfg.Callbacks.Secret, cfg.Callbacks.Timeout)
+}
+
+var Module = fx.Module(
+	"callback",
+	fx.Provide(
+		NewHTTPNotifierProvider,
+	),
+)
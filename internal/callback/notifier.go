@@ -0,0 +1,98 @@
+package callback
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/signature"
+)
+
+// ErrNoSecret is returned instead of sending a notification that could not be signed
+var ErrNoSecret = errors.New("callbacks.secret is not configured")
+
+// Notification is the JSON body pushed to a producer's callback URL when one of its messages changes status.
+// EventID is unique per status change, so producers can drop notifications delivered more than once.
+type Notification struct {
+	EventID        int64               `json:"event_id"`
+	MessageID      int64               `json:"message_id"`
+	Status         model.MessageStatus `json:"status"`
+	PreviousStatus model.MessageStatus `json:"previous_status"`
+	ExternalID     string              `json:"external_id,omitempty"`
+	LastError      string              `json:"last_error,omitempty"`
+	FailureReason  model.FailureReason `json:"failure_reason,omitempty"`
+	OccurredAt     time.Time           `json:"occurred_at"`
+}
+
+// NewNotification builds the notification of an outbox event
+func NewNotification(e model.CallbackEvent) Notification {
+	return Notification{
+		EventID:        e.ID,
+		MessageID:      e.MessageID,
+		Status:         e.Status,
+		PreviousStatus: e.PreviousStatus,
+		ExternalID:     e.ExternalID,
+		LastError:      e.LastError,
+		FailureReason:  e.FailureReason,
+		OccurredAt:     e.OccurredAt,
+	}
+}
+
+// Notifier pushes status change notifications to producers
+type Notifier interface {
+	Notify(ctx context.Context, url string, n Notification) error
+}
+
+// HTTPNotifier POSTs notifications as JSON, signed with a secret shared with the producers
+type HTTPNotifier struct {
+	Client *http.Client
+	// Secret signs the body into the X-Signature header; nothing is sent while it is empty
+	Secret string
+}
+
+func NewHTTPNotifier(secret string, timeout time.Duration) Notifier {
+	return &HTTPNotifier{
+		Client: &http.Client{
+			Timeout: timeout,
+		},
+		Secret: secret,
+	}
+}
+
+// Notify delivers n to url; any response other than 2xx is an error
+func (s *HTTPNotifier) Notify(ctx context.Context, url string, n Notification) error {
+	if s.Secret == "" {
+		return ErrNoSecret
+	}
+
+	body, err := json.Marshal(n)
+	if err != nil {
+		return fmt.Errorf("failed to marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signature.Header, signature.Sign(s.Secret, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute request: %w", err)
+	}
+	defer resp.Body.Close()
+	// drained so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+	return nil
+}
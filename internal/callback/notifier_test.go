@@ -0,0 +1,82 @@
+package callback_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/callback"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/signature"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPNotifier_Notify(t *testing.T) {
+	occurred := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	n := callback.NewNotification(model.CallbackEvent{
+		ID: 9, MessageID: 4, URL: "ignored", Status: model.StatusFailed, PreviousStatus: model.StatusPending,
+		LastError: "unexpected status code: 400", FailureReason: model.FailureUnrecoverable, OccurredAt: occurred,
+	})
+
+	tests := []struct {
+		name        string
+		secret      string
+		status      int
+		expectedErr string
+	}{
+		{name: "signed", secret: "s3cret", status: http.StatusOK},
+		{name: "no content", secret: "s3cret", status: http.StatusNoContent},
+		{name: "not sent without secret", status: http.StatusOK, expectedErr: callback.ErrNoSecret.Error()},
+		{name: "rejected", secret: "s3cret", status: http.StatusServiceUnavailable, expectedErr: "unexpected status code: 503"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				body   []byte
+				sig    string
+				called bool
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				called = true
+				body, _ = io.ReadAll(r.Body)
+				sig = r.Header.Get(signature.Header)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			err := callback.NewHTTPNotifier(tt.secret, time.Second).Notify(context.Background(), server.URL, n)
+			if tt.expectedErr != "" {
+				require.EqualError(t, err, tt.expectedErr)
+				require.Equal(t, tt.secret != "", called, "unsigned notifications must not be sent")
+				return
+			}
+			require.NoError(t, err)
+			require.True(t, signature.Verify(tt.secret, body, sig))
+
+			var got map[string]any
+			require.NoError(t, json.Unmarshal(body, &got))
+			require.Equal(t, map[string]any{
+				"event_id":        float64(9),
+				"message_id":      float64(4),
+				"status":          "failed",
+				"previous_status": "pending",
+				"last_error":      "unexpected status code: 400",
+				"failure_reason":  "unrecoverable_error",
+				"occurred_at":     "2026-01-02T03:04:05Z",
+			}, got)
+		})
+	}
+}
+
+func TestHTTPNotifier_Notify_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	err := callback.NewHTTPNotifier("s3cret", time.Second).Notify(context.Background(), server.URL, callback.Notification{})
+	require.ErrorContains(t, err, "failed to execute request")
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
@@ -16,20 +17,93 @@ type PostgresConfig struct {
 	Database string `mapstructure:"database"`
 }
 
+// DSN returns the connection string for lib/pq
+func (c PostgresConfig) DSN() string {
+	return fmt.Sprintf(
+		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		c.Host,
+		c.Port,
+		c.User,
+		c.Password,
+		c.Database,
+	)
+}
+
 type RelayerConfig struct {
 	Batch       int           `mapstructure:"batch"`
 	Timeout     time.Duration `mapstructure:"timeout"`
 	MaxAttempts int           `mapstructure:"maxAttempts"`
+	// MaxAttemptsByError overrides MaxAttempts for recoverable errors of the given class
+	// (timeout, rate_limited, server_error, connection_refused, other)
+	MaxAttemptsByError map[string]int `mapstructure:"maxAttemptsByError"`
+	// DefaultTTL expires messages enqueued without expires_at; zero keeps them until sent
+	DefaultTTL time.Duration `mapstructure:"defaultTtl"`
+	// PriorityWeights maps low/normal/high to their share of each batch
+	PriorityWeights map[string]int `mapstructure:"priorityWeights"`
+	Backoff         BackoffConfig  `mapstructure:"backoff"`
+	// Lease is how long a claimed batch is reserved for one relayer instance; zero uses the default.
+	// It must cover sending a batch: Timeout × ⌈Batch / Concurrency⌉ plus a 5s margin.
+	Lease time.Duration `mapstructure:"lease"`
+	// Concurrency is how many messages of a batch are sent at the same time
+	Concurrency int `mapstructure:"concurrency"`
+	// Drain keeps relaying batches back-to-back while the queue is not empty, up to MaxBatchesPerRun per run;
+	// what is left after the cap waits for the next tick
+	Drain            bool `mapstructure:"drain"`
+	MaxBatchesPerRun int  `mapstructure:"maxBatchesPerRun"`
+}
+
+// BackoffConfig controls the delay between retries of a message failing with a recoverable error
+type BackoffConfig struct {
+	Base       time.Duration `mapstructure:"base"`
+	Multiplier float64       `mapstructure:"multiplier"`
+	MaxDelay   time.Duration `mapstructure:"maxDelay"`
+	// Jitter shortens each delay by a random fraction of up to this value
+	Jitter float64 `mapstructure:"jitter"`
 }
 
 type WebhookConfig struct {
 	Url     string        `mapstructure:"url"`
 	AuthKey string        `mapstructure:"authKey"`
 	Timeout time.Duration `mapstructure:"timeout"`
+	// StatusUrl is the gateway's delivery lookup, queried with the delivery key appended; empty if it has none
+	StatusUrl string `mapstructure:"statusUrl"`
 }
 
 type ScheduleConfig struct {
 	Interval time.Duration `mapstructure:"interval"`
+	// Listen runs the job as soon as Postgres notifies about new messages; polling at Interval stays as a fallback
+	Listen bool `mapstructure:"listen"`
+	// Debounce coalesces notifications arriving within this window into a single run
+	Debounce time.Duration `mapstructure:"debounce"`
+}
+
+// ReconcilerConfig controls the job resolving messages whose send has an unknown outcome
+type ReconcilerConfig struct {
+	Interval time.Duration `mapstructure:"interval"`
+	Batch    int           `mapstructure:"batch"`
+	// MaxChecks is how many status lookups of a message may fail before it is marked failed, 10 when unset
+	MaxChecks int `mapstructure:"maxChecks"`
+}
+
+// DeliveryReceiptConfig authenticates the delivery receipts the gateway posts back
+type DeliveryReceiptConfig struct {
+	// Secret is shared with the gateway to sign every receipt; receipts are rejected while it is empty
+	Secret string `mapstructure:"secret"`
+}
+
+// CallbackConfig controls pushing message status changes to the callback URLs of producers
+type CallbackConfig struct {
+	// Secret signs every notification; nothing is sent while it is empty and status changes stay queued
+	Secret      string        `mapstructure:"secret"`
+	Interval    time.Duration `mapstructure:"interval"`
+	Batch       int           `mapstructure:"batch"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	Concurrency int           `mapstructure:"concurrency"`
+	// MaxAttempts is how often a notification is tried before it is given up on
+	MaxAttempts int           `mapstructure:"maxAttempts"`
+	Backoff     BackoffConfig `mapstructure:"backoff"`
+	// Lease is how long a claimed batch is reserved for one dispatcher; zero uses the default
+	Lease time.Duration `mapstructure:"lease"`
 }
 
 type Migration struct {
@@ -44,13 +118,22 @@ type RedisConfig struct {
 	TTL      time.Duration `mapstructure:"ttl"`
 }
 
+type IdempotencyConfig struct {
+	KeyTTL time.Duration `mapstructure:"keyTtl"`
+}
+
 type Config struct {
-	Postgres  PostgresConfig `mapstructure:"postgres"`
-	Relayer   RelayerConfig  `mapstructure:"relayer"`
-	Webhook   WebhookConfig  `mapstructure:"webhook"`
-	Schedule  ScheduleConfig `mapstructure:"schedule"`
-	Migration Migration      `mapstructure:"migration"`
-	Redis     RedisConfig    `mapstructure:"redis"`
+	Postgres   PostgresConfig   `mapstructure:"postgres"`
+	Relayer    RelayerConfig    `mapstructure:"relayer"`
+	Webhook    WebhookConfig    `mapstructure:"webhook"`
+	Schedule   ScheduleConfig   `mapstructure:"schedule"`
+	Reconciler ReconcilerConfig `mapstructure:"reconciler"`
+	// DeliveryReceipts configures the delivery receipt callback
+	DeliveryReceipts DeliveryReceiptConfig `mapstructure:"deliveryReceipts"`
+	Callbacks        CallbackConfig        `mapstructure:"callbacks"`
+	Migration        Migration             `mapstructure:"migration"`
+	Redis            RedisConfig           `mapstructure:"redis"`
+	Idempotency      IdempotencyConfig     `mapstructure:"idempotency"`
 }
 
 func LoadConfig() (*Config, error) {
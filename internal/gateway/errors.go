@@ -1,9 +1,12 @@
 package gateway
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"syscall"
 )
 
 // UpstreamError wraps an error from the SMS gateway with context about recoverability
@@ -11,6 +14,9 @@ type UpstreamError struct {
 	Err         error
 	StatusCode  int
 	Recoverable bool
+	// Ambiguous is set when the request reached the gateway but its outcome is unknown,
+	// e.g. the response timed out, so the message may have been accepted
+	Ambiguous bool
 }
 
 func (e *UpstreamError) Error() string {
@@ -20,6 +26,10 @@ func (e *UpstreamError) Error() string {
 	return fmt.Sprintf("upstream error: %v", e.Err)
 }
 
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
 // IsRecoverable checks whether an error can be retried
 func IsRecoverable(err error) bool {
 	if err == nil {
@@ -34,6 +44,55 @@ func IsRecoverable(err error) bool {
 	return true
 }
 
+// IsAmbiguous checks whether an error leaves it open if the gateway accepted the message
+func IsAmbiguous(err error) bool {
+	var ue *UpstreamError
+	return errors.As(err, &ue) && ue.Ambiguous
+}
+
+// ErrorClass groups send failures so retry limits can differ per kind of failure
+type ErrorClass string
+
+const (
+	ErrorClassTimeout           ErrorClass = "timeout"
+	ErrorClassRateLimited       ErrorClass = "rate_limited"
+	ErrorClassServerError       ErrorClass = "server_error"
+	ErrorClassConnectionRefused ErrorClass = "connection_refused"
+	ErrorClassOther             ErrorClass = "other"
+)
+
+// ErrorClasses lists the classes a retry policy can be configured for
+var ErrorClasses = []ErrorClass{
+	ErrorClassTimeout,
+	ErrorClassRateLimited,
+	ErrorClassServerError,
+	ErrorClassConnectionRefused,
+	ErrorClassOther,
+}
+
+// Classify returns the class of a send error
+func Classify(err error) ErrorClass {
+	var ue *UpstreamError
+	if errors.As(err, &ue) && ue.StatusCode > 0 {
+		switch {
+		case ue.StatusCode == http.StatusTooManyRequests:
+			return ErrorClassRateLimited
+		case ue.StatusCode >= http.StatusInternalServerError:
+			return ErrorClassServerError
+		}
+		return ErrorClassOther
+	}
+
+	var netErr net.Error
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ErrorClassConnectionRefused
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ErrorClassTimeout
+	}
+	return ErrorClassOther
+}
+
 func isStatusRecoverable(code int) bool {
 	return code == http.StatusTooManyRequests ||
 		(code >= http.StatusInternalServerError &&
@@ -48,3 +107,13 @@ func WrapUpstreamError(err error, statusCode int) *UpstreamError {
 		Recoverable: isStatusRecoverable(statusCode),
 	}
 }
+
+// WrapTransportError creates an UpstreamError for a request that got no response;
+// timeouts and refused connections are transient and therefore recoverable
+func WrapTransportError(err error) *UpstreamError {
+	class := Classify(err)
+	return &UpstreamError{
+		Err:         err,
+		Recoverable: class == ErrorClassTimeout || class == ErrorClassConnectionRefused,
+	}
+}
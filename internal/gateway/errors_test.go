@@ -1,8 +1,10 @@
 package gateway_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"syscall"
 	"testing"
 
 	"github.com/lazerion/outbox-relayer/internal/gateway"
@@ -75,6 +77,30 @@ func TestIsRecoverable(t *testing.T) {
 	}
 }
 
+func TestIsAmbiguous(t *testing.T) {
+	ambiguous := &gateway.UpstreamError{Err: errors.New("timeout"), Recoverable: true, Ambiguous: true}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil error", nil, false},
+		{"plain error", errors.New("boom"), false},
+		{"unambiguous UpstreamError", &gateway.UpstreamError{Err: errors.New("refused"), Recoverable: true}, false},
+		{"ambiguous UpstreamError", ambiguous, true},
+		{"wrapped ambiguous UpstreamError", fmt.Errorf("send: %w", ambiguous), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gateway.IsAmbiguous(tt.err); got != tt.want {
+				t.Errorf("IsAmbiguous() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestWrapUpstreamError(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -103,3 +129,38 @@ func TestWrapUpstreamError(t *testing.T) {
 		})
 	}
 }
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want gateway.ErrorClass
+	}{
+		{"429", gateway.WrapUpstreamError(errors.New("slow down"), 429), gateway.ErrorClassRateLimited},
+		{"503", gateway.WrapUpstreamError(errors.New("unavailable"), 503), gateway.ErrorClassServerError},
+		{"400", gateway.WrapUpstreamError(errors.New("bad request"), 400), gateway.ErrorClassOther},
+		{"deadline", gateway.WrapTransportError(fmt.Errorf("execute: %w", context.DeadlineExceeded)), gateway.ErrorClassTimeout},
+		{"connection refused", gateway.WrapTransportError(fmt.Errorf("dial: %w", syscall.ECONNREFUSED)), gateway.ErrorClassConnectionRefused},
+		{"plain error", errors.New("boom"), gateway.ErrorClassOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := gateway.Classify(tt.err); got != tt.want {
+				t.Errorf("Classify() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrapTransportError(t *testing.T) {
+	if !gateway.IsRecoverable(gateway.WrapTransportError(context.DeadlineExceeded)) {
+		t.Error("timeouts should be recoverable")
+	}
+	if !gateway.IsRecoverable(gateway.WrapTransportError(syscall.ECONNREFUSED)) {
+		t.Error("refused connections should be recoverable")
+	}
+	if gateway.IsRecoverable(gateway.WrapTransportError(context.Canceled)) {
+		t.Error("cancellation should not be recoverable")
+	}
+}
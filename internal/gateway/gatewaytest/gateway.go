@@ -0,0 +1,118 @@
+// Package gatewaytest provides an in-memory SMS gateway for tests.
+package gatewaytest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+
+	"github.com/lazerion/outbox-relayer/internal/gateway"
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+// Gateway implements gateway.Sender and gateway.StatusChecker in memory. Like a real gateway it accepts every
+// delivery key once; sending the same message again answers with the external ID it was first accepted with,
+// while a different recipient or content under a key already used is rejected with a conflict.
+type Gateway struct {
+	mu          sync.Mutex
+	accepted    map[string]string
+	payloads    map[string]model.Message
+	sends       []model.Message
+	dropReplies bool
+	statusErr   error
+}
+
+func New() *Gateway {
+	return &Gateway{accepted: make(map[string]string), payloads: make(map[string]model.Message)}
+}
+
+// Send accepts the message under its delivery key. While replies are dropped the message is still accepted,
+// but Send fails with an ambiguous timeout as if the response never made it back.
+func (g *Gateway) Send(ctx context.Context, message model.Message) (*gateway.SendResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, gateway.WrapTransportError(err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.sends = append(g.sends, message)
+
+	key := gateway.DeliveryKey(message)
+	externalID, duplicate := g.accepted[key]
+	if duplicate {
+		if p := g.payloads[key]; p.PhoneNumber != message.PhoneNumber || p.Content != message.Content {
+			return nil, gateway.WrapUpstreamError(
+				fmt.Errorf("unexpected status code: %d", http.StatusConflict), http.StatusConflict)
+		}
+	} else {
+		externalID = fmt.Sprintf("fake-%d", len(g.accepted)+1)
+		g.accepted[key] = externalID
+		g.payloads[key] = message
+	}
+
+	if g.dropReplies {
+		ue := gateway.WrapTransportError(fmt.Errorf("failed to execute request: %w", context.DeadlineExceeded))
+		ue.Ambiguous = true
+		return nil, ue
+	}
+	return &gateway.SendResponse{
+		MessageID:  externalID,
+		Message:    "accepted",
+		StatusCode: 202,
+		Duplicate:  duplicate,
+	}, nil
+}
+
+// CheckStatus reports whether a message was accepted under the delivery key
+func (g *Gateway) CheckStatus(ctx context.Context, deliveryKey string) (*gateway.DeliveryStatus, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, gateway.WrapTransportError(err)
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.statusErr != nil {
+		return nil, g.statusErr
+	}
+
+	externalID, ok := g.accepted[deliveryKey]
+	return &gateway.DeliveryStatus{Found: ok, MessageID: externalID}, nil
+}
+
+// DropReplies makes the following sends lose their response after the message was accepted
+func (g *Gateway) DropReplies(drop bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.dropReplies = drop
+}
+
+// FailStatus makes CheckStatus return err; nil lets it answer again
+func (g *Gateway) FailStatus(err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.statusErr = err
+}
+
+// Accepted returns the external ID the message was accepted with under its current delivery key, if any
+func (g *Gateway) Accepted(message model.Message) (string, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	externalID, ok := g.accepted[gateway.DeliveryKey(message)]
+	return externalID, ok
+}
+
+// Sends returns how often Send was called, including sends answered as duplicates or rejected
+func (g *Gateway) Sends() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.sends)
+}
+
+// Sent returns every message passed to Send, in order
+func (g *Gateway) Sent() []model.Message {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return slices.Clone(g.sends)
+}
@@ -14,7 +14,22 @@ func NewWebhookSenderProvider(cfg *config.Config) Sender {
 	)
 }
 
+// NewWebhookStatusCheckerProvider returns nil when no status endpoint is configured
+func NewWebhookStatusCheckerProvider(cfg *config.Config) StatusChecker {
+	if cfg.Webhook.StatusUrl == "" {
+		return nil
+	}
+	return NewWebhookStatusChecker(
+		cfg.Webhook.StatusUrl,
+		cfg.Webhook.AuthKey,
+		cfg.Webhook.Timeout,
+	)
+}
+
 var Module = fx.Module(
 	"webhook.site",
-	fx.Provide(NewWebhookSenderProvider),
+	fx.Provide(
+		NewWebhookSenderProvider,
+		NewWebhookStatusCheckerProvider,
+	),
 )
@@ -6,14 +6,67 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/lazerion/outbox-relayer/internal/model"
 )
 
+const (
+	// IdempotencyKeyHeader carries the delivery key, so the gateway can recognize a message it already accepted
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to true by the gateway when it answers with the response of an earlier request
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
 type SendResponse struct {
 	MessageID string `json:"messageId"`
 	Message   string `json:"message"`
+	// StatusCode is the HTTP status of the gateway response
+	StatusCode int `json:"-"`
+	// Duplicate is set when the gateway already accepted the message under the same delivery key;
+	// MessageID is then the external ID it was originally accepted with
+	Duplicate bool `json:"-"`
+}
+
+// Accepted reports whether the gateway took the message, either now or with an earlier attempt
+func (r *SendResponse) Accepted() bool {
+	return r.Duplicate || strings.EqualFold(r.Message, "accepted")
+}
+
+// DeliveryKey is the idempotency key of a message. It depends on the message ID and its delivery generation, so
+// every attempt to deliver the same message carries the same key, also after a crash or a failed update made the
+// relayer send it again. Replaying or editing a message bumps the generation, so the changed message is not
+// answered with the response the gateway stored for an earlier attempt. Generation 0 keeps the original
+// "message-<id>" form.
+func DeliveryKey(m model.Message) string {
+	key := "message-" + strconv.FormatInt(m.ID, 10)
+	if m.DeliveryGeneration > 0 {
+		key += "-" + strconv.Itoa(m.DeliveryGeneration)
+	}
+	return key
+}
+
+// ParseDeliveryKey returns the message ID and delivery generation a key built by DeliveryKey stands for
+func ParseDeliveryKey(key string) (id int64, generation int, ok bool) {
+	rest, found := strings.CutPrefix(key, "message-")
+	if !found {
+		return 0, 0, false
+	}
+	rawID, rawGen, hasGen := strings.Cut(rest, "-")
+	id, err := strconv.ParseInt(rawID, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, 0, false
+	}
+	if hasGen {
+		if generation, err = strconv.Atoi(rawGen); err != nil || generation <= 0 {
+			return 0, 0, false
+		}
+	}
+	return id, generation, true
 }
 
 // Sender defines the contract for sending messages to an external SMS Gateway.
@@ -21,6 +74,20 @@ type Sender interface {
 	Send(ctx context.Context, message model.Message) (*SendResponse, error)
 }
 
+// DeliveryStatus is what the gateway knows about a delivery key
+type DeliveryStatus struct {
+	// Found is set when the gateway accepted a message under the key
+	Found bool
+	// MessageID is the external ID the message was accepted with
+	MessageID string
+}
+
+// StatusChecker asks the SMS Gateway whether it accepted a message, by the message's delivery key.
+// Gateways are not required to offer it; without one, messages with an unknown outcome are sent again.
+type StatusChecker interface {
+	CheckStatus(ctx context.Context, deliveryKey string) (*DeliveryStatus, error)
+}
+
 // WebhookSender implements the Sender interface for the example webhook.site.
 type WebhookSender struct {
 	Client  *http.Client
@@ -60,17 +127,34 @@ func (s *WebhookSender) Send(ctx context.Context, message model.Message) (*SendR
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
+	req.Header.Set(IdempotencyKeyHeader, DeliveryKey(message))
 	if s.AuthKey != "" {
 		req.Header.Set("api-key", s.AuthKey)
 	}
 
+	// once the request is written the gateway may act on it, even if no response makes it back
+	var written atomic.Bool
+	req = req.WithContext(httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				written.Store(true)
+			}
+		},
+	}))
+
 	resp, err := s.Client.Do(req)
 	if err != nil {
-		return nil, WrapUpstreamError(fmt.Errorf("failed to execute request: %w", err), 0)
+		ue := WrapTransportError(fmt.Errorf("failed to execute request: %w", err))
+		ue.Ambiguous = written.Load()
+		return nil, ue
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusAccepted {
+	// only a response the gateway marks as replayed, successful or a conflict on the delivery key, means an earlier
+	// attempt was already accepted; any other conflict, e.g. a key reused with a different payload, is a failure
+	duplicate := strings.EqualFold(resp.Header.Get(IdempotentReplayedHeader), "true") &&
+		(resp.StatusCode < http.StatusMultipleChoices || resp.StatusCode == http.StatusConflict)
+	if resp.StatusCode != http.StatusAccepted && !duplicate {
 		return nil, WrapUpstreamError(
 			fmt.Errorf("unexpected status code: %d", resp.StatusCode),
 			resp.StatusCode,
@@ -79,8 +163,19 @@ func (s *WebhookSender) Send(ctx context.Context, message model.Message) (*SendR
 
 	var response SendResponse
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return nil, WrapUpstreamError(fmt.Errorf("failed to decode response body: %w", err), resp.StatusCode)
+		ue := WrapUpstreamError(fmt.Errorf("failed to decode response body: %w", err), resp.StatusCode)
+		// the status says the message was taken, only which external ID it got is lost
+		ue.Ambiguous = true
+		return nil, ue
+	}
+	if duplicate && response.MessageID == "" {
+		return nil, WrapUpstreamError(
+			fmt.Errorf("duplicate delivery reported without the original message ID, status code: %d", resp.StatusCode),
+			resp.StatusCode,
+		)
 	}
 
+	response.StatusCode = resp.StatusCode
+	response.Duplicate = duplicate
 	return &response, nil
 }
@@ -21,6 +21,28 @@ func createMessage() model.Message {
 	}
 }
 
+func TestDeliveryKey(t *testing.T) {
+	require.Equal(t, "message-42", gateway.DeliveryKey(model.Message{ID: 42}))
+	require.Equal(t, gateway.DeliveryKey(model.Message{ID: 42, AttemptCount: 1}), gateway.DeliveryKey(model.Message{ID: 42, AttemptCount: 2}),
+		"stable across attempts")
+	require.NotEqual(t, gateway.DeliveryKey(model.Message{ID: 42}), gateway.DeliveryKey(model.Message{ID: 43}))
+	require.Equal(t, "message-42-2", gateway.DeliveryKey(model.Message{ID: 42, DeliveryGeneration: 2}),
+		"a replayed or edited message gets a new key")
+}
+
+func TestParseDeliveryKey(t *testing.T) {
+	for _, m := range []model.Message{{ID: 42}, {ID: 42, DeliveryGeneration: 3}} {
+		id, gen, ok := gateway.ParseDeliveryKey(gateway.DeliveryKey(m))
+		require.True(t, ok)
+		require.Equal(t, m.ID, id)
+		require.Equal(t, m.DeliveryGeneration, gen)
+	}
+	for _, key := range []string{"", "42", "message-", "message-x", "message-0", "message-42-0", "message-42-x", "message-42-1-2"} {
+		_, _, ok := gateway.ParseDeliveryKey(key)
+		require.False(t, ok, key)
+	}
+}
+
 func TestWebhookSender_Send(t *testing.T) {
 	tests := []struct {
 		name           string
@@ -29,12 +51,20 @@ func TestWebhookSender_Send(t *testing.T) {
 		expectedErr    string
 		expectedRespID string
 		expectedStatus string
-		timeout        time.Duration
-		cancelContext  bool
+		expectedDup    bool
+		// expectedAmbiguous marks errors after which the message may have been accepted
+		expectedAmbiguous bool
+		timeout           time.Duration
+		cancelContext     bool
+		closeServer       bool
 	}{
 		{
 			name: "accepted",
 			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get(gateway.IdempotencyKeyHeader) != "message-1" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
 				w.WriteHeader(http.StatusAccepted)
 				_ = json.NewEncoder(w).Encode(map[string]string{
 					"messageId": "def456",
@@ -52,13 +82,63 @@ func TestWebhookSender_Send(t *testing.T) {
 			},
 			expectedErr: "unexpected status code: 400",
 		},
+		{
+			name: "duplicate delivery key",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(gateway.IdempotentReplayedHeader, "true")
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(map[string]string{
+					"messageId": "orig-1",
+					"message":   "duplicate",
+				})
+			},
+			expectedRespID: "orig-1",
+			expectedStatus: "duplicate",
+			expectedDup:    true,
+		},
+		{
+			name: "replayed response",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(gateway.IdempotentReplayedHeader, "true")
+				w.WriteHeader(http.StatusOK)
+				_ = json.NewEncoder(w).Encode(map[string]string{
+					"messageId": "orig-1",
+					"message":   "accepted",
+				})
+			},
+			expectedRespID: "orig-1",
+			expectedStatus: "accepted",
+			expectedDup:    true,
+		},
+		{
+			name: "duplicate without original ID",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(gateway.IdempotentReplayedHeader, "true")
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"message":"duplicate"}`))
+			},
+			expectedErr: "without the original message ID",
+		},
+		{
+			// e.g. the key was reused with a different payload; the original ID must not be taken for this message
+			name: "conflict without replayed response",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusConflict)
+				_ = json.NewEncoder(w).Encode(map[string]string{
+					"messageId": "orig-1",
+					"message":   "idempotency key reused",
+				})
+			},
+			expectedErr: "unexpected status code: 409",
+		},
 		{
 			name: "invalid JSON response",
 			serverHandler: func(w http.ResponseWriter, r *http.Request) {
 				w.WriteHeader(http.StatusAccepted)
 				_, _ = w.Write([]byte(`{invalid-json}`))
 			},
-			expectedErr: "decode response body",
+			expectedErr:       "decode response body",
+			expectedAmbiguous: true,
 		},
 		{
 			name: "context canceled",
@@ -75,8 +155,17 @@ func TestWebhookSender_Send(t *testing.T) {
 				time.Sleep(200 * time.Millisecond)
 				w.WriteHeader(http.StatusOK)
 			},
+			expectedErr:       "execute request",
+			expectedAmbiguous: true,
+			timeout:           50 * time.Millisecond,
+		},
+		{
+			name: "connection closed before the request was sent",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+			},
 			expectedErr: "execute request",
-			timeout:     50 * time.Millisecond,
+			closeServer: true,
 		},
 	}
 
@@ -84,6 +173,9 @@ func TestWebhookSender_Send(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ts := httptest.NewServer(tt.serverHandler)
 			defer ts.Close()
+			if tt.closeServer {
+				ts.Close()
+			}
 
 			timeout := 5 * time.Second
 			if tt.timeout != 0 {
@@ -104,12 +196,15 @@ func TestWebhookSender_Send(t *testing.T) {
 			if tt.expectedErr != "" {
 				require.Error(t, err)
 				require.Contains(t, err.Error(), tt.expectedErr)
+				require.Equal(t, tt.expectedAmbiguous, gateway.IsAmbiguous(err))
 				require.Nil(t, resp)
 			} else {
 				require.NoError(t, err)
 				require.NotNil(t, resp)
 				require.Equal(t, tt.expectedRespID, resp.MessageID)
 				require.Equal(t, tt.expectedStatus, resp.Message)
+				require.Equal(t, tt.expectedDup, resp.Duplicate)
+				require.True(t, resp.Accepted())
 			}
 		})
 	}
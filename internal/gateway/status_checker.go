@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebhookStatusChecker implements the StatusChecker interface with a lookup endpoint of the gateway,
+// queried as GET <URL>/<delivery key>
+type WebhookStatusChecker struct {
+	Client  *http.Client
+	URL     string
+	AuthKey string
+}
+
+type statusResponse struct {
+	MessageID string `json:"messageId"`
+}
+
+func NewWebhookStatusChecker(statusURL, authKey string, timeout time.Duration) StatusChecker {
+	return &WebhookStatusChecker{
+		Client: &http.Client{
+			Timeout: timeout,
+		},
+		URL:     statusURL,
+		AuthKey: authKey,
+	}
+}
+
+// CheckStatus reports the message accepted under the delivery key; a 404 means the gateway never accepted it
+func (c *WebhookStatusChecker) CheckStatus(ctx context.Context, deliveryKey string) (*DeliveryStatus, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(c.URL, "/")+"/"+url.PathEscape(deliveryKey), nil)
+	if err != nil {
+		return nil, WrapUpstreamError(fmt.Errorf("failed to create request: %w", err), 0)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if c.AuthKey != "" {
+		req.Header.Set("api-key", c.AuthKey)
+	}
+
+	resp, err := c.Client.Do(req)
+	if err != nil {
+		return nil, WrapTransportError(fmt.Errorf("failed to execute request: %w", err))
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return &DeliveryStatus{}, nil
+	default:
+		return nil, WrapUpstreamError(
+			fmt.Errorf("unexpected status code: %d", resp.StatusCode),
+			resp.StatusCode,
+		)
+	}
+
+	var status statusResponse
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, WrapUpstreamError(fmt.Errorf("failed to decode response body: %w", err), resp.StatusCode)
+	}
+	if status.MessageID == "" {
+		return nil, WrapUpstreamError(fmt.Errorf("delivery reported without a message ID"), resp.StatusCode)
+	}
+	return &DeliveryStatus{Found: true, MessageID: status.MessageID}, nil
+}
@@ -0,0 +1,73 @@
+package gateway_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/gateway"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookStatusChecker_CheckStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		serverHandler http.HandlerFunc
+		expectedErr   string
+		expected      *gateway.DeliveryStatus
+	}{
+		{
+			name: "found",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/status/message-1" || r.Header.Get("api-key") != "secret" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				_, _ = w.Write([]byte(`{"messageId":"ext-1"}`))
+			},
+			expected: &gateway.DeliveryStatus{Found: true, MessageID: "ext-1"},
+		},
+		{
+			name: "not found",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+			expected: &gateway.DeliveryStatus{},
+		},
+		{
+			name: "found without message ID",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(`{}`))
+			},
+			expectedErr: "without a message ID",
+		},
+		{
+			name: "server error",
+			serverHandler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			},
+			expectedErr: "unexpected status code: 503",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(tt.serverHandler)
+			defer ts.Close()
+
+			checker := gateway.NewWebhookStatusChecker(ts.URL+"/status/", "secret", 5*time.Second)
+			status, err := checker.CheckStatus(context.Background(), gateway.DeliveryKey(model.Message{ID: 1}))
+			if tt.expectedErr != "" {
+				require.Error(t, err)
+				require.Contains(t, err.Error(), tt.expectedErr)
+				require.Nil(t, status)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, status)
+		})
+	}
+}
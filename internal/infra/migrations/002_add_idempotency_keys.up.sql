@@ -0,0 +1,9 @@
+ALTER TABLE messages
+    ADD COLUMN IF NOT EXISTS created_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC'),
+    ADD COLUMN IF NOT EXISTS client_id VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+
+-- Keys are unique per producer; expired keys are released by clearing the column
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_client_idempotency_key
+    ON messages(client_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
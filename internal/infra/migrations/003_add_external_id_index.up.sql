@@ -0,0 +1,2 @@
+-- Lookups by the gateway assigned ID
+CREATE INDEX IF NOT EXISTS idx_messages_external_id ON messages(external_id);
@@ -0,0 +1,3 @@
+-- Indexes backing the message search API
+CREATE INDEX IF NOT EXISTS idx_messages_phone_number ON messages(phone_number);
+CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at);
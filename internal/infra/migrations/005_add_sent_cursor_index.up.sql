@@ -0,0 +1,2 @@
+-- Keyset pagination over sent messages by (sent_time, id)
+CREATE INDEX IF NOT EXISTS idx_messages_sent_time_id ON messages(sent_time, id) WHERE status = 'sent';
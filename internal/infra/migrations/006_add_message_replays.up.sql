@@ -0,0 +1,14 @@
+-- Audit trail of failed messages moved back to pending
+CREATE TABLE IF NOT EXISTS message_replays (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES messages(id),
+    replayed_by VARCHAR(64) NOT NULL,
+    replayed_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC'),
+    previous_phone_number VARCHAR(20) NOT NULL,
+    previous_content VARCHAR(160) NOT NULL,
+    previous_attempt_count INT NOT NULL,
+    phone_number VARCHAR(20) NOT NULL,
+    content VARCHAR(160) NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_replays_message_id ON message_replays(message_id);
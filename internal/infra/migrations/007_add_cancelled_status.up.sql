@@ -0,0 +1,4 @@
+-- Pending messages can be cancelled before they are relayed
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_check;
+ALTER TABLE messages ADD CONSTRAINT messages_status_check
+    CHECK (status IN ('pending', 'sent', 'failed', 'cancelled'));
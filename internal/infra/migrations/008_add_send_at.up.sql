@@ -0,0 +1,5 @@
+-- Deferred delivery: pending messages are not relayed before send_at
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS send_at TIMESTAMP;
+
+-- Backs the due check of the pending fetch; rows without send_at are due immediately
+CREATE INDEX IF NOT EXISTS idx_messages_pending_send_at ON messages(send_at, id) WHERE status = 'pending';
@@ -0,0 +1,8 @@
+-- Messages not relayed before expires_at move to the terminal expired status
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_check;
+ALTER TABLE messages ADD CONSTRAINT messages_status_check
+    CHECK (status IN ('pending', 'sent', 'failed', 'cancelled', 'expired'));
+
+CREATE INDEX IF NOT EXISTS idx_messages_pending_expires_at ON messages(expires_at) WHERE status = 'pending';
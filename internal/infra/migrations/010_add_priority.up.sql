@@ -0,0 +1,6 @@
+-- Priority lanes: 1 = low, 2 = normal, 3 = high
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS priority SMALLINT NOT NULL DEFAULT 2
+    CHECK (priority BETWEEN 1 AND 3);
+
+-- Backs the per-priority claim of pending messages
+CREATE INDEX IF NOT EXISTS idx_messages_pending_priority ON messages(priority, id) WHERE status = 'pending';
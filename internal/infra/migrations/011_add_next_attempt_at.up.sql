@@ -0,0 +1,4 @@
+-- Retry backoff: a pending message is not fetched again before next_attempt_at
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS next_attempt_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_messages_pending_next_attempt_at ON messages(next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,4 @@
+-- Per-message override of relayer.maxAttempts; NULL uses the configured default
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS max_attempts SMALLINT;
+
+ALTER TABLE messages ADD CONSTRAINT messages_max_attempts_check CHECK (max_attempts IS NULL OR max_attempts BETWEEN 1 AND 100);
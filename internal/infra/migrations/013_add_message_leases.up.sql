@@ -0,0 +1,5 @@
+-- Lease based claiming: a relayer stamps the messages it is sending instead of holding row locks
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS claimed_by TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS lease_until TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_messages_pending_lease_until ON messages(lease_until) WHERE status = 'pending';
@@ -0,0 +1,14 @@
+-- Wakes up listening relayers as soon as new messages are committed; one notification per statement so bulk
+-- imports don't flood the channel. Identical notifications within a transaction are folded by Postgres.
+CREATE OR REPLACE FUNCTION notify_messages_pending() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('messages_pending', '');
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS messages_notify_pending ON messages;
+CREATE TRIGGER messages_notify_pending
+    AFTER INSERT ON messages
+    FOR EACH STATEMENT
+    EXECUTE FUNCTION notify_messages_pending();
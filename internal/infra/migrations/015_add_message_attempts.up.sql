@@ -0,0 +1,13 @@
+-- Audit trail of every send attempt made by the relayer
+CREATE TABLE IF NOT EXISTS message_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES messages(id),
+    attempted_at TIMESTAMP NOT NULL,
+    status_code SMALLINT,
+    error TEXT,
+    recoverable BOOLEAN NOT NULL DEFAULT false,
+    external_id TEXT,
+    duration_ms INT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_message_attempts_message_id ON message_attempts(message_id);
@@ -0,0 +1,6 @@
+-- Why a message failed: the error of its latest attempt and the reason it was given up on
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS last_error TEXT;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS failure_reason VARCHAR(32)
+    CHECK (failure_reason IN ('max_attempts', 'unrecoverable_error', 'unexpected_response'));
+
+CREATE INDEX IF NOT EXISTS idx_messages_failure_reason ON messages(failure_reason) WHERE status = 'failed';
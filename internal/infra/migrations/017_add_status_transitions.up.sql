@@ -0,0 +1,20 @@
+-- Rejects status changes outside the transitions of model.MessageStatus, whatever statement issues them:
+-- pending -> sent/failed/cancelled/expired and failed -> pending on replay
+CREATE OR REPLACE FUNCTION check_message_status_transition() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NOT (
+        (OLD.status = 'pending' AND NEW.status IN ('sent', 'failed', 'cancelled', 'expired')) OR
+        (OLD.status = 'failed' AND NEW.status = 'pending')
+    ) THEN
+        RAISE EXCEPTION 'message % cannot move from % to %', OLD.id, OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS messages_status_transition ON messages;
+CREATE TRIGGER messages_status_transition
+    BEFORE UPDATE OF status ON messages
+    FOR EACH ROW
+    EXECUTE FUNCTION check_message_status_transition();
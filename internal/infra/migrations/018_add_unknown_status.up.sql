@@ -0,0 +1,21 @@
+-- Sends that may have reached the gateway move to unknown until the reconciler asks the gateway about them:
+-- pending -> unknown, then unknown -> sent when it was accepted or unknown -> pending to send it again
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_check;
+ALTER TABLE messages ADD CONSTRAINT messages_status_check
+    CHECK (status IN ('pending', 'sent', 'failed', 'cancelled', 'expired', 'unknown'));
+
+CREATE OR REPLACE FUNCTION check_message_status_transition() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NOT (
+        (OLD.status = 'pending' AND NEW.status IN ('sent', 'failed', 'cancelled', 'expired', 'unknown')) OR
+        (OLD.status = 'failed' AND NEW.status = 'pending') OR
+        (OLD.status = 'unknown' AND NEW.status IN ('sent', 'pending'))
+    ) THEN
+        RAISE EXCEPTION 'message % cannot move from % to %', OLD.id, OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE INDEX IF NOT EXISTS idx_messages_unknown_next_attempt_at ON messages(next_attempt_at) WHERE status = 'unknown';
@@ -0,0 +1,26 @@
+-- Delivery receipts move sent messages to their final handset outcome: sent -> delivered/undelivered
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivered_time TIMESTAMP;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_status_check;
+ALTER TABLE messages ADD CONSTRAINT messages_status_check
+    CHECK (status IN ('pending', 'sent', 'failed', 'cancelled', 'expired', 'unknown', 'delivered', 'undelivered'));
+
+CREATE OR REPLACE FUNCTION check_message_status_transition() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NOT (
+        (OLD.status = 'pending' AND NEW.status IN ('sent', 'failed', 'cancelled', 'expired', 'unknown')) OR
+        (OLD.status = 'failed' AND NEW.status = 'pending') OR
+        (OLD.status = 'unknown' AND NEW.status IN ('sent', 'pending')) OR
+        (OLD.status = 'sent' AND NEW.status IN ('delivered', 'undelivered'))
+    ) THEN
+        RAISE EXCEPTION 'message % cannot move from % to %', OLD.id, OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- sent messages keep their place in the sent listing once a receipt arrives
+DROP INDEX IF EXISTS idx_messages_sent_time_id;
+CREATE INDEX IF NOT EXISTS idx_messages_sent_time_id ON messages(sent_time, id)
+    WHERE status IN ('sent', 'delivered', 'undelivered');
@@ -0,0 +1,59 @@
+-- Status change callbacks to producers: a message's own callback_url, else the one registered for its client
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS callback_url VARCHAR(2048);
+
+CREATE TABLE IF NOT EXISTS client_callbacks (
+    client_id VARCHAR(64) PRIMARY KEY,
+    url VARCHAR(2048) NOT NULL,
+    updated_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC')
+);
+
+-- Outbox of status changes to push; rows are written in the transaction changing the status and dispatched
+-- independently of relaying, with their own lease and retry backoff
+CREATE TABLE IF NOT EXISTS callback_events (
+    id BIGSERIAL PRIMARY KEY,
+    message_id BIGINT NOT NULL REFERENCES messages(id),
+    url VARCHAR(2048) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    previous_status VARCHAR(20) NOT NULL,
+    external_id VARCHAR(100),
+    last_error TEXT,
+    failure_reason VARCHAR(32),
+    occurred_at TIMESTAMP NOT NULL DEFAULT (now() AT TIME ZONE 'UTC'),
+    state VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (state IN ('pending', 'delivered', 'failed')),
+    attempt_count INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP,
+    claimed_by TEXT,
+    lease_until TIMESTAMP,
+    dispatch_error TEXT,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_callback_events_pending ON callback_events(next_attempt_at, id) WHERE state = 'pending';
+CREATE INDEX IF NOT EXISTS idx_callback_events_message_id ON callback_events(message_id);
+
+-- Every status change a producer cares about is queued; pending and unknown are internal to the relayer
+CREATE OR REPLACE FUNCTION enqueue_status_callback() RETURNS trigger AS $$
+DECLARE
+    target VARCHAR(2048) := NEW.callback_url;
+BEGIN
+    IF NEW.status NOT IN ('sent', 'failed', 'delivered', 'undelivered', 'expired', 'cancelled') THEN
+        RETURN NULL;
+    END IF;
+    IF target IS NULL THEN
+        SELECT url INTO target FROM client_callbacks WHERE client_id = NEW.client_id;
+    END IF;
+    IF target IS NOT NULL THEN
+        INSERT INTO callback_events (message_id, url, status, previous_status, external_id, last_error, failure_reason)
+        VALUES (NEW.id, target, NEW.status, OLD.status, NEW.external_id, NEW.last_error, NEW.failure_reason);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS messages_status_callback ON messages;
+CREATE TRIGGER messages_status_callback
+    AFTER UPDATE OF status ON messages
+    FOR EACH ROW
+    WHEN (OLD.status IS DISTINCT FROM NEW.status)
+    EXECUTE FUNCTION enqueue_status_callback();
@@ -0,0 +1,3 @@
+-- Replaying or editing a message starts a new delivery generation, so the gateway sees a new idempotency key
+-- instead of answering the changed message with the response stored for an earlier attempt
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS delivery_generation INT NOT NULL DEFAULT 0;
@@ -0,0 +1,20 @@
+-- A receipt may arrive for a message whose send timed out before the reconciler confirmed it:
+-- unknown -> delivered/undelivered
+CREATE OR REPLACE FUNCTION check_message_status_transition() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NOT (
+        (OLD.status = 'pending' AND NEW.status IN ('sent', 'failed', 'cancelled', 'expired', 'unknown')) OR
+        (OLD.status = 'failed' AND NEW.status = 'pending') OR
+        (OLD.status = 'unknown' AND NEW.status IN ('sent', 'pending', 'delivered', 'undelivered')) OR
+        (OLD.status = 'sent' AND NEW.status IN ('delivered', 'undelivered'))
+    ) THEN
+        RAISE EXCEPTION 'message % cannot move from % to %', OLD.id, OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- receipts find their message by the gateway assigned ID, which must therefore name a single message
+DROP INDEX IF EXISTS idx_messages_external_id;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_messages_external_id ON messages(external_id);
@@ -0,0 +1,22 @@
+-- Failed status lookups of a message with an unknown outcome; the reconciler gives up on the message after too many
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS status_checks INT NOT NULL DEFAULT 0;
+
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_failure_reason_check;
+ALTER TABLE messages ADD CONSTRAINT messages_failure_reason_check
+    CHECK (failure_reason IN ('max_attempts', 'unrecoverable_error', 'unexpected_response', 'unknown_outcome'));
+
+-- unknown -> failed once the gateway could not tell whether it accepted the message
+CREATE OR REPLACE FUNCTION check_message_status_transition() RETURNS trigger AS $$
+BEGIN
+    IF NEW.status IS DISTINCT FROM OLD.status AND NOT (
+        (OLD.status = 'pending' AND NEW.status IN ('sent', 'failed', 'cancelled', 'expired', 'unknown')) OR
+        (OLD.status = 'failed' AND NEW.status = 'pending') OR
+        (OLD.status = 'unknown' AND NEW.status IN ('sent', 'pending', 'delivered', 'undelivered', 'failed')) OR
+        (OLD.status = 'sent' AND NEW.status IN ('delivered', 'undelivered'))
+    ) THEN
+        RAISE EXCEPTION 'message % cannot move from % to %', OLD.id, OLD.status, NEW.status
+            USING ERRCODE = 'check_violation';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
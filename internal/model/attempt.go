@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// MessageAttempt records a single send attempt made by the relayer
+type MessageAttempt struct {
+	ID          int64     `json:"id"`
+	MessageID   int64     `json:"message_id"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	// StatusCode is the HTTP status returned by the gateway, 0 when no response was received
+	StatusCode  int    `json:"status_code,omitempty"`
+	Error       string `json:"error,omitempty"`
+	Recoverable bool   `json:"recoverable"`
+	ExternalID  string `json:"external_id,omitempty"`
+	DurationMS  int64  `json:"duration_ms"`
+}
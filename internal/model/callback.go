@@ -0,0 +1,63 @@
+package model
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxCallbackURLLength mirrors the callback_url column of the messages table
+const MaxCallbackURLLength = 2048
+
+// ValidateCallbackURL requires an absolute http or https URL
+func ValidateCallbackURL(raw string) error {
+	return validateURL("callback_url", raw)
+}
+
+func validateURL(field, raw string) error {
+	if utf8.RuneCountInString(raw) > MaxCallbackURLLength {
+		return &ValidationError{Field: field, Reason: fmt.Sprintf("must be at most %d characters", MaxCallbackURLLength)}
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return &ValidationError{Field: field, Reason: "must be an absolute http or https URL"}
+	}
+	return nil
+}
+
+// ClientCallback is the callback URL a producer registered for all of its messages
+type ClientCallback struct {
+	ClientID  string    `json:"client_id"`
+	URL       string    `json:"url"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Validate checks the client ID and URL against the client_callbacks columns
+func (c ClientCallback) Validate() error {
+	switch {
+	case c.ClientID == "":
+		return &ValidationError{Field: "client_id", Reason: "must not be empty"}
+	case utf8.RuneCountInString(c.ClientID) > MaxClientIDLength:
+		return &ValidationError{Field: "client_id", Reason: fmt.Sprintf("must be at most %d characters", MaxClientIDLength)}
+	}
+	if c.URL == "" {
+		return &ValidationError{Field: "url", Reason: "must not be empty"}
+	}
+	return validateURL("url", c.URL)
+}
+
+// CallbackEvent is a status change of a message waiting in the outbox to be pushed to the producer
+type CallbackEvent struct {
+	ID             int64
+	MessageID      int64
+	URL            string
+	Status         MessageStatus
+	PreviousStatus MessageStatus
+	ExternalID     string
+	LastError      string
+	FailureReason  FailureReason
+	OccurredAt     time.Time
+	// AttemptCount is how many deliveries of the event failed so far
+	AttemptCount int
+}
@@ -0,0 +1,46 @@
+package model_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+func TestClientCallback_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		cb        model.ClientCallback
+		wantField string
+	}{
+		{"valid", model.ClientCallback{ClientID: "billing", URL: "https://billing.example.com/sms"}, ""},
+		{"plain http", model.ClientCallback{ClientID: "billing", URL: "http://billing.internal:8080/sms"}, ""},
+		{"empty client", model.ClientCallback{URL: "https://billing.example.com/sms"}, "client_id"},
+		{"client too long", model.ClientCallback{ClientID: strings.Repeat("c", 65), URL: "https://billing.example.com/sms"}, "client_id"},
+		{"empty url", model.ClientCallback{ClientID: "billing"}, "url"},
+		{"relative url", model.ClientCallback{ClientID: "billing", URL: "/sms"}, "url"},
+		{"unsupported scheme", model.ClientCallback{ClientID: "billing", URL: "mailto:ops@example.com"}, "url"},
+		{"url too long", model.ClientCallback{ClientID: "billing", URL: "https://example.com/" + strings.Repeat("a", 2048)}, "url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cb.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var vErr *model.ValidationError
+			if !errors.As(err, &vErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if vErr.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", vErr.Field, tt.wantField)
+			}
+		})
+	}
+}
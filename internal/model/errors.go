@@ -0,0 +1,42 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrIdempotencyKeyReused is returned when an idempotency key is replayed with a different payload
+	ErrIdempotencyKeyReused = errors.New("idempotency key already used with a different request")
+
+	// ErrNotFound is returned when a requested message does not exist
+	ErrNotFound = errors.New("message not found")
+
+	// ErrNotPending is returned when a message can no longer be changed because it left the pending state
+	// or is currently being relayed
+	ErrNotPending = errors.New("message is no longer pending")
+
+	// ErrLeaseLost is returned when a relayer records an outcome for a message it no longer holds the lease on
+	ErrLeaseLost = errors.New("message lease lost")
+
+	// ErrCallbackNotFound is returned when a client has no callback URL registered
+	ErrCallbackNotFound = errors.New("client callback not found")
+
+	// ErrInvalidTransition is matched by every TransitionError
+	ErrInvalidTransition = errors.New("invalid status transition")
+)
+
+// TransitionError is returned when a message cannot move to the requested status from the one it is in
+type TransitionError struct {
+	MessageID int64
+	From      MessageStatus
+	To        MessageStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("message %d cannot move from %s to %s", e.MessageID, e.From, e.To)
+}
+
+func (e *TransitionError) Unwrap() error {
+	return ErrInvalidTransition
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FailureReason tells why the relayer gave up on a failed message; the error itself is kept in Message.LastError
+type FailureReason string
+
+const (
+	// FailureMaxAttempts means every allowed attempt failed with a recoverable error
+	FailureMaxAttempts FailureReason = "max_attempts"
+	// FailureUnrecoverable means the gateway returned an error that is not retried, e.g. a 4xx status
+	FailureUnrecoverable FailureReason = "unrecoverable_error"
+	// FailureUnexpectedResponse means the gateway replied with something other than "accepted"
+	FailureUnexpectedResponse FailureReason = "unexpected_response"
+	// FailureUnknownOutcome means every status lookup of a message with an unknown outcome failed
+	FailureUnknownOutcome FailureReason = "unknown_outcome"
+)
+
+// IsValid reports whether the reason is one the messages table accepts
+func (r FailureReason) IsValid() bool {
+	switch r {
+	case FailureMaxAttempts, FailureUnrecoverable, FailureUnexpectedResponse, FailureUnknownOutcome:
+		return true
+	}
+	return false
+}
+
+// ParseFailureReasons parses a comma separated failure reason list
+func ParseFailureReasons(raw string) ([]FailureReason, error) {
+	var reasons []FailureReason
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		r := FailureReason(part)
+		if !r.IsValid() {
+			return nil, &ValidationError{Field: "failure_reason", Reason: fmt.Sprintf("unknown failure reason %q", part)}
+		}
+		reasons = append(reasons, r)
+	}
+	return reasons, nil
+}
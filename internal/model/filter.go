@@ -0,0 +1,81 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MessageFilter narrows a message listing; zero values leave a criterion unset
+type MessageFilter struct {
+	Statuses    []MessageStatus
+	PhoneNumber string
+	MinID       int64
+	MaxID       int64
+	CreatedFrom time.Time
+	CreatedTo   time.Time
+	SentFrom    time.Time
+	SentTo      time.Time
+	MinAttempts *int
+	MaxAttempts *int
+	// Scheduled selects messages whose send_at lies in the future when true, and due messages when false
+	Scheduled *bool
+	// FailureReasons selects failed messages given up on for one of the reasons
+	FailureReasons []FailureReason
+}
+
+// IsEmpty reports whether the filter would match every message
+func (f MessageFilter) IsEmpty() bool {
+	return len(f.Statuses) == 0 &&
+		f.PhoneNumber == "" &&
+		f.MinID == 0 && f.MaxID == 0 &&
+		f.CreatedFrom.IsZero() && f.CreatedTo.IsZero() &&
+		f.SentFrom.IsZero() && f.SentTo.IsZero() &&
+		f.MinAttempts == nil && f.MaxAttempts == nil &&
+		f.Scheduled == nil &&
+		len(f.FailureReasons) == 0
+}
+
+// Validate rejects unknown statuses and inverted ranges
+func (f MessageFilter) Validate() error {
+	for _, s := range f.Statuses {
+		if !s.IsValid() {
+			return &ValidationError{Field: "status", Reason: fmt.Sprintf("unknown status %q", s)}
+		}
+	}
+	for _, r := range f.FailureReasons {
+		if !r.IsValid() {
+			return &ValidationError{Field: "failure_reason", Reason: fmt.Sprintf("unknown failure reason %q", r)}
+		}
+	}
+	if f.MinID != 0 && f.MaxID != 0 && f.MinID > f.MaxID {
+		return &ValidationError{Field: "min_id", Reason: "must not be greater than max_id"}
+	}
+	if !f.CreatedFrom.IsZero() && !f.CreatedTo.IsZero() && f.CreatedFrom.After(f.CreatedTo) {
+		return &ValidationError{Field: "created_from", Reason: "must not be after created_to"}
+	}
+	if !f.SentFrom.IsZero() && !f.SentTo.IsZero() && f.SentFrom.After(f.SentTo) {
+		return &ValidationError{Field: "sent_from", Reason: "must not be after sent_to"}
+	}
+	if f.MinAttempts != nil && f.MaxAttempts != nil && *f.MinAttempts > *f.MaxAttempts {
+		return &ValidationError{Field: "min_attempts", Reason: "must not be greater than max_attempts"}
+	}
+	return nil
+}
+
+// ParseStatuses parses a comma separated status list
+func ParseStatuses(raw string) ([]MessageStatus, error) {
+	var statuses []MessageStatus
+	for _, part := range strings.Split(raw, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		s := MessageStatus(part)
+		if !s.IsValid() {
+			return nil, &ValidationError{Field: "status", Reason: fmt.Sprintf("unknown status %q", part)}
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses, nil
+}
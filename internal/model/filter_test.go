@@ -0,0 +1,89 @@
+package model_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+func TestParseStatuses(t *testing.T) {
+	got, err := model.ParseStatuses("pending, FAILED,,sent")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []model.MessageStatus{model.StatusPending, model.StatusFailed, model.StatusSent}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseStatuses() = %v, want %v", got, want)
+	}
+
+	_, err = model.ParseStatuses("pending,bogus")
+	var vErr *model.ValidationError
+	if !errors.As(err, &vErr) || vErr.Field != "status" {
+		t.Errorf("expected status ValidationError, got %v", err)
+	}
+}
+
+func TestParseFailureReasons(t *testing.T) {
+	got, err := model.ParseFailureReasons("max_attempts, Unexpected_Response")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []model.FailureReason{model.FailureMaxAttempts, model.FailureUnexpectedResponse}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseFailureReasons() = %v, want %v", got, want)
+	}
+
+	_, err = model.ParseFailureReasons("timeout")
+	var vErr *model.ValidationError
+	if !errors.As(err, &vErr) || vErr.Field != "failure_reason" {
+		t.Errorf("expected failure_reason ValidationError, got %v", err)
+	}
+}
+
+func TestMessageFilter_Validate(t *testing.T) {
+	now := time.Now()
+	one, two := 1, 2
+
+	tests := []struct {
+		name      string
+		filter    model.MessageFilter
+		wantField string
+	}{
+		{"empty", model.MessageFilter{}, ""},
+		{"valid ranges", model.MessageFilter{MinID: 1, MaxID: 2, MinAttempts: &one, MaxAttempts: &two}, ""},
+		{"unknown status", model.MessageFilter{Statuses: []model.MessageStatus{"bogus"}}, "status"},
+		{"unknown failure reason", model.MessageFilter{FailureReasons: []model.FailureReason{"bogus"}}, "failure_reason"},
+		{"inverted ids", model.MessageFilter{MinID: 5, MaxID: 2}, "min_id"},
+		{"inverted created", model.MessageFilter{CreatedFrom: now, CreatedTo: now.Add(-time.Hour)}, "created_from"},
+		{"inverted sent", model.MessageFilter{SentFrom: now, SentTo: now.Add(-time.Hour)}, "sent_from"},
+		{"inverted attempts", model.MessageFilter{MinAttempts: &two, MaxAttempts: &one}, "min_attempts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.filter.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var vErr *model.ValidationError
+			if !errors.As(err, &vErr) || vErr.Field != tt.wantField {
+				t.Errorf("expected ValidationError on %q, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestMessageFilter_IsEmpty(t *testing.T) {
+	if !(model.MessageFilter{}).IsEmpty() {
+		t.Error("zero filter should be empty")
+	}
+	if (model.MessageFilter{PhoneNumber: "+1"}).IsEmpty() {
+		t.Error("filter with phone number should not be empty")
+	}
+}
@@ -1,21 +1,127 @@
 package model
 
-import "time"
-
-type MessageStatus string
-
-const (
-	StatusPending MessageStatus = "PENDING"
-	StatusSent    MessageStatus = "SENT"
-	StatusFailed  MessageStatus = "FAILED"
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
 )
 
 type Message struct {
-	ID           int64         `db:"id" json:"id"`
-	PhoneNumber  string        `db:"phone_number" json:"phone_number"`
-	Content      string        `db:"content" json:"content"`
-	Status       MessageStatus `db:"status" json:"status"`
-	SentTime     time.Time     `db:"sent_time" json:"sent_time"`
-	ExternalID   string        `db:"external_id" json:"external_id"`
-	AttemptCount int           `db:"attempt_count" json:"attempt_count"`
+	ID             int64           `db:"id" json:"id"`
+	PhoneNumber    string          `db:"phone_number" json:"phone_number"`
+	Content        string          `db:"content" json:"content"`
+	Status         MessageStatus   `db:"status" json:"status"`
+	SentTime       time.Time       `db:"sent_time" json:"sent_time"`
+	ExternalID     string          `db:"external_id" json:"external_id"`
+	AttemptCount   int             `db:"attempt_count" json:"attempt_count"`
+	CreatedAt      time.Time       `db:"created_at" json:"created_at"`
+	ClientID       string          `db:"client_id" json:"client_id,omitempty"`
+	IdempotencyKey string          `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	SendAt         time.Time       `db:"send_at" json:"send_at,omitzero"`
+	ExpiresAt      time.Time       `db:"expires_at" json:"expires_at,omitzero"`
+	Priority       MessagePriority `db:"priority" json:"priority" swaggertype:"string" enums:"low,normal,high"`
+	NextAttemptAt  time.Time       `db:"next_attempt_at" json:"next_attempt_at,omitzero"`
+	// MaxAttempts overrides the relayer's configured maximum when set
+	MaxAttempts int `db:"max_attempts" json:"max_attempts,omitzero"`
+	// ClaimedBy and LeaseUntil identify the relayer currently sending the message
+	ClaimedBy  string    `db:"claimed_by" json:"claimed_by,omitempty"`
+	LeaseUntil time.Time `db:"lease_until" json:"lease_until,omitzero"`
+	// LastError is the error of the most recent failed attempt
+	LastError string `db:"last_error" json:"last_error,omitempty"`
+	// FailureReason tells why a failed message was given up on
+	FailureReason FailureReason `db:"failure_reason" json:"failure_reason,omitempty" enums:"max_attempts,unrecoverable_error,unexpected_response,unknown_outcome"`
+	// DeliveredTime is when the gateway's delivery receipt reported the handset outcome
+	DeliveredTime time.Time `db:"delivered_time" json:"delivered_time,omitzero"`
+	// CallbackURL receives the message's status changes instead of the URL registered for its client
+	CallbackURL string `db:"callback_url" json:"callback_url,omitempty"`
+	// DeliveryGeneration is bumped by every replay or edit and is part of the gateway idempotency key
+	DeliveryGeneration int `db:"delivery_generation" json:"delivery_generation"`
+}
+
+// Expired reports whether the message must not be relayed anymore at now.
+// Messages without an explicit expiry expire defaultTTL after they became due; a non-positive defaultTTL disables that.
+func (m Message) Expired(now time.Time, defaultTTL time.Duration) bool {
+	if !m.ExpiresAt.IsZero() {
+		return !now.Before(m.ExpiresAt)
+	}
+	if defaultTTL <= 0 {
+		return false
+	}
+	due := m.CreatedAt
+	if !m.SendAt.IsZero() {
+		due = m.SendAt
+	}
+	return !now.Before(due.Add(defaultTTL))
+}
+
+// NewMessage holds the producer supplied fields of a message to be enqueued
+type NewMessage struct {
+	PhoneNumber string
+	Content     string
+	// ClientID scopes the idempotency key to a single producer
+	ClientID       string
+	IdempotencyKey string
+	// SendAt defers delivery until the given time; the zero value sends as soon as possible
+	SendAt time.Time
+	// ExpiresAt drops the message instead of relaying it after the given time
+	ExpiresAt time.Time
+	// Priority defaults to PriorityNormal when unset
+	Priority MessagePriority
+	// MaxAttempts overrides the relayer's configured maximum; zero uses the default
+	MaxAttempts int
+	// CallbackURL receives the message's status changes; empty falls back to the URL registered for ClientID
+	CallbackURL string
+}
+
+// Validate checks the message against the same rules the database enforces
+func (m NewMessage) Validate() error {
+	if err := ValidatePhoneNumber(m.PhoneNumber); err != nil {
+		return err
+	}
+	if err := ValidateContent(m.Content); err != nil {
+		return err
+	}
+	if utf8.RuneCountInString(m.ClientID) > MaxClientIDLength {
+		return &ValidationError{Field: "client_id", Reason: fmt.Sprintf("must be at most %d characters", MaxClientIDLength)}
+	}
+	if utf8.RuneCountInString(m.IdempotencyKey) > MaxIdempotencyKeyLength {
+		return &ValidationError{Field: "idempotency_key", Reason: fmt.Sprintf("must be at most %d characters", MaxIdempotencyKeyLength)}
+	}
+	if m.Priority != 0 && !m.Priority.IsValid() {
+		return &ValidationError{Field: "priority", Reason: "must be low, normal or high"}
+	}
+	if m.MaxAttempts < 0 || m.MaxAttempts > MaxMessageAttempts {
+		return &ValidationError{Field: "max_attempts", Reason: fmt.Sprintf("must be between 1 and %d", MaxMessageAttempts)}
+	}
+	if !m.ExpiresAt.IsZero() && !m.SendAt.IsZero() && !m.ExpiresAt.After(m.SendAt) {
+		return &ValidationError{Field: "expires_at", Reason: "must be after send_at"}
+	}
+	if m.CallbackURL != "" {
+		return ValidateCallbackURL(m.CallbackURL)
+	}
+	return nil
+}
+
+// MessageEdit replaces the recipient or content of a pending message; empty fields keep the stored value
+type MessageEdit struct {
+	PhoneNumber string
+	Content     string
+}
+
+// Validate requires at least one change and checks it against the same rules as a new message
+func (e MessageEdit) Validate() error {
+	if e.PhoneNumber == "" && e.Content == "" {
+		return &ValidationError{Field: "phone_number", Reason: "either phone_number or content is required"}
+	}
+	if e.PhoneNumber != "" {
+		if err := ValidatePhoneNumber(e.PhoneNumber); err != nil {
+			return err
+		}
+	}
+	if e.Content != "" {
+		if err := ValidateContent(e.Content); err != nil {
+			return err
+		}
+	}
+	return nil
 }
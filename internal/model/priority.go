@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MessagePriority orders pending messages for relaying; it is stored as a SMALLINT and rendered by name.
+// The zero value means unset and is stored as PriorityNormal.
+type MessagePriority int16
+
+const (
+	PriorityLow    MessagePriority = 1
+	PriorityNormal MessagePriority = 2
+	PriorityHigh   MessagePriority = 3
+)
+
+// Priorities lists all priority levels from highest to lowest
+var Priorities = []MessagePriority{PriorityHigh, PriorityNormal, PriorityLow}
+
+func (p MessagePriority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityNormal:
+		return "normal"
+	case PriorityHigh:
+		return "high"
+	}
+	return fmt.Sprintf("MessagePriority(%d)", int16(p))
+}
+
+// IsValid reports whether the priority is one the messages table accepts
+func (p MessagePriority) IsValid() bool {
+	return p >= PriorityLow && p <= PriorityHigh
+}
+
+// ParsePriority parses a priority name
+func ParsePriority(raw string) (MessagePriority, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "low":
+		return PriorityLow, nil
+	case "normal":
+		return PriorityNormal, nil
+	case "high":
+		return PriorityHigh, nil
+	}
+	return 0, &ValidationError{Field: "priority", Reason: fmt.Sprintf("unknown priority %q", raw)}
+}
+
+// OrDefault returns PriorityNormal for the unset zero value
+func (p MessagePriority) OrDefault() MessagePriority {
+	if p == 0 {
+		return PriorityNormal
+	}
+	return p
+}
+
+func (p MessagePriority) MarshalText() ([]byte, error) {
+	p = p.OrDefault()
+	if !p.IsValid() {
+		return nil, fmt.Errorf("invalid priority %d", int16(p))
+	}
+	return []byte(p.String()), nil
+}
+
+func (p *MessagePriority) UnmarshalText(text []byte) error {
+	parsed, err := ParsePriority(string(text))
+	if err != nil {
+		return err
+	}
+	*p = parsed
+	return nil
+}
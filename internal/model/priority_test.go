@@ -0,0 +1,58 @@
+package model_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+func TestMessagePriority_JSON(t *testing.T) {
+	out, err := json.Marshal(model.Message{ID: 1, Priority: model.PriorityHigh})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var decoded struct {
+		Priority string `json:"priority"`
+	}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.Priority != "high" {
+		t.Errorf("expected priority rendered as %q, got %q", "high", decoded.Priority)
+	}
+
+	// messages cached before priorities existed decode as unset and render as normal
+	var msg model.Message
+	if err := json.Unmarshal([]byte(`{"id":1}`), &msg); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out, err := json.Marshal(msg); err != nil || !json.Valid(out) {
+		t.Fatalf("unexpected marshal result: %s, %v", out, err)
+	}
+	if msg.Priority.OrDefault() != model.PriorityNormal {
+		t.Errorf("expected unset priority to default to normal, got %v", msg.Priority.OrDefault())
+	}
+
+	if err := json.Unmarshal([]byte(`{"priority":"urgent"}`), &msg); err == nil {
+		t.Error("expected unknown priority to be rejected")
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for raw, want := range map[string]model.MessagePriority{
+		"low":    model.PriorityLow,
+		"Normal": model.PriorityNormal,
+		" HIGH ": model.PriorityHigh,
+	} {
+		got, err := model.ParsePriority(raw)
+		if err != nil || got != want {
+			t.Errorf("ParsePriority(%q) = %v, %v; want %v", raw, got, err, want)
+		}
+	}
+
+	if _, err := model.ParsePriority("urgent"); err == nil {
+		t.Error("expected error for unknown priority")
+	}
+}
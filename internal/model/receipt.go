@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+// DeliveryReceipt is the gateway's report on whether a sent message reached the handset
+type DeliveryReceipt struct {
+	// ExternalID is the ID the gateway accepted the message with
+	ExternalID string
+	// Status is StatusDelivered or StatusUndelivered
+	Status MessageStatus
+	// DeliveredAt is when the gateway learned the outcome
+	DeliveredAt time.Time
+	// Error is the gateway's reason for an undelivered message
+	Error string
+	// MessageID and DeliveryGeneration identify the message by the delivery key it was sent with, when the gateway
+	// reports it. They find a message whose send outcome is still unknown, which has no external ID yet.
+	MessageID          int64
+	DeliveryGeneration int
+}
+
+// Validate checks that the receipt identifies a message and reports a delivery outcome
+func (r DeliveryReceipt) Validate() error {
+	if r.ExternalID == "" {
+		return &ValidationError{Field: "messageId", Reason: "must not be empty"}
+	}
+	if r.Status != StatusDelivered && r.Status != StatusUndelivered {
+		return &ValidationError{Field: "status", Reason: "must be delivered or undelivered"}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+func TestDeliveryReceipt_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		receipt   model.DeliveryReceipt
+		wantField string
+	}{
+		{"delivered", model.DeliveryReceipt{ExternalID: "ext-1", Status: model.StatusDelivered}, ""},
+		{"undelivered", model.DeliveryReceipt{ExternalID: "ext-1", Status: model.StatusUndelivered, Error: "absent subscriber"}, ""},
+		{"missing external ID", model.DeliveryReceipt{Status: model.StatusDelivered}, "messageId"},
+		{"not a delivery status", model.DeliveryReceipt{ExternalID: "ext-1", Status: model.StatusFailed}, "status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.receipt.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var vErr *model.ValidationError
+			if !errors.As(err, &vErr) || vErr.Field != tt.wantField {
+				t.Fatalf("expected validation error on %s, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
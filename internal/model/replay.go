@@ -0,0 +1,67 @@
+package model
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+// MaxReplayIDs caps the explicit ID list of a single replay request
+const MaxReplayIDs = 1000
+
+// ReplayRequest selects failed messages to move back to pending.
+// Messages are matched by IDs and Filter combined; at least one of them must be set.
+type ReplayRequest struct {
+	IDs    []int64
+	Filter MessageFilter
+	// PhoneNumber and Content replace the stored values when set
+	PhoneNumber string
+	Content     string
+	ReplayedBy  string
+}
+
+// Validate rejects requests that would replay every failed message or carry invalid edits
+func (r ReplayRequest) Validate() error {
+	if len(r.IDs) == 0 && r.Filter.IsEmpty() {
+		return &ValidationError{Field: "ids", Reason: "either ids or a filter is required"}
+	}
+	if len(r.IDs) > MaxReplayIDs {
+		return &ValidationError{Field: "ids", Reason: fmt.Sprintf("must contain at most %d IDs", MaxReplayIDs)}
+	}
+	if len(r.Filter.Statuses) > 0 {
+		return &ValidationError{Field: "status", Reason: "only failed messages can be replayed"}
+	}
+	if err := r.Filter.Validate(); err != nil {
+		return err
+	}
+	if r.PhoneNumber != "" {
+		if err := ValidatePhoneNumber(r.PhoneNumber); err != nil {
+			return err
+		}
+	}
+	if r.Content != "" {
+		if err := ValidateContent(r.Content); err != nil {
+			return err
+		}
+	}
+	switch {
+	case r.ReplayedBy == "":
+		return &ValidationError{Field: "replayed_by", Reason: "must not be empty"}
+	case utf8.RuneCountInString(r.ReplayedBy) > MaxClientIDLength:
+		return &ValidationError{Field: "replayed_by", Reason: fmt.Sprintf("must be at most %d characters", MaxClientIDLength)}
+	}
+	return nil
+}
+
+// MessageReplay records a failed message being moved back to pending
+type MessageReplay struct {
+	ID                   int64     `json:"id"`
+	MessageID            int64     `json:"message_id"`
+	ReplayedBy           string    `json:"replayed_by"`
+	ReplayedAt           time.Time `json:"replayed_at"`
+	PreviousPhoneNumber  string    `json:"previous_phone_number"`
+	PreviousContent      string    `json:"previous_content"`
+	PreviousAttemptCount int       `json:"previous_attempt_count"`
+	PhoneNumber          string    `json:"phone_number"`
+	Content              string    `json:"content"`
+}
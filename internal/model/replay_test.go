@@ -0,0 +1,49 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+func TestReplayRequest_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       model.ReplayRequest
+		wantField string
+	}{
+		{"by ids", model.ReplayRequest{IDs: []int64{1, 2}, ReplayedBy: "ops"}, ""},
+		{"by filter with edits", model.ReplayRequest{
+			Filter:      model.MessageFilter{PhoneNumber: "+123"},
+			PhoneNumber: "+456",
+			Content:     "fixed",
+			ReplayedBy:  "ops",
+		}, ""},
+		{"no selection", model.ReplayRequest{ReplayedBy: "ops"}, "ids"},
+		{"too many ids", model.ReplayRequest{IDs: make([]int64, model.MaxReplayIDs+1), ReplayedBy: "ops"}, "ids"},
+		{"status filter", model.ReplayRequest{
+			Filter:     model.MessageFilter{Statuses: []model.MessageStatus{model.StatusSent}},
+			ReplayedBy: "ops",
+		}, "status"},
+		{"invalid filter", model.ReplayRequest{Filter: model.MessageFilter{MinID: 5, MaxID: 2}, ReplayedBy: "ops"}, "min_id"},
+		{"invalid phone edit", model.ReplayRequest{IDs: []int64{1}, PhoneNumber: "abc", ReplayedBy: "ops"}, "phone_number"},
+		{"missing replayed_by", model.ReplayRequest{IDs: []int64{1}}, "replayed_by"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.req.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			var vErr *model.ValidationError
+			if !errors.As(err, &vErr) || vErr.Field != tt.wantField {
+				t.Errorf("expected ValidationError on %q, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
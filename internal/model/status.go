@@ -0,0 +1,62 @@
+package model
+
+import "slices"
+
+type MessageStatus string
+
+// Values match the status column so cached and queried messages agree
+const (
+	StatusPending   MessageStatus = "pending"
+	StatusSent      MessageStatus = "sent"
+	StatusFailed    MessageStatus = "failed"
+	StatusCancelled MessageStatus = "cancelled"
+	StatusExpired   MessageStatus = "expired"
+	// StatusUnknown marks a message whose send may or may not have reached the gateway,
+	// until the reconciler finds out whether it was accepted
+	StatusUnknown MessageStatus = "unknown"
+	// StatusDelivered and StatusUndelivered are the handset delivery outcome of a sent message,
+	// as reported by the gateway's delivery receipt
+	StatusDelivered   MessageStatus = "delivered"
+	StatusUndelivered MessageStatus = "undelivered"
+)
+
+// transitions lists the statuses a message may move to from each status; statuses without an entry are final.
+// The messages_status_transition trigger enforces the same table in the database (migrations 017 to 023).
+var transitions = map[MessageStatus][]MessageStatus{
+	StatusPending: {StatusSent, StatusFailed, StatusCancelled, StatusExpired, StatusUnknown},
+	// replaying a failed message queues it again
+	StatusFailed: {StatusPending},
+	// reconciliation either confirms the send or queues the message again, unless a delivery receipt arrives first;
+	// it gives up when the gateway keeps failing to tell
+	StatusUnknown: {StatusSent, StatusPending, StatusDelivered, StatusUndelivered, StatusFailed},
+	// a delivery receipt reports the final outcome
+	StatusSent: {StatusDelivered, StatusUndelivered},
+}
+
+// IsValid reports whether the status is one the messages table accepts
+func (s MessageStatus) IsValid() bool {
+	switch s {
+	case StatusPending, StatusSent, StatusFailed, StatusCancelled, StatusExpired, StatusUnknown,
+		StatusDelivered, StatusUndelivered:
+		return true
+	}
+	return false
+}
+
+// CanTransitionTo reports whether a message in status s may move to next
+func (s MessageStatus) CanTransitionTo(next MessageStatus) bool {
+	return slices.Contains(transitions[s], next)
+}
+
+// IsFinal reports whether a message in status s can no longer change its status
+func (s MessageStatus) IsFinal() bool {
+	return len(transitions[s]) == 0
+}
+
+// CheckTransition returns a *TransitionError when message id may not move from one status to the other
+func CheckTransition(id int64, from, to MessageStatus) error {
+	if !from.CanTransitionTo(to) {
+		return &TransitionError{MessageID: id, From: from, To: to}
+	}
+	return nil
+}
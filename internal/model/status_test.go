@@ -0,0 +1,75 @@
+package model_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+func TestMessageStatus_CanTransitionTo(t *testing.T) {
+	tests := []struct {
+		from, to model.MessageStatus
+		want     bool
+	}{
+		{model.StatusPending, model.StatusSent, true},
+		{model.StatusPending, model.StatusFailed, true},
+		{model.StatusPending, model.StatusCancelled, true},
+		{model.StatusPending, model.StatusExpired, true},
+		{model.StatusFailed, model.StatusPending, true},
+		{model.StatusPending, model.StatusUnknown, true},
+		{model.StatusUnknown, model.StatusSent, true},
+		{model.StatusUnknown, model.StatusPending, true},
+		{model.StatusUnknown, model.StatusDelivered, true},
+		{model.StatusUnknown, model.StatusUndelivered, true},
+		{model.StatusUnknown, model.StatusFailed, true},
+		{model.StatusUnknown, model.StatusCancelled, false},
+		{model.StatusPending, model.StatusPending, false},
+		{model.StatusSent, model.StatusPending, false},
+		{model.StatusSent, model.StatusDelivered, true},
+		{model.StatusSent, model.StatusUndelivered, true},
+		{model.StatusDelivered, model.StatusUndelivered, false},
+		{model.StatusPending, model.StatusDelivered, false},
+		{model.StatusSent, model.StatusFailed, false},
+		{model.StatusFailed, model.StatusSent, false},
+		{model.StatusCancelled, model.StatusPending, false},
+		{model.StatusExpired, model.StatusPending, false},
+	}
+
+	for _, tt := range tests {
+		if got := tt.from.CanTransitionTo(tt.to); got != tt.want {
+			t.Errorf("%s -> %s: got %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestMessageStatus_IsFinal(t *testing.T) {
+	for _, s := range []model.MessageStatus{model.StatusDelivered, model.StatusUndelivered, model.StatusCancelled, model.StatusExpired} {
+		if !s.IsFinal() {
+			t.Errorf("expected %s to be final", s)
+		}
+	}
+	for _, s := range []model.MessageStatus{model.StatusPending, model.StatusSent, model.StatusFailed, model.StatusUnknown} {
+		if s.IsFinal() {
+			t.Errorf("expected %s not to be final", s)
+		}
+	}
+}
+
+func TestCheckTransition(t *testing.T) {
+	if err := model.CheckTransition(1, model.StatusFailed, model.StatusPending); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	err := model.CheckTransition(1, model.StatusSent, model.StatusFailed)
+	var tErr *model.TransitionError
+	if !errors.As(err, &tErr) || tErr.MessageID != 1 || tErr.From != model.StatusSent || tErr.To != model.StatusFailed {
+		t.Fatalf("expected TransitionError, got %v", err)
+	}
+	if !errors.Is(err, model.ErrInvalidTransition) {
+		t.Errorf("expected error to match ErrInvalidTransition")
+	}
+	if err.Error() != "message 1 cannot move from sent to failed" {
+		t.Errorf("unexpected message: %s", err)
+	}
+}
@@ -0,0 +1,53 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// Limits mirror the column definitions of the messages table
+const (
+	MaxPhoneNumberLength    = 20
+	MaxContentLength        = 160
+	MaxClientIDLength       = 64
+	MaxIdempotencyKeyLength = 255
+	// MaxMessageAttempts bounds the per-message max_attempts override
+	MaxMessageAttempts = 100
+)
+
+var phoneNumberPattern = regexp.MustCompile(`^[0-9+]+$`)
+
+// ValidationError describes a message field rejected before reaching the database
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// ValidatePhoneNumber applies the `phone_number` CHECK constraint of the messages table
+func ValidatePhoneNumber(phone string) error {
+	switch {
+	case phone == "":
+		return &ValidationError{Field: "phone_number", Reason: "must not be empty"}
+	case utf8.RuneCountInString(phone) > MaxPhoneNumberLength:
+		return &ValidationError{Field: "phone_number", Reason: fmt.Sprintf("must be at most %d characters", MaxPhoneNumberLength)}
+	case !phoneNumberPattern.MatchString(phone):
+		return &ValidationError{Field: "phone_number", Reason: "must contain only digits and '+'"}
+	}
+	return nil
+}
+
+// ValidateContent applies the `content` column limits of the messages table
+func ValidateContent(content string) error {
+	switch {
+	case content == "":
+		return &ValidationError{Field: "content", Reason: "must not be empty"}
+	case utf8.RuneCountInString(content) > MaxContentLength:
+		return &ValidationError{Field: "content", Reason: fmt.Sprintf("must be at most %d characters", MaxContentLength)}
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package model_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+func TestNewMessage_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		msg       model.NewMessage
+		wantField string
+	}{
+		{"valid", model.NewMessage{PhoneNumber: "+905551112233", Content: "hello"}, ""},
+		{"empty phone", model.NewMessage{PhoneNumber: "", Content: "hello"}, "phone_number"},
+		{"phone with letters", model.NewMessage{PhoneNumber: "+90abc", Content: "hello"}, "phone_number"},
+		{"phone with spaces", model.NewMessage{PhoneNumber: "+90 555", Content: "hello"}, "phone_number"},
+		{"phone too long", model.NewMessage{PhoneNumber: strings.Repeat("1", 21), Content: "hello"}, "phone_number"},
+		{"empty content", model.NewMessage{PhoneNumber: "+90555", Content: ""}, "content"},
+		{"content too long", model.NewMessage{PhoneNumber: "+90555", Content: strings.Repeat("a", 161)}, "content"},
+		{"idempotency key too long", model.NewMessage{PhoneNumber: "+90555", Content: "hi", IdempotencyKey: strings.Repeat("k", 256)}, "idempotency_key"},
+		{"client id too long", model.NewMessage{PhoneNumber: "+90555", Content: "hi", ClientID: strings.Repeat("c", 65)}, "client_id"},
+		{"expiry before send_at", model.NewMessage{PhoneNumber: "+90555", Content: "hi", SendAt: time.Unix(200, 0), ExpiresAt: time.Unix(100, 0)}, "expires_at"},
+		{"negative max attempts", model.NewMessage{PhoneNumber: "+90555", Content: "hi", MaxAttempts: -1}, "max_attempts"},
+		{"max attempts too high", model.NewMessage{PhoneNumber: "+90555", Content: "hi", MaxAttempts: 101}, "max_attempts"},
+		{"callback url", model.NewMessage{PhoneNumber: "+90555", Content: "hi", CallbackURL: "https://producer.example.com/sms"}, ""},
+		{"relative callback url", model.NewMessage{PhoneNumber: "+90555", Content: "hi", CallbackURL: "/sms"}, "callback_url"},
+		{"callback url without http", model.NewMessage{PhoneNumber: "+90555", Content: "hi", CallbackURL: "ftp://producer.example.com"}, "callback_url"},
+		{"multibyte content at limit", model.NewMessage{PhoneNumber: "+90555", Content: strings.Repeat("ş", 160)}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.msg.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var vErr *model.ValidationError
+			if !errors.As(err, &vErr) {
+				t.Fatalf("expected ValidationError, got %v", err)
+			}
+			if vErr.Field != tt.wantField {
+				t.Errorf("Field = %q, want %q", vErr.Field, tt.wantField)
+			}
+		})
+	}
+}
+
+func TestMessageEdit_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		edit      model.MessageEdit
+		wantField string
+	}{
+		{"content only", model.MessageEdit{Content: "updated"}, ""},
+		{"phone only", model.MessageEdit{PhoneNumber: "+90555"}, ""},
+		{"nothing to change", model.MessageEdit{}, "phone_number"},
+		{"invalid phone", model.MessageEdit{PhoneNumber: "+90 555"}, "phone_number"},
+		{"content too long", model.MessageEdit{Content: strings.Repeat("a", 161)}, "content"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.edit.Validate()
+			if tt.wantField == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+
+			var vErr *model.ValidationError
+			if !errors.As(err, &vErr) || vErr.Field != tt.wantField {
+				t.Errorf("expected ValidationError on %q, got %v", tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestMessage_Expired(t *testing.T) {
+	now := time.Date(2025, 12, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		msg        model.Message
+		defaultTTL time.Duration
+		want       bool
+	}{
+		{"explicit expiry passed", model.Message{ExpiresAt: now.Add(-time.Second)}, 0, true},
+		{"explicit expiry ahead", model.Message{ExpiresAt: now.Add(time.Second), CreatedAt: now.Add(-time.Hour)}, time.Minute, false},
+		{"no expiry without default", model.Message{CreatedAt: now.Add(-24 * time.Hour)}, 0, false},
+		{"default ttl from creation", model.Message{CreatedAt: now.Add(-time.Hour)}, 30 * time.Minute, true},
+		{"default ttl from send_at", model.Message{CreatedAt: now.Add(-time.Hour), SendAt: now.Add(-time.Minute)}, 30 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.msg.Expired(now, tt.defaultTTL); got != tt.want {
+				t.Errorf("expected Expired=%v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+// CallbackRepository stores the callback URLs of producers and the outbox of status changes pushed to them.
+// Outbox rows are written by the messages_status_callback trigger in the transaction changing a message's status.
+type CallbackRepository interface {
+	SetClientCallback(ctx context.Context, cb model.ClientCallback) (*model.ClientCallback, error)
+	GetClientCallback(ctx context.Context, clientID string) (*model.ClientCallback, error)
+	DeleteClientCallback(ctx context.Context, clientID string) error
+	ClaimCallbacks(ctx context.Context, owner string, lease time.Duration, batchSize int) ([]model.CallbackEvent, error)
+	MarkCallbackDelivered(ctx context.Context, id int64, owner string, deliveredAt time.Time) error
+	RetryCallback(ctx context.Context, id int64, owner string, nextAttemptAt time.Time, dispatchError string) error
+	MarkCallbackFailed(ctx context.Context, id int64, owner string, dispatchError string) error
+}
+
+type PostgresCallbackRepository struct {
+	db *sql.DB
+}
+
+func NewPostgresCallbackRepository(db *sql.DB) CallbackRepository {
+	return &PostgresCallbackRepository{db: db}
+}
+
+// SetClientCallback registers or replaces the callback URL of a client. Messages changing status afterwards are
+// pushed to the new URL; events already in the outbox keep the URL they were queued with.
+func (r *PostgresCallbackRepository) SetClientCallback(ctx context.Context, cb model.ClientCallback) (*model.ClientCallback, error) {
+	if err := cb.Validate(); err != nil {
+		return nil, err
+	}
+
+	saved := model.ClientCallback{ClientID: cb.ClientID, URL: cb.URL}
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO client_callbacks (client_id, url)
+        VALUES ($1, $2)
+        ON CONFLICT (client_id) DO UPDATE
+            SET url = EXCLUDED.url,
+                updated_at = now() AT TIME ZONE 'UTC'
+        RETURNING updated_at
+    `, cb.ClientID, cb.URL).Scan(&saved.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+// GetClientCallback returns the callback URL registered for a client, or model.ErrCallbackNotFound
+func (r *PostgresCallbackRepository) GetClientCallback(ctx context.Context, clientID string) (*model.ClientCallback, error) {
+	cb := model.ClientCallback{ClientID: clientID}
+	err := r.db.QueryRowContext(ctx,
+		`SELECT url, updated_at FROM client_callbacks WHERE client_id = $1`, clientID).
+		Scan(&cb.URL, &cb.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrCallbackNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &cb, nil
+}
+
+// DeleteClientCallback stops pushing status changes of a client's messages that have no callback URL of their own
+func (r *PostgresCallbackRepository) DeleteClientCallback(ctx context.Context, clientID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM client_callbacks WHERE client_id = $1`, clientID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return model.ErrCallbackNotFound
+	}
+	return nil
+}
+
+// callbackColumns lists the outbox columns read by ClaimCallbacks, in order
+const callbackColumns = `id, message_id, url, status, previous_status, external_id, last_error, failure_reason,
+       occurred_at, attempt_count`
+
+// ClaimCallbacks leases up to batchSize due outbox events to owner, oldest first, utilizing
+// `FOR UPDATE SKIP LOCKED` so concurrent dispatchers never claim the same event.
+// An event waits while an earlier event of the same message is still pending, so producers receive the status
+// changes of a message in order. Events whose lease expired without an outcome being recorded are claimed again.
+func (r *PostgresCallbackRepository) ClaimCallbacks(
+	ctx context.Context,
+	owner string,
+	lease time.Duration,
+	batchSize int,
+) ([]model.CallbackEvent, error) {
+	rows, err := r.db.QueryContext(ctx, `
+        WITH claimed AS (
+            UPDATE callback_events
+            SET claimed_by = $1,
+                lease_until = now() AT TIME ZONE 'UTC' + make_interval(secs => $2::float8)
+            WHERE id IN (
+                SELECT id FROM callback_events
+                WHERE state = 'pending'
+                  AND (next_attempt_at IS NULL OR next_attempt_at <= now() AT TIME ZONE 'UTC')
+                  AND `+leaseFree+`
+                  AND NOT EXISTS (
+                      SELECT 1 FROM callback_events earlier
+                      WHERE earlier.message_id = callback_events.message_id
+                        AND earlier.state = 'pending'
+                        AND earlier.id < callback_events.id
+                  )
+                ORDER BY id
+                LIMIT $3
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING `+callbackColumns+`
+        )
+        SELECT `+callbackColumns+` FROM claimed ORDER BY id`,
+		owner, lease.Seconds(), batchSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []model.CallbackEvent
+	for rows.Next() {
+		var (
+			e             model.CallbackEvent
+			externalID    sql.NullString
+			lastError     sql.NullString
+			failureReason sql.NullString
+		)
+		if err := rows.Scan(&e.ID, &e.MessageID, &e.URL, &e.Status, &e.PreviousStatus, &externalID, &lastError,
+			&failureReason, &e.OccurredAt, &e.AttemptCount); err != nil {
+			return nil, err
+		}
+		e.ExternalID = externalID.String
+		e.LastError = lastError.String
+		e.FailureReason = model.FailureReason(failureReason.String)
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// MarkCallbackDelivered records that the producer acknowledged an event leased to owner
+func (r *PostgresCallbackRepository) MarkCallbackDelivered(ctx context.Context, id int64, owner string, deliveredAt time.Time) error {
+	return r.releaseCallback(ctx, id, owner, `
+        state = 'delivered',
+        attempt_count = attempt_count + 1,
+        delivered_at = $3`, deliveredAt.UTC())
+}
+
+// RetryCallback records a failed delivery of an event leased to owner and holds it back until nextAttemptAt
+func (r *PostgresCallbackRepository) RetryCallback(ctx context.Context, id int64, owner string, nextAttemptAt time.Time, dispatchError string) error {
+	return r.releaseCallback(ctx, id, owner, `
+        attempt_count = attempt_count + 1,
+        next_attempt_at = $3,
+        dispatch_error = $4`, nullTime(nextAttemptAt), dispatchError)
+}
+
+// MarkCallbackFailed gives up on an event leased to owner after its last failed delivery
+func (r *PostgresCallbackRepository) MarkCallbackFailed(ctx context.Context, id int64, owner string, dispatchError string) error {
+	return r.releaseCallback(ctx, id, owner, `
+        state = 'failed',
+        attempt_count = attempt_count + 1,
+        dispatch_error = $3`, dispatchError)
+}
+
+// releaseCallback applies set, whose arguments start at $3, to a pending event still leased to owner and clears
+// the lease. It returns model.ErrLeaseLost when another dispatcher took over the event.
+func (r *PostgresCallbackRepository) releaseCallback(ctx context.Context, id int64, owner string, set string, args ...any) error {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE callback_events
+        SET `+set+`,
+            claimed_by = NULL,
+            lease_until = NULL
+        WHERE id = $1 AND claimed_by = $2 AND state = 'pending'`,
+		append([]any{id, owner}, args...)...,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return fmt.Errorf("callback event %d: %w", id, model.ErrLeaseLost)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+)
+
+func TestPostgresCallbackRepository_ClientCallbacks(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresCallbackRepository(db)
+	updated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`INSERT INTO client_callbacks \(client_id, url\)\s+VALUES \(\$1, \$2\)\s+ON CONFLICT \(client_id\) DO UPDATE`).
+		WithArgs("billing", "https://billing.example.com/sms").
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}).AddRow(updated))
+	mock.ExpectQuery(`SELECT url, updated_at FROM client_callbacks WHERE client_id = \$1`).
+		WithArgs("unknown").
+		WillReturnRows(sqlmock.NewRows([]string{"url", "updated_at"}))
+	mock.ExpectExec(`DELETE FROM client_callbacks WHERE client_id = \$1`).
+		WithArgs("unknown").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	cb, err := repo.SetClientCallback(context.Background(), model.ClientCallback{ClientID: "billing", URL: "https://billing.example.com/sms"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if cb.ClientID != "billing" || !cb.UpdatedAt.Equal(updated) {
+		t.Errorf("unexpected callback: %+v", cb)
+	}
+
+	if _, err := repo.GetClientCallback(context.Background(), "unknown"); !errors.Is(err, model.ErrCallbackNotFound) {
+		t.Errorf("expected ErrCallbackNotFound, got %v", err)
+	}
+	if err := repo.DeleteClientCallback(context.Background(), "unknown"); !errors.Is(err, model.ErrCallbackNotFound) {
+		t.Errorf("expected ErrCallbackNotFound, got %v", err)
+	}
+
+	// rejected before reaching the database
+	var vErr *model.ValidationError
+	if _, err := repo.SetClientCallback(context.Background(), model.ClientCallback{ClientID: "billing", URL: "billing"}); !errors.As(err, &vErr) {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresCallbackRepository_ClaimCallbacks(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresCallbackRepository(db)
+	occurred := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery(`UPDATE callback_events\s+SET claimed_by = \$1,(?s).*WHERE state = 'pending'(?s).*`+
+		`AND NOT EXISTS \(\s+SELECT 1 FROM callback_events earlier\s+WHERE earlier.message_id = callback_events.message_id(?s).*`+
+		`ORDER BY id\s+LIMIT \$3\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs("dispatcher-1", float64(60), 50).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "message_id", "url", "status", "previous_status", "external_id",
+			"last_error", "failure_reason", "occurred_at", "attempt_count"}).
+			AddRow(int64(1), int64(7), "https://billing.example.com/sms", "sent", "pending", "ext-7", nil, nil, occurred, 0).
+			AddRow(int64(2), int64(8), "https://billing.example.com/sms", "failed", "pending", nil, "bad request", "unrecoverable_error", occurred, 2))
+
+	events, err := repo.ClaimCallbacks(context.Background(), "dispatcher-1", time.Minute, 50)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := []model.CallbackEvent{
+		{ID: 1, MessageID: 7, URL: "https://billing.example.com/sms", Status: model.StatusSent, PreviousStatus: model.StatusPending,
+			ExternalID: "ext-7", OccurredAt: occurred},
+		{ID: 2, MessageID: 8, URL: "https://billing.example.com/sms", Status: model.StatusFailed, PreviousStatus: model.StatusPending,
+			LastError: "bad request", FailureReason: model.FailureUnrecoverable, OccurredAt: occurred, AttemptCount: 2},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %d", len(want), len(events))
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d = %+v, want %+v", i, events[i], want[i])
+		}
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresCallbackRepository_ReleaseCallback(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresCallbackRepository(db)
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE callback_events\s+SET\s+state = 'delivered',\s+attempt_count = attempt_count \+ 1,\s+delivered_at = \$3,\s+`+
+		`claimed_by = NULL,\s+lease_until = NULL\s+WHERE id = \$1 AND claimed_by = \$2 AND state = 'pending'`).
+		WithArgs(int64(1), "dispatcher-1", now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE callback_events\s+SET\s+attempt_count = attempt_count \+ 1,\s+next_attempt_at = \$3,\s+dispatch_error = \$4`).
+		WithArgs(int64(2), "dispatcher-1", now.Add(time.Minute), "unexpected status code: 503").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// another dispatcher took over the expired lease
+	mock.ExpectExec(`UPDATE callback_events\s+SET\s+state = 'failed'`).
+		WithArgs(int64(3), "dispatcher-1", "connection refused").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if err := repo.MarkCallbackDelivered(context.Background(), 1, "dispatcher-1", now); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := repo.RetryCallback(context.Background(), 2, "dispatcher-1", now.Add(time.Minute), "unexpected status code: 503"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := repo.MarkCallbackFailed(context.Background(), 3, "dispatcher-1", "connection refused"); !errors.Is(err, model.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lib/pq"
+)
+
+// whereBuilder accumulates SQL conditions joined by AND together with their positional arguments
+type whereBuilder struct {
+	conds []string
+	args  []any
+}
+
+// add appends a condition whose single `%d` verb is replaced by the argument's placeholder index
+func (b *whereBuilder) add(cond string, arg any) {
+	b.args = append(b.args, arg)
+	b.conds = append(b.conds, fmt.Sprintf(cond, len(b.args)))
+}
+
+// addFilter appends a condition for every criterion set on the filter
+func (b *whereBuilder) addFilter(f model.MessageFilter) {
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, len(f.Statuses))
+		for i, s := range f.Statuses {
+			statuses[i] = string(s)
+		}
+		b.add("status = ANY($%d)", pq.Array(statuses))
+	}
+	if len(f.FailureReasons) > 0 {
+		reasons := make([]string, len(f.FailureReasons))
+		for i, r := range f.FailureReasons {
+			reasons[i] = string(r)
+		}
+		b.add("failure_reason = ANY($%d)", pq.Array(reasons))
+	}
+	if f.PhoneNumber != "" {
+		b.add("phone_number = $%d", f.PhoneNumber)
+	}
+	if f.MinID != 0 {
+		b.add("id >= $%d", f.MinID)
+	}
+	if f.MaxID != 0 {
+		b.add("id <= $%d", f.MaxID)
+	}
+	// timestamps are stored without time zone in UTC
+	if !f.CreatedFrom.IsZero() {
+		b.add("created_at >= $%d", f.CreatedFrom.UTC())
+	}
+	if !f.CreatedTo.IsZero() {
+		b.add("created_at < $%d", f.CreatedTo.UTC())
+	}
+	if !f.SentFrom.IsZero() {
+		b.add("sent_time >= $%d", f.SentFrom.UTC())
+	}
+	if !f.SentTo.IsZero() {
+		b.add("sent_time < $%d", f.SentTo.UTC())
+	}
+	if f.MinAttempts != nil {
+		b.add("attempt_count >= $%d", *f.MinAttempts)
+	}
+	if f.MaxAttempts != nil {
+		b.add("attempt_count <= $%d", *f.MaxAttempts)
+	}
+	if f.Scheduled != nil {
+		if *f.Scheduled {
+			b.add("send_at > $%d", time.Now().UTC())
+		} else {
+			b.add("(send_at IS NULL OR send_at <= $%d)", time.Now().UTC())
+		}
+	}
+}
+
+// where renders the accumulated conditions, or TRUE when there are none
+func (b *whereBuilder) where() string {
+	if len(b.conds) == 0 {
+		return "TRUE"
+	}
+	return strings.Join(b.conds, " AND ")
+}
+
+// placeholder appends an argument that is referenced outside of the WHERE clause
+func (b *whereBuilder) placeholder(arg any) string {
+	b.args = append(b.args, arg)
+	return fmt.Sprintf("$%d", len(b.args))
+}
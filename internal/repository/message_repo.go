@@ -3,16 +3,37 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"iter"
+	"strconv"
 	"time"
 
 	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lib/pq"
 )
 
 type MessageRepository interface {
-	FetchPendingTx(ctx context.Context, batchSize int) ([]model.Message, *sql.Tx, error)
-	MarkAsSentTx(ctx context.Context, tx *sql.Tx, id int64, externalID string, sentTime time.Time) error
-	MarkAsFailedTx(ctx context.Context, tx *sql.Tx, id int64) error
-	IncrementAttemptTx(ctx context.Context, tx *sql.Tx, id int64) error
+	CreateMessage(ctx context.Context, msg model.NewMessage) (*model.Message, error)
+	CreateMessageIdempotent(ctx context.Context, msg model.NewMessage, keyTTL time.Duration) (*model.Message, bool, error)
+	CopyMessages(ctx context.Context, msgs iter.Seq2[model.NewMessage, error]) (int64, error)
+	ClaimPending(ctx context.Context, owner string, lease time.Duration, batchSize int,
+		quotas map[model.MessagePriority]int) ([]model.Message, error)
+	MarkAsSent(ctx context.Context, id int64, owner string, externalID string, sentTime time.Time) error
+	MarkAsFailed(ctx context.Context, id int64, owner string, reason model.FailureReason, lastError string) error
+	MarkAsExpired(ctx context.Context, id int64, owner string) error
+	ExpirePending(ctx context.Context, defaultTTL time.Duration) (int64, error)
+	IncrementAttempt(ctx context.Context, id int64, owner string, nextAttemptAt time.Time, lastError string) error
+	MarkAsUnknown(ctx context.Context, id int64, owner string, checkAt time.Time, lastError string) error
+	ClaimUnknown(ctx context.Context, owner string, lease time.Duration, batchSize int) ([]model.Message, error)
+	ConfirmSent(ctx context.Context, id int64, owner string, externalID string, sentTime time.Time) error
+	RequeueUnknown(ctx context.Context, id int64, owner string) error
+	RecordFailedCheck(ctx context.Context, id int64, owner string, lastError string, maxChecks int) (bool, error)
+	RecordDelivery(ctx context.Context, receipt model.DeliveryReceipt) (*model.Message, error)
+	RecordAttempt(ctx context.Context, attempt model.MessageAttempt) error
+	ReplayMessages(ctx context.Context, req model.ReplayRequest) ([]model.Message, error)
+	CancelMessage(ctx context.Context, id int64) (*model.Message, error)
+	EditMessage(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error)
+	CancelMessages(ctx context.Context, filter model.MessageFilter) (int64, error)
 }
 
 type PostgresMessageRepository struct {
@@ -23,71 +44,717 @@ func NewPostgresMessageRepository(db *sql.DB) MessageRepository {
 	return &PostgresMessageRepository{db: db}
 }
 
-// FetchPendingTx
-// utilizing `FOR UPDATE SKIP LOCKED` to prevent race conditions and ensure reliability in a multi-instance environment
-func (r *PostgresMessageRepository) FetchPendingTx(ctx context.Context, batchSize int) ([]model.Message, *sql.Tx, error) {
-	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{
-		Isolation: sql.LevelRepeatableRead,
-	})
+// CreateMessage validates and inserts a new pending message, returning it with its generated ID
+func (r *PostgresMessageRepository) CreateMessage(ctx context.Context, msg model.NewMessage) (*model.Message, error) {
+	if err := msg.Validate(); err != nil {
+		return nil, err
+	}
+
+	created := model.Message{
+		PhoneNumber: msg.PhoneNumber,
+		Content:     msg.Content,
+		ClientID:    msg.ClientID,
+		SendAt:      nullTime(msg.SendAt).Time,
+		ExpiresAt:   nullTime(msg.ExpiresAt).Time,
+		Priority:    msg.Priority.OrDefault(),
+		MaxAttempts: msg.MaxAttempts,
+		CallbackURL: msg.CallbackURL,
+	}
+	err := r.db.QueryRowContext(ctx, `
+        INSERT INTO messages (phone_number, content, client_id, send_at, expires_at, priority, max_attempts,
+                              callback_url)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+        RETURNING id, status, attempt_count, created_at
+    `, msg.PhoneNumber, msg.Content, msg.ClientID, nullTime(msg.SendAt), nullTime(msg.ExpiresAt), created.Priority,
+		nullInt(msg.MaxAttempts), nullString(msg.CallbackURL)).
+		Scan(&created.ID, &created.Status, &created.AttemptCount, &created.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &created, nil
+}
+
+// CreateMessageIdempotent inserts a new pending message unless the client already used the idempotency key
+// within keyTTL, in which case the originally created message is returned with replayed set to true.
+// A key older than keyTTL is released from its message so it can be reused; a non-positive keyTTL never expires keys.
+func (r *PostgresMessageRepository) CreateMessageIdempotent(
+	ctx context.Context,
+	msg model.NewMessage,
+	keyTTL time.Duration,
+) (*model.Message, bool, error) {
+	if err := msg.Validate(); err != nil {
+		return nil, false, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	// the row lock serializes concurrent requests replaying the same key
+	existing, live, err := findByIdempotencyKey(ctx, tx, msg.ClientID, msg.IdempotencyKey, keyTTL, true)
+	switch {
+	case err == nil && live:
+		return replayIdempotent(existing, msg)
+	case err == nil:
+		if _, err := tx.ExecContext(ctx,
+			`UPDATE messages SET idempotency_key = NULL WHERE id = $1`, existing.ID); err != nil {
+			return nil, false, err
+		}
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, false, err
+	}
+
+	created := model.Message{
+		PhoneNumber:    msg.PhoneNumber,
+		Content:        msg.Content,
+		ClientID:       msg.ClientID,
+		IdempotencyKey: msg.IdempotencyKey,
+		SendAt:         nullTime(msg.SendAt).Time,
+		ExpiresAt:      nullTime(msg.ExpiresAt).Time,
+		Priority:       msg.Priority.OrDefault(),
+		MaxAttempts:    msg.MaxAttempts,
+		CallbackURL:    msg.CallbackURL,
+	}
+	err = tx.QueryRowContext(ctx, `
+        INSERT INTO messages (phone_number, content, client_id, idempotency_key, send_at, expires_at, priority,
+                              max_attempts, callback_url)
+        VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+        ON CONFLICT (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
+        RETURNING id, status, attempt_count, created_at
+    `, msg.PhoneNumber, msg.Content, msg.ClientID, msg.IdempotencyKey, nullTime(msg.SendAt), nullTime(msg.ExpiresAt),
+		created.Priority, nullInt(msg.MaxAttempts), nullString(msg.CallbackURL)).
+		Scan(&created.ID, &created.Status, &created.AttemptCount, &created.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// a concurrent request inserted the same key first and has committed by now
+		existing, _, err := findByIdempotencyKey(ctx, tx, msg.ClientID, msg.IdempotencyKey, keyTTL, false)
+		if err != nil {
+			return nil, false, err
+		}
+		return replayIdempotent(existing, msg)
+	}
 	if err != nil {
-		return nil, nil, err
+		return nil, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
 	}
+	return &created, false, nil
+}
 
-	rows, err := tx.QueryContext(ctx,
-		`SELECT id, phone_number, content, status 
-         FROM messages 
-         WHERE status = 'pending' 
-         ORDER BY id 
-         LIMIT $1 
-         FOR UPDATE SKIP LOCKED`,
-		batchSize,
+// findByIdempotencyKey loads the message holding the key and reports whether the key is still within keyTTL
+func findByIdempotencyKey(
+	ctx context.Context,
+	tx *sql.Tx,
+	clientID, key string,
+	keyTTL time.Duration,
+	lock bool,
+) (*model.Message, bool, error) {
+	query := `
+        SELECT id, phone_number, content, status, attempt_count, created_at, client_id, idempotency_key, send_at,
+               expires_at, priority, max_attempts, callback_url,
+               ($3::float8 <= 0 OR created_at > (now() AT TIME ZONE 'UTC') - make_interval(secs => $3::float8)) AS live
+        FROM messages
+        WHERE client_id = $1 AND idempotency_key = $2`
+	if lock {
+		query += ` FOR UPDATE`
+	}
+
+	var (
+		m           model.Message
+		sendAt      sql.NullTime
+		expiresAt   sql.NullTime
+		maxAttempts sql.NullInt64
+		callbackURL sql.NullString
+		live        bool
+	)
+	err := tx.QueryRowContext(ctx, query, clientID, key, keyTTL.Seconds()).Scan(
+		&m.ID, &m.PhoneNumber, &m.Content, &m.Status, &m.AttemptCount, &m.CreatedAt, &m.ClientID, &m.IdempotencyKey,
+		&sendAt, &expiresAt, &m.Priority, &maxAttempts, &callbackURL, &live,
 	)
 	if err != nil {
-		tx.Rollback()
-		return nil, nil, err
+		return nil, false, err
+	}
+	m.SendAt = sendAt.Time
+	m.ExpiresAt = expiresAt.Time
+	m.MaxAttempts = int(maxAttempts.Int64)
+	m.CallbackURL = callbackURL.String
+	return &m, live, nil
+}
+
+// replayIdempotent returns the original message if the replayed request carries the same payload,
+// including its schedule, priority and delivery options
+func replayIdempotent(existing *model.Message, msg model.NewMessage) (*model.Message, bool, error) {
+	if existing.PhoneNumber != msg.PhoneNumber || existing.Content != msg.Content ||
+		!sameTime(existing.SendAt, msg.SendAt) || !sameTime(existing.ExpiresAt, msg.ExpiresAt) ||
+		existing.Priority != msg.Priority.OrDefault() || existing.MaxAttempts != msg.MaxAttempts ||
+		existing.CallbackURL != msg.CallbackURL {
+		return nil, false, model.ErrIdempotencyKeyReused
+	}
+	return existing, true, nil
+}
+
+// sameTime compares a stored timestamp with a requested one at the microsecond precision Postgres keeps
+func sameTime(stored, requested time.Time) bool {
+	return stored.Equal(requested.Round(time.Microsecond))
+}
+
+// CopyMessages streams messages into the table using `COPY FROM STDIN` within a single transaction.
+// Messages are expected to be validated by the caller; the first error yielded by msgs aborts the whole copy.
+func (r *PostgresMessageRepository) CopyMessages(ctx context.Context, msgs iter.Seq2[model.NewMessage, error]) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("messages", "phone_number", "content", "client_id", "send_at",
+		"expires_at", "priority", "max_attempts", "callback_url"))
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var copied int64
+	for msg, err := range msgs {
+		if err != nil {
+			return 0, err
+		}
+		_, err := stmt.ExecContext(ctx, msg.PhoneNumber, msg.Content, msg.ClientID, nullTime(msg.SendAt),
+			nullTime(msg.ExpiresAt), msg.Priority.OrDefault(), nullInt(msg.MaxAttempts), nullString(msg.CallbackURL))
+		if err != nil {
+			return 0, err
+		}
+		copied++
+	}
+
+	// an argument-less Exec flushes the buffered rows to the server
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return 0, err
+	}
+	if err := stmt.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return copied, nil
+}
+
+// leaseFree matches rows not leased to a relayer, including rows whose lease ran out because the relayer crashed
+const leaseFree = `(lease_until IS NULL OR lease_until <= now() AT TIME ZONE 'UTC')`
+
+// pendingDue matches pending messages whose send_at and retry backoff, if any, have passed and that are not leased
+const pendingDue = `status = 'pending'
+           AND (send_at IS NULL OR send_at <= now() AT TIME ZONE 'UTC')
+           AND (next_attempt_at IS NULL OR next_attempt_at <= now() AT TIME ZONE 'UTC')
+           AND ` + leaseFree
+
+// unknownDue matches messages with an unknown outcome that are due to be checked and not leased
+const unknownDue = `status = 'unknown'
+           AND (next_attempt_at IS NULL OR next_attempt_at <= now() AT TIME ZONE 'UTC')
+           AND ` + leaseFree
+
+// ClaimPending leases up to batchSize due messages to owner for the lease duration in one short transaction,
+// utilizing `FOR UPDATE SKIP LOCKED` so concurrent relayers never claim the same message.
+// Every priority first claims up to its quota in ID order; slots left over go to the remaining due messages
+// ordered by priority and ID, so an idle lane never wastes part of the batch.
+// Messages whose lease expired without an outcome being recorded are claimed again.
+func (r *PostgresMessageRepository) ClaimPending(
+	ctx context.Context,
+	owner string,
+	lease time.Duration,
+	batchSize int,
+	quotas map[model.MessagePriority]int,
+) ([]model.Message, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var msgs []model.Message
+	for _, p := range model.Priorities {
+		n := min(quotas[p], batchSize-len(msgs))
+		if n <= 0 {
+			continue
+		}
+		claimed, err := claimMessages(ctx, tx, owner, lease, n, pendingDue+" AND priority = $4", "id", p)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, claimed...)
+	}
+
+	// rows leased above are no longer due, so the fill does not claim them twice
+	if rest := batchSize - len(msgs); rest > 0 {
+		claimed, err := claimMessages(ctx, tx, owner, lease, rest, pendingDue, "priority DESC, id")
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, claimed...)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return msgs, nil
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// claimMessages leases up to limit messages matching cond, which may refer to args from $4 on
+func claimMessages(
+	ctx context.Context,
+	q querier,
+	owner string,
+	lease time.Duration,
+	limit int,
+	cond, order string,
+	args ...any,
+) ([]model.Message, error) {
+	rows, err := q.QueryContext(ctx, `
+        WITH claimed AS (
+            UPDATE messages
+            SET claimed_by = $1,
+                lease_until = now() AT TIME ZONE 'UTC' + make_interval(secs => $2::float8)
+            WHERE id IN (
+                SELECT id FROM messages
+                WHERE `+cond+`
+                ORDER BY `+order+`
+                LIMIT $3
+                FOR UPDATE SKIP LOCKED
+            )
+            RETURNING `+messageColumns+`
+        )
+        SELECT `+messageColumns+` FROM claimed ORDER BY `+order,
+		append([]any{owner, lease.Seconds(), limit}, args...)...,
+	)
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
 	var msgs []model.Message
 	for rows.Next() {
-		var m model.Message
-		if err := rows.Scan(&m.ID, &m.PhoneNumber, &m.Content, &m.Status); err != nil {
-			tx.Rollback()
-			return nil, nil, err
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
 		}
 		msgs = append(msgs, m)
 	}
+	return msgs, rows.Err()
+}
 
-	return msgs, tx, nil
+// IncrementAttempt records a failed attempt, holds the message back until nextAttemptAt and releases the lease
+func (r *PostgresMessageRepository) IncrementAttempt(ctx context.Context, id int64, owner string, nextAttemptAt time.Time, lastError string) error {
+	return r.releaseLease(ctx, id, owner, model.StatusPending, model.StatusPending, `
+        attempt_count = attempt_count + 1,
+        next_attempt_at = $4,
+        last_error = $5`, nullTime(nextAttemptAt), lastError)
 }
 
-func (r *PostgresMessageRepository) IncrementAttemptTx(ctx context.Context, tx *sql.Tx, id int64) error {
-	_, err := tx.ExecContext(ctx, `
-        UPDATE messages
-        SET attempt_count = attempt_count + 1
-        WHERE id = $1
-    `, id)
-	return err
+// MarkAsSent records the gateway's external ID for a message leased to owner
+func (r *PostgresMessageRepository) MarkAsSent(
+	ctx context.Context,
+	id int64,
+	owner string,
+	externalID string,
+	sentTime time.Time,
+) error {
+	return r.releaseLease(ctx, id, owner, model.StatusPending, model.StatusSent, `
+        external_id = $4,
+        sent_time = $5`, externalID, sentTime.UTC())
+}
+
+// MarkAsUnknown records a send that may have reached the gateway without telling whether it was accepted.
+// The message is left to the reconciler, which asks the gateway about it from checkAt on.
+func (r *PostgresMessageRepository) MarkAsUnknown(ctx context.Context, id int64, owner string, checkAt time.Time, lastError string) error {
+	return r.releaseLease(ctx, id, owner, model.StatusPending, model.StatusUnknown, `
+        attempt_count = attempt_count + 1,
+        status_checks = 0,
+        next_attempt_at = $4,
+        last_error = $5`, nullTime(checkAt), lastError)
 }
 
-func (r *PostgresMessageRepository) MarkAsSentTx(
+// ClaimUnknown leases up to batchSize messages with an unknown outcome that are due to be checked to owner
+func (r *PostgresMessageRepository) ClaimUnknown(
+	ctx context.Context,
+	owner string,
+	lease time.Duration,
+	batchSize int,
+) ([]model.Message, error) {
+	return claimMessages(ctx, r.db, owner, lease, batchSize, unknownDue, "id")
+}
+
+// ConfirmSent records that the gateway did accept a message with an unknown outcome leased to owner
+func (r *PostgresMessageRepository) ConfirmSent(
 	ctx context.Context,
-	tx *sql.Tx,
 	id int64,
+	owner string,
 	externalID string,
 	sentTime time.Time,
 ) error {
-	_, err := tx.ExecContext(ctx, `
+	return r.releaseLease(ctx, id, owner, model.StatusUnknown, model.StatusSent, `
+        external_id = $4,
+        sent_time = $5,
+        next_attempt_at = NULL`, externalID, sentTime.UTC())
+}
+
+// RequeueUnknown queues a message with an unknown outcome leased to owner to be sent again right away,
+// once the gateway confirmed that it never accepted it
+func (r *PostgresMessageRepository) RequeueUnknown(ctx context.Context, id int64, owner string) error {
+	return r.releaseLease(ctx, id, owner, model.StatusUnknown, model.StatusPending, `
+        next_attempt_at = NULL`)
+}
+
+// RecordFailedCheck counts a failed status lookup of a message with an unknown outcome leased to owner. The message
+// stays claimed, so it is checked again once the lease runs out, until maxChecks lookups failed and it is failed with
+// model.FailureUnknownOutcome. It reports whether the message was failed.
+func (r *PostgresMessageRepository) RecordFailedCheck(
+	ctx context.Context,
+	id int64,
+	owner string,
+	lastError string,
+	maxChecks int,
+) (bool, error) {
+	var status model.MessageStatus
+	err := r.db.QueryRowContext(ctx, `
         UPDATE messages
-        SET status = 'sent',
-            external_id = $2,
-            sent_time = $3
-        WHERE id = $1
-    `, id, externalID, sentTime)
-	return err
+        SET status_checks = status_checks + 1,
+            last_error = $3,
+            status = CASE WHEN status_checks + 1 >= $4 THEN $5 ELSE status END,
+            failure_reason = CASE WHEN status_checks + 1 >= $4 THEN $6 ELSE failure_reason END,
+            claimed_by = CASE WHEN status_checks + 1 >= $4 THEN NULL ELSE claimed_by END,
+            lease_until = CASE WHEN status_checks + 1 >= $4 THEN NULL ELSE lease_until END
+        WHERE id = $1 AND claimed_by = $2 AND status = $7
+        RETURNING status`,
+		id, owner, lastError, maxChecks, string(model.StatusFailed), string(model.FailureUnknownOutcome),
+		string(model.StatusUnknown),
+	).Scan(&status)
+	if err == nil {
+		return status == model.StatusFailed, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return false, err
+	}
+
+	current, err := r.currentStatus(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if current != model.StatusUnknown {
+		return false, &model.TransitionError{MessageID: id, From: current, To: model.StatusFailed}
+	}
+	return false, model.ErrLeaseLost
+}
+
+// MarkAsFailed gives up on a message leased to owner. An empty lastError keeps the error of the previous attempt,
+// e.g. when a message is failed for exceeding its attempts without being sent again.
+func (r *PostgresMessageRepository) MarkAsFailed(ctx context.Context, id int64, owner string, reason model.FailureReason, lastError string) error {
+	return r.releaseLease(ctx, id, owner, model.StatusPending, model.StatusFailed, `
+        failure_reason = $4,
+        last_error = COALESCE($5, last_error)`, string(reason), nullString(lastError))
+}
+
+func (r *PostgresMessageRepository) MarkAsExpired(ctx context.Context, id int64, owner string) error {
+	return r.releaseLease(ctx, id, owner, model.StatusPending, model.StatusExpired, "")
+}
+
+// RecordDelivery moves the sent message the gateway accepted as receipt.ExternalID to the delivery status of the
+// receipt. When the receipt names the message by its delivery key, a message whose send outcome is still unknown is
+// moved as well: it takes the external ID, its last attempt becomes the sent time and a pending status check is
+// dropped. A receipt repeating the status the message already has returns the message unchanged, since gateways
+// retry receipts. It returns model.ErrNotFound for an unknown external ID and a *model.TransitionError when the
+// message is in any other status.
+func (r *PostgresMessageRepository) RecordDelivery(ctx context.Context, receipt model.DeliveryReceipt) (*model.Message, error) {
+	row := r.db.QueryRowContext(ctx, `
+        UPDATE messages
+        SET status = $2,
+            delivered_time = $3,
+            last_error = COALESCE($4, last_error)
+        WHERE external_id = $1 AND status = 'sent'
+        RETURNING `+messageColumns,
+		receipt.ExternalID, string(receipt.Status), nullTime(receipt.DeliveredAt), nullString(receipt.Error))
+	m, err := scanMessage(row)
+	if err == nil {
+		return &m, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	if receipt.MessageID > 0 {
+		row := r.db.QueryRowContext(ctx, `
+        UPDATE messages
+        SET status = $3,
+            delivered_time = $4,
+            last_error = COALESCE($5, last_error),
+            external_id = $6,
+            sent_time = COALESCE((SELECT max(attempted_at) FROM message_attempts WHERE message_id = $1), $4),
+            next_attempt_at = NULL,
+            claimed_by = NULL,
+            lease_until = NULL
+        WHERE id = $1 AND delivery_generation = $2 AND status = 'unknown'
+        RETURNING `+messageColumns,
+			receipt.MessageID, receipt.DeliveryGeneration, string(receipt.Status), nullTime(receipt.DeliveredAt),
+			nullString(receipt.Error), receipt.ExternalID)
+		m, err := scanMessage(row)
+		if err == nil {
+			return &m, nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return nil, err
+		}
+	}
+
+	m, err = scanMessage(r.db.QueryRowContext(ctx,
+		`SELECT `+messageColumns+` FROM messages WHERE external_id = $1`, receipt.ExternalID))
+	if errors.Is(err, sql.ErrNoRows) && receipt.MessageID > 0 {
+		m, err = scanMessage(r.db.QueryRowContext(ctx,
+			`SELECT `+messageColumns+` FROM messages WHERE id = $1 AND delivery_generation = $2`,
+			receipt.MessageID, receipt.DeliveryGeneration))
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if m.Status == receipt.Status {
+		return &m, nil
+	}
+	return nil, &model.TransitionError{MessageID: m.ID, From: m.Status, To: receipt.Status}
 }
 
-func (r *PostgresMessageRepository) MarkAsFailedTx(ctx context.Context, tx *sql.Tx, id int64) error {
-	_, err := tx.ExecContext(ctx, `UPDATE messages SET status='failed' WHERE id=$1`, id)
+// RecordAttempt stores the outcome of a single send attempt in the message's attempt history
+func (r *PostgresMessageRepository) RecordAttempt(ctx context.Context, attempt model.MessageAttempt) error {
+	_, err := r.db.ExecContext(ctx, `
+        INSERT INTO message_attempts (message_id, attempted_at, status_code, error, recoverable, external_id, duration_ms)
+        VALUES ($1, $2, $3, $4, $5, $6, $7)
+    `, attempt.MessageID, attempt.AttemptedAt.UTC(), nullInt(attempt.StatusCode), nullString(attempt.Error),
+		attempt.Recoverable, nullString(attempt.ExternalID), attempt.DurationMS)
 	return err
 }
+
+// releaseLease moves a message in status from still leased to owner to status to, applies set, whose arguments
+// start at $4, and clears the lease. Staying in the same status records a retry. It returns a *model.TransitionError
+// when the message already left from, e.g. because another relayer took over an expired lease and sent it, and
+// model.ErrLeaseLost when it is still in from but leased to someone else.
+func (r *PostgresMessageRepository) releaseLease(ctx context.Context, id int64, owner string, from, to model.MessageStatus,
+	set string, args ...any) error {
+	if to != from {
+		if err := model.CheckTransition(id, from, to); err != nil {
+			return err
+		}
+	}
+	if set != "" {
+		set += ","
+	}
+
+	// the expected status follows the arguments of set
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE messages
+        SET status = $3,`+set+`
+            claimed_by = NULL,
+            lease_until = NULL
+        WHERE id = $1 AND claimed_by = $2 AND status = $`+strconv.Itoa(len(args)+4),
+		append(append([]any{id, owner, string(to)}, args...), string(from))...,
+	)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return nil
+	}
+
+	current, err := r.currentStatus(ctx, id)
+	if err != nil {
+		return err
+	}
+	if current != from {
+		return &model.TransitionError{MessageID: id, From: current, To: to}
+	}
+	return model.ErrLeaseLost
+}
+
+// currentStatus returns the status a message is in, or model.ErrNotFound
+func (r *PostgresMessageRepository) currentStatus(ctx context.Context, id int64) (model.MessageStatus, error) {
+	var status model.MessageStatus
+	err := r.db.QueryRowContext(ctx, `SELECT status FROM messages WHERE id = $1`, id).Scan(&status)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", model.ErrNotFound
+	}
+	return status, err
+}
+
+// ExpirePending moves every pending message past its expiry to expired and returns how many were expired.
+// Messages without expires_at expire defaultTTL after they became due; a non-positive defaultTTL disables that.
+// Messages leased to a relayer are skipped and left to the relayer's own expiry check.
+func (r *PostgresMessageRepository) ExpirePending(ctx context.Context, defaultTTL time.Duration) (int64, error) {
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE messages
+        SET status = 'expired'
+        WHERE id IN (
+            SELECT id FROM messages
+            WHERE status = 'pending' AND `+leaseFree+`
+              AND (expires_at <= now() AT TIME ZONE 'UTC'
+                   OR (expires_at IS NULL AND $1::float8 > 0
+                       AND COALESCE(send_at, created_at) + make_interval(secs => $1::float8) <= now() AT TIME ZONE 'UTC'))
+            FOR UPDATE SKIP LOCKED
+        )`, defaultTTL.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ReplayMessages moves the failed messages selected by the request back to pending with their attempt count reset,
+// applying the optional phone number and content edits. Each starts a new delivery generation, so it is not
+// mistaken for the earlier attempts by the gateway. Every replayed message is recorded in message_replays
+// within the same statement.
+func (r *PostgresMessageRepository) ReplayMessages(ctx context.Context, req model.ReplayRequest) ([]model.Message, error) {
+	var b whereBuilder
+	b.add("status = $%d", string(model.StatusFailed))
+	if len(req.IDs) > 0 {
+		b.add("id = ANY($%d)", pq.Array(req.IDs))
+	}
+	b.addFilter(req.Filter)
+
+	phone := b.placeholder(req.PhoneNumber)
+	content := b.placeholder(req.Content)
+	replayedBy := b.placeholder(req.ReplayedBy)
+
+	// the locked snapshot keeps the previous values for the audit row and skips rows a concurrent replay already moved
+	rows, err := r.db.QueryContext(ctx, `
+        WITH replayed AS (
+            UPDATE messages
+            SET status = 'pending',
+                attempt_count = 0,
+                next_attempt_at = NULL,
+                failure_reason = NULL,
+                last_error = NULL,
+                delivery_generation = delivery_generation + 1,
+                phone_number = COALESCE(NULLIF(`+phone+`, ''), prev.prev_phone_number),
+                content = COALESCE(NULLIF(`+content+`, ''), prev.prev_content)
+            FROM (
+                SELECT id AS prev_id, phone_number AS prev_phone_number, content AS prev_content,
+                       attempt_count AS prev_attempt_count
+                FROM messages
+                WHERE `+b.where()+`
+                FOR UPDATE
+            ) prev
+            WHERE id = prev.prev_id
+            RETURNING `+messageColumns+`, prev_phone_number, prev_content, prev_attempt_count
+        ), audit AS (
+            INSERT INTO message_replays (message_id, replayed_by, previous_phone_number, previous_content,
+                                         previous_attempt_count, phone_number, content)
+            SELECT id, `+replayedBy+`, prev_phone_number, prev_content, prev_attempt_count, phone_number, content
+            FROM replayed
+        )
+        SELECT `+messageColumns+` FROM replayed ORDER BY id
+    `, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []model.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+
+	return msgs, rows.Err()
+}
+
+// pendingUnlocked selects the row only while it is pending and not leased or locked by a relayer
+const pendingUnlocked = `SELECT id FROM messages WHERE id = $1 AND status = 'pending' AND ` + leaseFree + `
+    FOR UPDATE SKIP LOCKED`
+
+// CancelMessage moves a pending message to cancelled.
+// It returns a *model.TransitionError when the message left the pending state and model.ErrNotPending when it is
+// being relayed.
+func (r *PostgresMessageRepository) CancelMessage(ctx context.Context, id int64) (*model.Message, error) {
+	row := r.db.QueryRowContext(ctx, `
+        UPDATE messages
+        SET status = 'cancelled'
+        WHERE id = (`+pendingUnlocked+`)
+        RETURNING `+messageColumns, id)
+	return r.scanGuarded(ctx, row, id, model.StatusCancelled)
+}
+
+// EditMessage replaces the phone number and/or content of a pending message and starts a new delivery generation,
+// since an earlier attempt may have reached the gateway with the previous payload.
+// It returns model.ErrNotPending when the message left the pending state or is being relayed.
+func (r *PostgresMessageRepository) EditMessage(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error) {
+	if err := edit.Validate(); err != nil {
+		return nil, err
+	}
+
+	row := r.db.QueryRowContext(ctx, `
+        UPDATE messages
+        SET phone_number = COALESCE(NULLIF($2, ''), phone_number),
+            content = COALESCE(NULLIF($3, ''), content),
+            delivery_generation = delivery_generation + 1
+        WHERE id = (`+pendingUnlocked+`)
+        RETURNING `+messageColumns, id, edit.PhoneNumber, edit.Content)
+	return r.scanGuarded(ctx, row, id, model.StatusPending)
+}
+
+// scanGuarded reads the row a pending-only statement moved to status to, telling a missing message and one in
+// another status apart from one that is being relayed
+func (r *PostgresMessageRepository) scanGuarded(ctx context.Context, row *sql.Row, id int64, to model.MessageStatus) (*model.Message, error) {
+	m, err := scanMessage(row)
+	if err == nil {
+		return &m, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	current, err := r.currentStatus(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current != model.StatusPending && to != model.StatusPending {
+		return nil, &model.TransitionError{MessageID: id, From: current, To: to}
+	}
+	return nil, model.ErrNotPending
+}
+
+// CancelMessages cancels every pending message matching the filter, skipping rows that are being relayed,
+// and returns the number of cancelled messages
+func (r *PostgresMessageRepository) CancelMessages(ctx context.Context, filter model.MessageFilter) (int64, error) {
+	var b whereBuilder
+	b.add("status = $%d", string(model.StatusPending))
+	b.conds = append(b.conds, leaseFree)
+	b.addFilter(filter)
+
+	res, err := r.db.ExecContext(ctx, `
+        UPDATE messages
+        SET status = 'cancelled'
+        WHERE id IN (
+            SELECT id FROM messages
+            WHERE `+b.where()+`
+            FOR UPDATE SKIP LOCKED
+        )`, b.args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
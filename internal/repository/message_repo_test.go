@@ -2,14 +2,16 @@ package repository_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lazerion/outbox-relayer/internal/model"
 	"github.com/lazerion/outbox-relayer/internal/repository"
 )
 
-func TestPostgresMessageRepository_FetchPendingTx(t *testing.T) {
+func TestPostgresMessageRepository_ClaimPending(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
 		t.Fatalf("failed to open sqlmock: %s", err)
@@ -20,100 +22,1006 @@ func TestPostgresMessageRepository_FetchPendingTx(t *testing.T) {
 
 	mock.ExpectBegin()
 
-	msgsRows := sqlmock.NewRows([]string{"id", "phone_number", "content", "status"}).
-		AddRow(int64(1), "+123456789", "Hello", "pending").
-		AddRow(int64(2), "+987654321", "World", "pending")
+	msgsRows := sqlmock.NewRows(messageColumns).
+		AddRow(int64(1), "+123456789", "Hello", "pending", nil, nil, 0, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 0).
+		AddRow(int64(2), "+987654321", "World", "pending", nil, nil, 1, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 0)
 
-	mock.ExpectQuery(`SELECT id, phone_number, content, status`).
-		WithArgs(2).
+	mock.ExpectQuery(`UPDATE messages\s+SET claimed_by = \$1,\s+lease_until = now\(\) AT TIME ZONE 'UTC' \+ make_interval\(secs => \$2::float8\)(?s).*`+
+		`WHERE status = 'pending' AND \(send_at IS NULL OR send_at <= now\(\) AT TIME ZONE 'UTC'\)\s+`+
+		`AND \(next_attempt_at IS NULL OR next_attempt_at <= now\(\) AT TIME ZONE 'UTC'\)\s+`+
+		`AND \(lease_until IS NULL OR lease_until <= now\(\) AT TIME ZONE 'UTC'\)\s+`+
+		`ORDER BY priority DESC, id\s+LIMIT \$3\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs("relayer-1", float64(60), 2).
 		WillReturnRows(msgsRows)
+	mock.ExpectCommit()
 
 	ctx := context.Background()
-	msgs, tx, err := repo.FetchPendingTx(ctx, 2)
+	msgs, err := repo.ClaimPending(ctx, "relayer-1", time.Minute, 2, nil)
 	if err != nil {
 		t.Fatalf("unexpected error: %s", err)
 	}
 
-	if tx == nil {
-		t.Fatal("expected transaction, got nil")
-	}
 	if len(msgs) != 2 {
 		t.Fatalf("expected 2 messages, got %d", len(msgs))
 	}
 	if msgs[0].ID != 1 || msgs[1].ID != 2 {
 		t.Errorf("unexpected message IDs: %v, %v", msgs[0].ID, msgs[1].ID)
 	}
+	if msgs[1].AttemptCount != 1 {
+		t.Errorf("expected attempt count 1 to be loaded, got %d", msgs[1].AttemptCount)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_ClaimPending_Quotas(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
 
-	tx.Rollback()
+	repo := repository.NewPostgresMessageRepository(db)
+
+	mock.ExpectBegin()
+	// high claims its full quota
+	mock.ExpectQuery(`AND priority = \$4\s+ORDER BY id\s+LIMIT \$3`).
+		WithArgs("relayer-1", float64(60), 2, int64(model.PriorityHigh)).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(5), "+1", "otp", "pending", nil, nil, 0, time.Now(), "", nil, nil, nil, 3, nil, nil, nil, nil, nil, nil, nil, nil, 0).
+			AddRow(int64(6), "+2", "otp", "pending", nil, nil, 0, time.Now(), "", nil, nil, nil, 3, nil, nil, nil, nil, nil, nil, nil, nil, 0))
+	// low has nothing pending, so its slot is left over
+	mock.ExpectQuery(`AND priority = \$4\s+ORDER BY id\s+LIMIT \$3`).
+		WithArgs("relayer-1", float64(60), 1, int64(model.PriorityLow)).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`ORDER BY priority DESC, id\s+LIMIT \$3`).
+		WithArgs("relayer-1", float64(60), 1).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(7), "+3", "otp", "pending", nil, nil, 0, time.Now(), "", nil, nil, nil, 3, nil, nil, nil, nil, nil, nil, nil, nil, 0))
+	mock.ExpectCommit()
+
+	msgs, err := repo.ClaimPending(context.Background(), "relayer-1", time.Minute, 3, map[model.MessagePriority]int{
+		model.PriorityHigh: 2,
+		model.PriorityLow:  1,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 3 || msgs[2].ID != 7 || msgs[0].Priority != model.PriorityHigh {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
 	}
 }
 
-func TestPostgresMessageRepository_MarkAsSentTx(t *testing.T) {
+func TestPostgresMessageRepository_MarkAsSent(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
 	repo := repository.NewPostgresMessageRepository(db)
+	// sent_time is stored without a zone, so a local time must be converted before it is written
+	sentAt := time.Date(2030, 1, 2, 6, 4, 5, 0, time.FixedZone("UTC+3", 3*60*60))
 
-	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE messages`).
-		WithArgs(int64(1), "ext123", sqlmock.AnyArg()).
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3,\s+external_id = \$4,(?s).*claimed_by = NULL,\s+lease_until = NULL\s+`+
+		`WHERE id = \$1 AND claimed_by = \$2 AND status = \$6`).
+		WithArgs(int64(1), "relayer-1", "sent", "ext123", sentAt.UTC(), "pending").
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectCommit()
 
-	tx, _ := db.BeginTx(context.Background(), nil)
-	err := repo.MarkAsSentTx(context.Background(), tx, 1, "ext123", time.Now())
+	err := repo.MarkAsSent(context.Background(), 1, "relayer-1", "ext123", sentAt)
 	if err != nil {
 		t.Fatalf("unexpected error: %s", err)
 	}
-	tx.Commit()
 }
 
-func TestPostgresMessageRepository_MarkAsFailedTx(t *testing.T) {
+func TestPostgresMessageRepository_MarkAsFailed(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
 	repo := repository.NewPostgresMessageRepository(db)
 
-	mock.ExpectBegin()
-	mock.ExpectExec(`UPDATE messages SET status='failed'`).
-		WithArgs(int64(1)).
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3,\s+failure_reason = \$4,\s+last_error = COALESCE\(\$5, last_error\)`).
+		WithArgs(int64(1), "relayer-1", "failed", "unrecoverable_error", "upstream error (status 400): bad request", "pending").
 		WillReturnResult(sqlmock.NewResult(1, 1))
-	mock.ExpectCommit()
+	// the lease was taken over by another relayer
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3`).
+		WithArgs(int64(2), "relayer-1", "failed", "max_attempts", nil, "pending").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT status FROM messages WHERE id = \$1`).
+		WithArgs(int64(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("pending"))
+	// the other relayer already sent it
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3`).
+		WithArgs(int64(3), "relayer-1", "failed", "max_attempts", nil, "pending").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT status FROM messages WHERE id = \$1`).
+		WithArgs(int64(3)).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("sent"))
 
-	tx, _ := db.BeginTx(context.Background(), nil)
-	err := repo.MarkAsFailedTx(context.Background(), tx, 1)
+	err := repo.MarkAsFailed(context.Background(), 1, "relayer-1", model.FailureUnrecoverable, "upstream error (status 400): bad request")
 	if err != nil {
 		t.Fatalf("unexpected error: %s", err)
 	}
-	tx.Commit()
+	if err := repo.MarkAsFailed(context.Background(), 2, "relayer-1", model.FailureMaxAttempts, ""); !errors.Is(err, model.ErrLeaseLost) {
+		t.Fatalf("expected ErrLeaseLost, got %v", err)
+	}
+	err = repo.MarkAsFailed(context.Background(), 3, "relayer-1", model.FailureMaxAttempts, "")
+	var tErr *model.TransitionError
+	if !errors.As(err, &tErr) || tErr.From != model.StatusSent || tErr.To != model.StatusFailed {
+		t.Fatalf("expected TransitionError from sent to failed, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
 }
 
-func TestPostgresMessageRepository_IncrementAttemptTx(t *testing.T) {
+func TestPostgresMessageRepository_ExpirePending(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	mock.ExpectExec(`UPDATE messages\s+SET status = 'expired'(?s).*WHERE status = 'pending'.*FOR UPDATE SKIP LOCKED`).
+		WithArgs(float64(600)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	n, err := repo.ExpirePending(context.Background(), 10*time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 expired messages, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_IncrementAttempt(t *testing.T) {
 	db, mock, _ := sqlmock.New()
 	defer db.Close()
 
 	repo := repository.NewPostgresMessageRepository(db)
 	ctx := context.Background()
 	messageID := int64(42)
+	nextAttemptAt := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3,\s+attempt_count = attempt_count \+ 1,\s+next_attempt_at = \$4,\s+last_error = \$5,\s+claimed_by = NULL`).
+		WithArgs(messageID, "relayer-1", "pending", nextAttemptAt, "upstream error: timeout", "pending").
+		WillReturnResult(sqlmock.NewResult(0, 1)) // Expect 1 row affected
+
+	err := repo.IncrementAttempt(ctx, messageID, "relayer-1", nextAttemptAt, "upstream error: timeout")
+	if err != nil {
+		t.Errorf("expected no error from IncrementAttempt, got: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_MarkAsUnknown(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+	checkAt := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3,\s+attempt_count = attempt_count \+ 1,\s+status_checks = 0,\s+next_attempt_at = \$4,\s+last_error = \$5,\s+`+
+		`claimed_by = NULL,\s+lease_until = NULL\s+WHERE id = \$1 AND claimed_by = \$2 AND status = \$6`).
+		WithArgs(int64(7), "relayer-1", "unknown", checkAt, "upstream error: timeout", "pending").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if err := repo.MarkAsUnknown(context.Background(), 7, "relayer-1", checkAt, "upstream error: timeout"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_RecordFailedCheck(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		wantFailed bool
+	}{
+		{"Checked again", "unknown", false},
+		{"Gives up", "failed", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New()
+			defer db.Close()
+
+			repo := repository.NewPostgresMessageRepository(db)
+
+			mock.ExpectQuery(`UPDATE messages\s+SET status_checks = status_checks \+ 1,\s+last_error = \$3,\s+`+
+				`status = CASE WHEN status_checks \+ 1 >= \$4 THEN \$5 ELSE status END,\s+`+
+				`failure_reason = CASE WHEN status_checks \+ 1 >= \$4 THEN \$6 ELSE failure_reason END,(?s).*`+
+				`WHERE id = \$1 AND claimed_by = \$2 AND status = \$7\s+RETURNING status`).
+				WithArgs(int64(7), "reconciler-1", "status endpoint unavailable", 10, "failed", "unknown_outcome", "unknown").
+				WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(tt.status))
+
+			failed, err := repo.RecordFailedCheck(context.Background(), 7, "reconciler-1", "status endpoint unavailable", 10)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if failed != tt.wantFailed {
+				t.Errorf("expected failed %v, got %v", tt.wantFailed, failed)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPostgresMessageRepository_RecordFailedCheck_Resolved(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	mock.ExpectQuery(`UPDATE messages\s+SET status_checks = status_checks \+ 1`).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+	mock.ExpectQuery(`SELECT status FROM messages WHERE id = \$1`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("sent"))
+
+	_, err := repo.RecordFailedCheck(context.Background(), 7, "reconciler-1", "status endpoint unavailable", 10)
+	var terr *model.TransitionError
+	if !errors.As(err, &terr) || terr.From != model.StatusSent {
+		t.Fatalf("expected a transition error from sent, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_ClaimUnknown(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	rows := sqlmock.NewRows(messageColumns).
+		AddRow(int64(7), "+1", "x", "unknown", nil, nil, 1, time.Now(), "", nil, nil, nil, 2, nil, nil, "reconciler-1", time.Now(), "upstream error: timeout", nil, nil, nil, 0)
+	mock.ExpectQuery(`UPDATE messages\s+SET claimed_by = \$1,(?s).*`+
+		`WHERE status = 'unknown'\s+AND \(next_attempt_at IS NULL OR next_attempt_at <= now\(\) AT TIME ZONE 'UTC'\)\s+`+
+		`AND \(lease_until IS NULL OR lease_until <= now\(\) AT TIME ZONE 'UTC'\)\s+`+
+		`ORDER BY id\s+LIMIT \$3\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs("reconciler-1", float64(60), 10).
+		WillReturnRows(rows)
+
+	msgs, err := repo.ClaimUnknown(context.Background(), "reconciler-1", time.Minute, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 1 || msgs[0].Status != model.StatusUnknown || msgs[0].ClaimedBy != "reconciler-1" {
+		t.Fatalf("unexpected messages: %+v", msgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_ResolveUnknown(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+	ctx := context.Background()
+	sentAt := time.Date(2030, 1, 2, 6, 4, 5, 0, time.FixedZone("UTC+3", 3*60*60))
+
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3,\s+external_id = \$4,\s+sent_time = \$5,\s+next_attempt_at = NULL,(?s).*`+
+		`WHERE id = \$1 AND claimed_by = \$2 AND status = \$6`).
+		WithArgs(int64(7), "reconciler-1", "sent", "ext-7", sentAt.UTC(), "unknown").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3,\s+next_attempt_at = NULL,(?s).*`+
+		`WHERE id = \$1 AND claimed_by = \$2 AND status = \$4`).
+		WithArgs(int64(8), "reconciler-1", "pending", "unknown").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// already resolved by another reconciler after the lease ran out
+	mock.ExpectExec(`UPDATE messages\s+SET status = \$3`).
+		WithArgs(int64(9), "reconciler-1", "pending", "unknown").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT status FROM messages WHERE id = \$1`).
+		WithArgs(int64(9)).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("sent"))
+
+	if err := repo.ConfirmSent(ctx, 7, "reconciler-1", "ext-7", sentAt); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := repo.RequeueUnknown(ctx, 8, "reconciler-1"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err := repo.RequeueUnknown(ctx, 9, "reconciler-1")
+	var tErr *model.TransitionError
+	if !errors.As(err, &tErr) || tErr.From != model.StatusSent || tErr.To != model.StatusPending {
+		t.Fatalf("expected TransitionError from sent to pending, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_RecordDelivery(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+	ctx := context.Background()
+	deliveredAt := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+	receipt := model.DeliveryReceipt{ExternalID: "ext-1", Status: model.StatusUndelivered, DeliveredAt: deliveredAt, Error: "absent subscriber"}
+
+	mock.ExpectQuery(`UPDATE messages\s+SET status = \$2,\s+delivered_time = \$3,\s+last_error = COALESCE\(\$4, last_error\)\s+`+
+		`WHERE external_id = \$1 AND status = 'sent'\s+RETURNING`).
+		WithArgs("ext-1", "undelivered", deliveredAt, "absent subscriber").
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(1), "+1", "x", "undelivered", time.Now(), "ext-1", 1, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, "absent subscriber", nil, deliveredAt, nil, 0))
+
+	msg, err := repo.RecordDelivery(ctx, receipt)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.Status != model.StatusUndelivered || !msg.DeliveredTime.Equal(deliveredAt) || msg.LastError != "absent subscriber" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	// a retried receipt finds the message already undelivered
+	mock.ExpectQuery(`UPDATE messages`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE external_id = \$1`).
+		WithArgs("ext-1").
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(1), "+1", "x", "undelivered", time.Now(), "ext-1", 1, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, "absent subscriber", nil, deliveredAt, nil, 0))
+	if _, err := repo.RecordDelivery(ctx, receipt); err != nil {
+		t.Fatalf("expected a repeated receipt to succeed, got %v", err)
+	}
+
+	// a conflicting receipt for a message already delivered
+	receipt.Status = model.StatusDelivered
+	mock.ExpectQuery(`UPDATE messages`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE external_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(1), "+1", "x", "undelivered", time.Now(), "ext-1", 1, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, "absent subscriber", nil, deliveredAt, nil, 0))
+	_, err = repo.RecordDelivery(ctx, receipt)
+	var tErr *model.TransitionError
+	if !errors.As(err, &tErr) || tErr.From != model.StatusUndelivered || tErr.To != model.StatusDelivered {
+		t.Fatalf("expected TransitionError from undelivered to delivered, got %v", err)
+	}
+
+	// an external ID the relayer never recorded
+	receipt.ExternalID = "ext-unknown"
+	mock.ExpectQuery(`UPDATE messages`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE external_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	if _, err := repo.RecordDelivery(ctx, receipt); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_RecordDelivery_Unknown(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+	ctx := context.Background()
+	deliveredAt := time.Date(2025, 1, 1, 12, 0, 30, 0, time.UTC)
+	attemptedAt := deliveredAt.Add(-time.Minute)
+	receipt := model.DeliveryReceipt{
+		ExternalID:         "ext-4",
+		Status:             model.StatusDelivered,
+		DeliveredAt:        deliveredAt,
+		MessageID:          4,
+		DeliveryGeneration: 1,
+	}
+
+	// the send timed out, so no message carries the external ID yet
+	mock.ExpectQuery(`UPDATE messages\s+SET status = \$2,(?s).*WHERE external_id = \$1 AND status = 'sent'`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`UPDATE messages\s+SET status = \$3,\s+delivered_time = \$4,\s+last_error = COALESCE\(\$5, last_error\),\s+`+
+		`external_id = \$6,\s+sent_time = COALESCE\(\(SELECT max\(attempted_at\) FROM message_attempts WHERE message_id = \$1\), \$4\),\s+`+
+		`next_attempt_at = NULL,\s+claimed_by = NULL,\s+lease_until = NULL\s+`+
+		`WHERE id = \$1 AND delivery_generation = \$2 AND status = 'unknown'\s+RETURNING`).
+		WithArgs(int64(4), 1, "delivered", deliveredAt, nil, "ext-4").
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(4), "+1", "x", "delivered", attemptedAt, "ext-4", 1, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, deliveredAt, nil, 1))
+
+	msg, err := repo.RecordDelivery(ctx, receipt)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.Status != model.StatusDelivered || msg.ExternalID != "ext-4" || !msg.SentTime.Equal(attemptedAt) {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	// the reconciler already queued the message to be sent again
+	mock.ExpectQuery(`WHERE external_id = \$1 AND status = 'sent'`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`WHERE id = \$1 AND delivery_generation = \$2 AND status = 'unknown'`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE external_id = \$1`).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE id = \$1 AND delivery_generation = \$2`).
+		WithArgs(int64(4), 1).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(4), "+1", "x", "pending", nil, nil, 1, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 1))
+	_, err = repo.RecordDelivery(ctx, receipt)
+	var tErr *model.TransitionError
+	if !errors.As(err, &tErr) || tErr.From != model.StatusPending || tErr.To != model.StatusDelivered {
+		t.Fatalf("expected TransitionError from pending to delivered, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_RecordAttempt(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+	attemptedAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectExec(`INSERT INTO message_attempts \(message_id, attempted_at, status_code, error, recoverable, external_id, duration_ms\)`).
+		WithArgs(int64(42), attemptedAt, int64(503), "upstream error (status 503): unavailable", true, nil, int64(180)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	err := repo.RecordAttempt(context.Background(), model.MessageAttempt{
+		MessageID:   42,
+		AttemptedAt: attemptedAt,
+		StatusCode:  503,
+		Error:       "upstream error (status 503): unavailable",
+		Recoverable: true,
+		DurationMS:  180,
+	})
+	if err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_CreateMessage(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	mock.ExpectQuery(`INSERT INTO messages`).
+		WithArgs("+905551112233", "hello", "", nil, nil, int64(model.PriorityNormal), nil, nil).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempt_count", "created_at"}).
+			AddRow(int64(7), "pending", 0, time.Now()))
+
+	msg, err := repo.CreateMessage(context.Background(), model.NewMessage{
+		PhoneNumber: "+905551112233",
+		Content:     "hello",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.ID != 7 {
+		t.Errorf("expected ID 7, got %d", msg.ID)
+	}
+	if msg.PhoneNumber != "+905551112233" || msg.Content != "hello" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_CreateMessage_SendAt(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	sendAt := time.Date(2025, 12, 1, 9, 0, 0, 0, time.FixedZone("TRT", 3*60*60))
+	mock.ExpectQuery(`INSERT INTO messages \(phone_number, content, client_id, send_at, expires_at, priority, max_attempts,\s+callback_url\)`).
+		WithArgs("+905551112233", "reminder", "", sendAt.UTC(), nil, int64(model.PriorityNormal), int64(8),
+			"https://producer.example.com/sms-status").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempt_count", "created_at"}).
+			AddRow(int64(8), "pending", 0, time.Now()))
+
+	msg, err := repo.CreateMessage(context.Background(), model.NewMessage{
+		PhoneNumber: "+905551112233",
+		Content:     "reminder",
+		SendAt:      sendAt,
+		MaxAttempts: 8,
+		CallbackURL: "https://producer.example.com/sms-status",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !msg.SendAt.Equal(sendAt) || msg.MaxAttempts != 8 || msg.CallbackURL != "https://producer.example.com/sms-status" {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_CreateMessage_Invalid(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	_, err := repo.CreateMessage(context.Background(), model.NewMessage{
+		PhoneNumber: "not-a-number",
+		Content:     "hello",
+	})
+
+	var vErr *model.ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+
+	// validation must happen before touching the database
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_CopyMessages(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+	sendAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
 
 	mock.ExpectBegin()
-	tx, err := db.BeginTx(ctx, nil)
+	prep := mock.ExpectPrepare(`COPY "messages" \("phone_number", "content", "client_id", "send_at", "expires_at", ` +
+		`"priority", "max_attempts", "callback_url"\) FROM STDIN`)
+	prep.ExpectExec().WithArgs("+111", "first", "billing", nil, nil, model.PriorityNormal, nil, nil).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithArgs("+222", "second", "billing", sendAt, nil, model.PriorityHigh, 3, "https://example.com/hook").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	prep.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	msgs := func(yield func(model.NewMessage, error) bool) {
+		if !yield(model.NewMessage{PhoneNumber: "+111", Content: "first", ClientID: "billing"}, nil) {
+			return
+		}
+		yield(model.NewMessage{
+			PhoneNumber: "+222",
+			Content:     "second",
+			ClientID:    "billing",
+			SendAt:      sendAt,
+			Priority:    model.PriorityHigh,
+			MaxAttempts: 3,
+			CallbackURL: "https://example.com/hook",
+		}, nil)
+	}
+
+	n, err := repo.CopyMessages(context.Background(), msgs)
 	if err != nil {
-		t.Fatalf("db.BeginTx failed: %v", err)
+		t.Fatalf("unexpected error: %s", err)
 	}
-	mock.ExpectExec(`UPDATE messages`).
-		WithArgs(messageID).
-		WillReturnResult(sqlmock.NewResult(0, 1)) // Expect 1 row affected
+	if n != 2 {
+		t.Errorf("expected 2 copied rows, got %d", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_CopyMessages_SourceError(t *testing.T) {
+	db, mock, _ := sqlmock.New()
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	mock.ExpectBegin()
+	prep := mock.ExpectPrepare(`COPY "messages"`)
+	prep.ExpectExec().WithArgs("+111", "first", "", nil, nil, model.PriorityNormal, nil, nil).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	readErr := errors.New("connection reset")
+	msgs := func(yield func(model.NewMessage, error) bool) {
+		if !yield(model.NewMessage{PhoneNumber: "+111", Content: "first"}, nil) {
+			return
+		}
+		yield(model.NewMessage{}, readErr)
+	}
+
+	_, err := repo.CopyMessages(context.Background(), msgs)
+	if !errors.Is(err, readErr) {
+		t.Fatalf("expected source error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+var idempotencyColumns = []string{
+	"id", "phone_number", "content", "status", "attempt_count", "created_at", "client_id", "idempotency_key", "send_at", "expires_at",
+	"priority", "max_attempts", "callback_url", "live",
+}
+
+func TestPostgresMessageRepository_CreateMessageIdempotent(t *testing.T) {
+	newMsg := model.NewMessage{PhoneNumber: "+111", Content: "hello", ClientID: "billing", IdempotencyKey: "key-1"}
+	sendAt := time.Date(2030, 1, 2, 3, 4, 5, 123456000, time.UTC)
+	scheduled := func(at time.Time) model.NewMessage {
+		msg := newMsg
+		msg.SendAt = at
+		msg.Priority = model.PriorityHigh
+		msg.MaxAttempts = 5
+		msg.CallbackURL = "https://example.com/hook"
+		return msg
+	}
+	insertedRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "status", "attempt_count", "created_at"}).
+			AddRow(int64(9), "pending", 0, time.Now())
+	}
+
+	tests := []struct {
+		name         string
+		setup        func(mock sqlmock.Sqlmock)
+		msg          model.NewMessage
+		wantID       int64
+		wantReplayed bool
+		wantErr      error
+	}{
+		{
+			name: "first use inserts",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				// created_at is stored in UTC, so the key age is measured against UTC rather than the session zone
+				mock.ExpectQuery(`SELECT .*created_at > \(now\(\) AT TIME ZONE 'UTC'\) - make_interval\(secs => \$3::float8\).*`+
+					`FROM messages\s+WHERE client_id = \$1 AND idempotency_key = \$2 FOR UPDATE`).
+					WithArgs("billing", "key-1", float64(3600)).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns))
+				mock.ExpectQuery(`INSERT INTO messages`).
+					WithArgs("+111", "hello", "billing", "key-1", nil, nil, int64(model.PriorityNormal), nil, nil).
+					WillReturnRows(insertedRows())
+				mock.ExpectCommit()
+			},
+			msg:    newMsg,
+			wantID: 9,
+		},
+		{
+			name: "live key replays",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(3), "+111", "hello", "pending", 0, time.Now(), "billing", "key-1", nil, nil, 2, nil, nil, true))
+				mock.ExpectRollback()
+			},
+			msg:          newMsg,
+			wantID:       3,
+			wantReplayed: true,
+		},
+		{
+			name: "live key with different payload",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(3), "+111", "other content", "pending", 0, time.Now(), "billing", "key-1", nil, nil, 2, nil, nil, true))
+				mock.ExpectRollback()
+			},
+			msg:     newMsg,
+			wantErr: model.ErrIdempotencyKeyReused,
+		},
+		{
+			name: "live key with the same schedule replays",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(3), "+111", "hello", "pending", 0, time.Now(), "billing", "key-1", sendAt, nil, 3, 5,
+							"https://example.com/hook", true))
+				mock.ExpectRollback()
+			},
+			// the stored send_at keeps microseconds and no zone
+			msg:          scheduled(sendAt.Add(400 * time.Nanosecond).In(time.FixedZone("UTC+3", 3*60*60))),
+			wantID:       3,
+			wantReplayed: true,
+		},
+		{
+			name: "live key with different schedule",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(3), "+111", "hello", "pending", 0, time.Now(), "billing", "key-1", sendAt, nil, 3, 5,
+							"https://example.com/hook", true))
+				mock.ExpectRollback()
+			},
+			msg:     scheduled(sendAt.Add(time.Hour)),
+			wantErr: model.ErrIdempotencyKeyReused,
+		},
+		{
+			name: "live key with different priority",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(3), "+111", "hello", "pending", 0, time.Now(), "billing", "key-1", nil, nil, 1, nil, nil, true))
+				mock.ExpectRollback()
+			},
+			msg:     newMsg,
+			wantErr: model.ErrIdempotencyKeyReused,
+		},
+		{
+			name: "live key with different max attempts",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(3), "+111", "hello", "pending", 0, time.Now(), "billing", "key-1", nil, nil, 2, 7, nil, true))
+				mock.ExpectRollback()
+			},
+			msg:     newMsg,
+			wantErr: model.ErrIdempotencyKeyReused,
+		},
+		{
+			name: "expired key is released",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(3), "+111", "hello", "sent", 0, time.Now(), "billing", "key-1", nil, nil, 2, nil, nil, false))
+				mock.ExpectExec(`UPDATE messages SET idempotency_key = NULL WHERE id = \$1`).
+					WithArgs(int64(3)).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectQuery(`INSERT INTO messages`).
+					WillReturnRows(insertedRows())
+				mock.ExpectCommit()
+			},
+			msg:    newMsg,
+			wantID: 9,
+		},
+		{
+			name: "concurrent insert wins",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectQuery(`SELECT .* FOR UPDATE`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns))
+				mock.ExpectQuery(`INSERT INTO messages`).
+					WillReturnRows(sqlmock.NewRows([]string{"id", "status", "attempt_count", "created_at"}))
+				mock.ExpectQuery(`SELECT .* FROM messages`).
+					WillReturnRows(sqlmock.NewRows(idempotencyColumns).
+						AddRow(int64(4), "+111", "hello", "pending", 0, time.Now(), "billing", "key-1", nil, nil, 2, nil, nil, true))
+				mock.ExpectRollback()
+			},
+			msg:          newMsg,
+			wantID:       4,
+			wantReplayed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, _ := sqlmock.New()
+			defer db.Close()
 
-	err = repo.IncrementAttemptTx(ctx, tx, messageID)
+			repo := repository.NewPostgresMessageRepository(db)
+			tt.setup(mock)
+
+			msg, replayed, err := repo.CreateMessageIdempotent(context.Background(), tt.msg, time.Hour)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected error %v, got %v", tt.wantErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if msg.ID != tt.wantID {
+					t.Errorf("expected ID %d, got %d", tt.wantID, msg.ID)
+				}
+				if replayed != tt.wantReplayed {
+					t.Errorf("expected replayed=%v, got %v", tt.wantReplayed, replayed)
+				}
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPostgresMessageRepository_ReplayMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
 	if err != nil {
-		t.Errorf("expected no error from IncrementAttemptTx, got: %v", err)
+		t.Fatalf("failed to open sqlmock: %s", err)
 	}
+	defer db.Close()
 
-	tx.Commit()
+	repo := repository.NewPostgresMessageRepository(db)
+
+	createdFrom := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	req := model.ReplayRequest{
+		IDs:        []int64{7, 8},
+		Filter:     model.MessageFilter{CreatedFrom: createdFrom},
+		Content:    "fixed",
+		ReplayedBy: "ops",
+	}
+
+	mock.ExpectQuery(`UPDATE messages\s+SET status = 'pending',\s+attempt_count = 0,(?s).*`+
+		`failure_reason = NULL,\s+last_error = NULL,\s+delivery_generation = delivery_generation \+ 1,(?s).*`+
+		`WHERE status = \$1 AND id = ANY\(\$2\) AND created_at >= \$3\s+FOR UPDATE(?s).*`+
+		`INSERT INTO message_replays`).
+		WithArgs("failed", sqlmock.AnyArg(), createdFrom, "", "fixed", "ops").
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(7), "+111", "fixed", "pending", nil, nil, 0, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 1))
+
+	msgs, err := repo.ReplayMessages(context.Background(), req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != 7 || msgs[0].Status != model.StatusPending || msgs[0].AttemptCount != 0 || msgs[0].LastError != "" ||
+		msgs[0].DeliveryGeneration != 1 {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_CancelMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(mock sqlmock.Sqlmock)
+		wantErr error
+	}{
+		{
+			name: "pending message is cancelled",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`UPDATE messages\s+SET status = 'cancelled'\s+WHERE id = \(SELECT id FROM messages WHERE id = \$1 AND status = 'pending' AND \(lease_until IS NULL OR lease_until <= now\(\) AT TIME ZONE 'UTC'\)\s+FOR UPDATE SKIP LOCKED\)`).
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows(messageColumns).
+						AddRow(int64(3), "+111", "hello", "cancelled", nil, nil, 0, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 0))
+			},
+		},
+		{
+			name: "locked message conflicts",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`UPDATE messages`).
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows(messageColumns))
+				mock.ExpectQuery(`SELECT status FROM messages WHERE id = \$1`).
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("pending"))
+			},
+			wantErr: model.ErrNotPending,
+		},
+		{
+			name: "sent message cannot be cancelled",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`UPDATE messages`).
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows(messageColumns))
+				mock.ExpectQuery(`SELECT status FROM messages WHERE id = \$1`).
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow("sent"))
+			},
+			wantErr: model.ErrInvalidTransition,
+		},
+		{
+			name: "unknown message",
+			setup: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(`UPDATE messages`).
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows(messageColumns))
+				mock.ExpectQuery(`SELECT status FROM messages WHERE id = \$1`).
+					WithArgs(int64(3)).
+					WillReturnRows(sqlmock.NewRows([]string{"status"}))
+			},
+			wantErr: model.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			if err != nil {
+				t.Fatalf("failed to open sqlmock: %s", err)
+			}
+			defer db.Close()
+
+			repo := repository.NewPostgresMessageRepository(db)
+			tt.setup(mock)
+
+			msg, err := repo.CancelMessage(context.Background(), 3)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil || msg.Status != model.StatusCancelled {
+				t.Fatalf("unexpected result: %+v, %v", msg, err)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("there were unfulfilled expectations: %s", err)
+			}
+		})
+	}
+}
+
+func TestPostgresMessageRepository_EditMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	mock.ExpectQuery(`UPDATE messages\s+SET phone_number = COALESCE\(NULLIF\(\$2, ''\), phone_number\),\s+content = COALESCE\(NULLIF\(\$3, ''\), content\),\s+delivery_generation = delivery_generation \+ 1\s+WHERE id = \(SELECT id FROM messages WHERE id = \$1 AND status = 'pending' AND \(lease_until IS NULL OR lease_until <= now\(\) AT TIME ZONE 'UTC'\)\s+FOR UPDATE SKIP LOCKED\)`).
+		WithArgs(int64(3), "", "updated").
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(3), "+111", "updated", "pending", nil, nil, 0, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 1))
+
+	msg, err := repo.EditMessage(context.Background(), 3, model.MessageEdit{Content: "updated"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.Content != "updated" || msg.PhoneNumber != "+111" || msg.DeliveryGeneration != 1 {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresMessageRepository_CancelMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresMessageRepository(db)
+
+	mock.ExpectExec(`UPDATE messages\s+SET status = 'cancelled'\s+WHERE id IN \(\s+SELECT id FROM messages\s+WHERE status = \$1 AND \(lease_until IS NULL OR lease_until <= now\(\) AT TIME ZONE 'UTC'\) AND phone_number = \$2\s+FOR UPDATE SKIP LOCKED`).
+		WithArgs("pending", "+111").
+		WillReturnResult(sqlmock.NewResult(0, 4))
+
+	n, err := repo.CancelMessages(context.Background(), model.MessageFilter{PhoneNumber: "+111"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n != 4 {
+		t.Errorf("expected 4 cancelled messages, got %d", n)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
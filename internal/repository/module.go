@@ -2,7 +2,6 @@ package repository
 
 import (
 	"database/sql"
-	"fmt"
 	"log"
 
 	"github.com/lazerion/outbox-relayer/internal/config"
@@ -12,16 +11,7 @@ import (
 
 // NewDB creates a PostgreSQL connection
 func NewDB(cfg *config.Config) (*sql.DB, error) {
-	dsn := fmt.Sprintf(
-		"host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Postgres.Host,
-		cfg.Postgres.Port,
-		cfg.Postgres.User,
-		cfg.Postgres.Password,
-		cfg.Postgres.Database,
-	)
-
-	db, err := sql.Open("postgres", dsn)
+	db, err := sql.Open("postgres", cfg.Postgres.DSN())
 	if err != nil {
 		return nil, err
 	}
@@ -42,11 +32,16 @@ func NewQueryRepositoryProvider(db *sql.DB) QueryRepository {
 	return NewPostgresQueryRepository(db)
 }
 
+func NewCallbackRepositoryProvider(db *sql.DB) CallbackRepository {
+	return NewPostgresCallbackRepository(db)
+}
+
 var Module = fx.Module(
 	"repository",
 	fx.Provide(
 		NewDB,
 		NewMessageRepositoryProvider,
 		NewQueryRepositoryProvider,
+		NewCallbackRepositoryProvider,
 	),
 )
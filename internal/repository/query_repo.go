@@ -3,13 +3,27 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"slices"
 	"time"
 
 	"github.com/lazerion/outbox-relayer/internal/model"
 )
 
 type QueryRepository interface {
-	ListSentMessages(ctx context.Context, after time.Time, limit int) ([]model.Message, error)
+	ListSentMessages(ctx context.Context, key SentKey, backward bool, limit int) ([]model.Message, error)
+	GetMessage(ctx context.Context, id int64) (*model.Message, error)
+	GetMessageByExternalID(ctx context.Context, externalID string) (*model.Message, error)
+	SearchMessages(ctx context.Context, filter model.MessageFilter, afterID int64, limit int) ([]model.Message, error)
+	ListReplays(ctx context.Context, messageID int64) ([]model.MessageReplay, error)
+	ListAttempts(ctx context.Context, messageID int64) ([]model.MessageAttempt, error)
+	CountByStatus(ctx context.Context, filter model.MessageFilter) (map[model.MessageStatus]int64, error)
+}
+
+// SentKey is a position in the (sent_time, id) ordering of sent messages
+type SentKey struct {
+	SentTime time.Time
+	ID       int64
 }
 
 type PostgresQueryRepository struct {
@@ -20,14 +34,28 @@ func NewPostgresQueryRepository(db *sql.DB) QueryRepository {
 	return &PostgresQueryRepository{db: db}
 }
 
-func (r *PostgresQueryRepository) ListSentMessages(ctx context.Context, after time.Time, limit int) ([]model.Message, error) {
-	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, phone_number, content, status, sent_time, external_id
+// ListSentMessages returns sent messages after (or, when backward, before) the key in (sent_time, id) order.
+// Messages a delivery receipt moved on to delivered or undelivered are still listed, with their delivered_time.
+// Both directions return messages in ascending order.
+func (r *PostgresQueryRepository) ListSentMessages(ctx context.Context, key SentKey, backward bool, limit int) ([]model.Message, error) {
+	query := `
+		SELECT id, phone_number, content, status, sent_time, external_id, delivered_time
 		FROM messages
-		WHERE status = 'sent' AND sent_time > $1
-		ORDER BY sent_time ASC
-		LIMIT $2
-	`, after, limit)
+		WHERE status IN ('sent', 'delivered', 'undelivered') AND (sent_time, id) > ($1, $2)
+		ORDER BY sent_time ASC, id ASC
+		LIMIT $3
+	`
+	if backward {
+		query = `
+		SELECT id, phone_number, content, status, sent_time, external_id, delivered_time
+		FROM messages
+		WHERE status IN ('sent', 'delivered', 'undelivered') AND (sent_time, id) < ($1, $2)
+		ORDER BY sent_time DESC, id DESC
+		LIMIT $3
+	`
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, key.SentTime, key.ID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -35,12 +63,174 @@ func (r *PostgresQueryRepository) ListSentMessages(ctx context.Context, after ti
 
 	var msgs []model.Message
 	for rows.Next() {
-		var m model.Message
-		if err := rows.Scan(&m.ID, &m.PhoneNumber, &m.Content, &m.Status, &m.SentTime, &m.ExternalID); err != nil {
+		var (
+			m             model.Message
+			deliveredTime sql.NullTime
+		)
+		if err := rows.Scan(&m.ID, &m.PhoneNumber, &m.Content, &m.Status, &m.SentTime, &m.ExternalID, &deliveredTime); err != nil {
 			return nil, err
 		}
+		m.DeliveredTime = deliveredTime.Time
 		msgs = append(msgs, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
+	if backward {
+		slices.Reverse(msgs)
+	}
 	return msgs, nil
 }
+
+// GetMessage returns the message with the given ID or model.ErrNotFound
+func (r *PostgresQueryRepository) GetMessage(ctx context.Context, id int64) (*model.Message, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+messageColumns+` FROM messages WHERE id = $1`, id)
+	return scanOne(row)
+}
+
+// GetMessageByExternalID returns the message the gateway acknowledged with the given ID or model.ErrNotFound
+func (r *PostgresQueryRepository) GetMessageByExternalID(ctx context.Context, externalID string) (*model.Message, error) {
+	row := r.db.QueryRowContext(ctx, `SELECT `+messageColumns+` FROM messages WHERE external_id = $1`, externalID)
+	return scanOne(row)
+}
+
+func scanOne(row *sql.Row) (*model.Message, error) {
+	m, err := scanMessage(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, model.ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// SearchMessages lists messages matching the filter ordered by ID, using keyset pagination on afterID
+func (r *PostgresQueryRepository) SearchMessages(
+	ctx context.Context,
+	filter model.MessageFilter,
+	afterID int64,
+	limit int,
+) ([]model.Message, error) {
+	var b whereBuilder
+	b.addFilter(filter)
+	b.add("id > $%d", afterID)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+messageColumns+`
+		FROM messages
+		WHERE `+b.where()+`
+		ORDER BY id ASC
+		LIMIT `+b.placeholder(limit), b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []model.Message
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+
+	return msgs, rows.Err()
+}
+
+// ListReplays returns the replay history of a message, oldest first
+func (r *PostgresQueryRepository) ListReplays(ctx context.Context, messageID int64) ([]model.MessageReplay, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, message_id, replayed_by, replayed_at, previous_phone_number, previous_content,
+		       previous_attempt_count, phone_number, content
+		FROM message_replays
+		WHERE message_id = $1
+		ORDER BY id ASC
+	`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var replays []model.MessageReplay
+	for rows.Next() {
+		var rp model.MessageReplay
+		if err := rows.Scan(
+			&rp.ID, &rp.MessageID, &rp.ReplayedBy, &rp.ReplayedAt, &rp.PreviousPhoneNumber, &rp.PreviousContent,
+			&rp.PreviousAttemptCount, &rp.PhoneNumber, &rp.Content,
+		); err != nil {
+			return nil, err
+		}
+		replays = append(replays, rp)
+	}
+
+	return replays, rows.Err()
+}
+
+// ListAttempts returns the send attempts of a message, oldest first
+func (r *PostgresQueryRepository) ListAttempts(ctx context.Context, messageID int64) ([]model.MessageAttempt, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, message_id, attempted_at, status_code, error, recoverable, external_id, duration_ms
+		FROM message_attempts
+		WHERE message_id = $1
+		ORDER BY id ASC
+	`, messageID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []model.MessageAttempt
+	for rows.Next() {
+		var (
+			a          model.MessageAttempt
+			statusCode sql.NullInt64
+			errText    sql.NullString
+			externalID sql.NullString
+		)
+		if err := rows.Scan(
+			&a.ID, &a.MessageID, &a.AttemptedAt, &statusCode, &errText, &a.Recoverable, &externalID, &a.DurationMS,
+		); err != nil {
+			return nil, err
+		}
+		a.StatusCode = int(statusCode.Int64)
+		a.Error = errText.String
+		a.ExternalID = externalID.String
+		attempts = append(attempts, a)
+	}
+
+	return attempts, rows.Err()
+}
+
+// CountByStatus counts the messages matching the filter per status; statuses without messages are omitted
+func (r *PostgresQueryRepository) CountByStatus(ctx context.Context, filter model.MessageFilter) (map[model.MessageStatus]int64, error) {
+	var b whereBuilder
+	b.addFilter(filter)
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT status, count(*)
+		FROM messages
+		WHERE `+b.where()+`
+		GROUP BY status
+	`, b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[model.MessageStatus]int64)
+	for rows.Next() {
+		var (
+			status model.MessageStatus
+			n      int64
+		)
+		if err := rows.Scan(&status, &n); err != nil {
+			return nil, err
+		}
+		counts[status] = n
+	}
+
+	return counts, rows.Err()
+}
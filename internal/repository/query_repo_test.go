@@ -2,13 +2,23 @@ package repository_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lazerion/outbox-relayer/internal/model"
 	"github.com/lazerion/outbox-relayer/internal/repository"
 )
 
+var messageColumns = []string{
+	"id", "phone_number", "content", "status", "sent_time", "external_id", "attempt_count",
+	"created_at", "client_id", "idempotency_key", "send_at", "expires_at",
+	"priority", "next_attempt_at", "max_attempts", "claimed_by", "lease_until",
+	"last_error", "failure_reason", "delivered_time", "callback_url",
+	"delivery_generation",
+}
+
 func TestPostgresQueryRepository_ListSentMessages(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	if err != nil {
@@ -19,16 +29,17 @@ func TestPostgresQueryRepository_ListSentMessages(t *testing.T) {
 	repo := repository.NewPostgresQueryRepository(db)
 
 	msgTime := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "phone_number", "content", "status", "sent_time", "external_id"}).
-		AddRow("1", "+123456789", "Hello", "sent", msgTime, "ext1").
-		AddRow("2", "+987654321", "World", "sent", msgTime.Add(time.Minute), "ext2")
+	rows := sqlmock.NewRows([]string{"id", "phone_number", "content", "status", "sent_time", "external_id", "delivered_time"}).
+		AddRow("1", "+123456789", "Hello", "sent", msgTime, "ext1", nil).
+		AddRow("2", "+987654321", "World", "delivered", msgTime.Add(time.Minute), "ext2", msgTime.Add(2*time.Minute))
 
 	// Expect query
-	mock.ExpectQuery(`SELECT id, phone_number, content, status, sent_time, external_id`).
-		WithArgs(sqlmock.AnyArg(), 2).
+	mock.ExpectQuery(`SELECT id, phone_number, content, status, sent_time, external_id, delivered_time\s+FROM messages\s+`+
+		`WHERE status IN \('sent', 'delivered', 'undelivered'\)`).
+		WithArgs(sqlmock.AnyArg(), int64(0), 2).
 		WillReturnRows(rows)
 
-	msgs, err := repo.ListSentMessages(context.Background(), time.Time{}, 2)
+	msgs, err := repo.ListSentMessages(context.Background(), repository.SentKey{}, false, 2)
 	if err != nil {
 		t.Fatalf("unexpected error: %s", err)
 	}
@@ -40,6 +51,353 @@ func TestPostgresQueryRepository_ListSentMessages(t *testing.T) {
 	if msgs[0].ID != 1 || msgs[1].ID != 2 {
 		t.Errorf("unexpected message IDs: %v, %v", msgs[0].ID, msgs[1].ID)
 	}
+	if !msgs[0].DeliveredTime.IsZero() || !msgs[1].DeliveredTime.Equal(msgTime.Add(2*time.Minute)) {
+		t.Errorf("unexpected delivered times: %v, %v", msgs[0].DeliveredTime, msgs[1].DeliveredTime)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_ListSentMessages_Backward(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	msgTime := time.Now()
+	// rows come back newest first and are returned in ascending order
+	rows := sqlmock.NewRows([]string{"id", "phone_number", "content", "status", "sent_time", "external_id", "delivered_time"}).
+		AddRow(int64(4), "+123", "b", "sent", msgTime, "ext4", nil).
+		AddRow(int64(3), "+123", "a", "sent", msgTime, "ext3", nil)
+
+	mock.ExpectQuery(`\(sent_time, id\) < \(\$1, \$2\)\s+ORDER BY sent_time DESC, id DESC`).
+		WithArgs(msgTime, int64(5), 2).
+		WillReturnRows(rows)
+
+	msgs, err := repo.ListSentMessages(context.Background(), repository.SentKey{SentTime: msgTime, ID: 5}, true, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 2 || msgs[0].ID != 3 || msgs[1].ID != 4 {
+		t.Errorf("expected ascending IDs 3, 4, got %+v", msgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_ListSentMessages_RowError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	msgTime := time.Now()
+	rowErr := errors.New("connection reset")
+	rows := sqlmock.NewRows([]string{"id", "phone_number", "content", "status", "sent_time", "external_id", "delivered_time"}).
+		AddRow(int64(1), "+123", "a", "sent", msgTime, "ext1", nil).
+		AddRow(int64(2), "+123", "b", "sent", msgTime, "ext2", nil).
+		RowError(1, rowErr)
+
+	mock.ExpectQuery(`SELECT id, phone_number, content, status, sent_time, external_id, delivered_time`).
+		WillReturnRows(rows)
+
+	// a page cut short by the error must not pass for a complete one
+	msgs, err := repo.ListSentMessages(context.Background(), repository.SentKey{}, false, 2)
+	if !errors.Is(err, rowErr) {
+		t.Fatalf("expected row error, got %v", err)
+	}
+	if msgs != nil {
+		t.Errorf("expected no messages, got %+v", msgs)
+	}
+}
+
+func TestPostgresQueryRepository_GetMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	// pending messages have no sent_time, external_id or idempotency_key yet
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE id = \$1`).
+		WithArgs(int64(5)).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(5), "+123", "Hello", "pending", nil, nil, 2, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 0))
+
+	msg, err := repo.GetMessage(context.Background(), 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.ID != 5 || msg.AttemptCount != 2 || msg.ExternalID != "" || !msg.SentTime.IsZero() {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_GetMessageByExternalID(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	sentAt := time.Now()
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE external_id = \$1`).
+		WithArgs("ext-1").
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(5), "+123", "Hello", "sent", sentAt, "ext-1", 0, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 0))
+	mock.ExpectQuery(`SELECT .* FROM messages WHERE external_id = \$1`).
+		WithArgs("missing").
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+
+	msg, err := repo.GetMessageByExternalID(context.Background(), "ext-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.ExternalID != "ext-1" || !msg.SentTime.Equal(sentAt) {
+		t.Errorf("unexpected message: %+v", msg)
+	}
+
+	_, err = repo.GetMessageByExternalID(context.Background(), "missing")
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_SearchMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	minAttempts := 2
+	createdFrom := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	filter := model.MessageFilter{
+		Statuses:    []model.MessageStatus{model.StatusPending, model.StatusFailed},
+		PhoneNumber: "+123",
+		CreatedFrom: createdFrom,
+		MinAttempts: &minAttempts,
+	}
+
+	mock.ExpectQuery(`WHERE status = ANY\(\$1\) AND phone_number = \$2 AND created_at >= \$3 AND attempt_count >= \$4 AND id > \$5\s+ORDER BY id ASC\s+LIMIT \$6`).
+		WithArgs(sqlmock.AnyArg(), "+123", createdFrom, 2, int64(10), 20).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(11), "+123", "Hello", "failed", nil, nil, 3, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 0))
+
+	msgs, err := repo.SearchMessages(context.Background(), filter, 10, 20)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 1 || msgs[0].ID != 11 {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_SearchMessages_NoFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	mock.ExpectQuery(`WHERE id > \$1\s+ORDER BY id ASC\s+LIMIT \$2`).
+		WithArgs(int64(0), 5).
+		WillReturnRows(sqlmock.NewRows(messageColumns))
+
+	msgs, err := repo.SearchMessages(context.Background(), model.MessageFilter{}, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 0 {
+		t.Errorf("expected no messages, got %d", len(msgs))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_SearchMessages_FailureReason(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	mock.ExpectQuery(`WHERE status = ANY\(\$1\) AND failure_reason = ANY\(\$2\) AND id > \$3`).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), int64(0), 5).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(4), "+123", "otp", "failed", nil, nil, 3, time.Now(), "", nil, nil, nil, 2, nil, nil, nil, nil,
+				"upstream error (status 503): unavailable", "max_attempts", nil, nil, 0))
+
+	filter := model.MessageFilter{
+		Statuses:       []model.MessageStatus{model.StatusFailed},
+		FailureReasons: []model.FailureReason{model.FailureMaxAttempts},
+	}
+	msgs, err := repo.SearchMessages(context.Background(), filter, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 1 || msgs[0].FailureReason != model.FailureMaxAttempts || msgs[0].LastError == "" {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_ListReplays(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	replayedAt := time.Now()
+	mock.ExpectQuery(`FROM message_replays\s+WHERE message_id = \$1\s+ORDER BY id ASC`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "message_id", "replayed_by", "replayed_at", "previous_phone_number", "previous_content",
+			"previous_attempt_count", "phone_number", "content",
+		}).AddRow(int64(1), int64(7), "ops", replayedAt, "+111", "typo", 3, "+111", "fixed"))
+
+	replays, err := repo.ListReplays(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(replays) != 1 || replays[0].ReplayedBy != "ops" || replays[0].PreviousAttemptCount != 3 || replays[0].Content != "fixed" {
+		t.Errorf("unexpected replays: %+v", replays)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_ListAttempts(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	attemptedAt := time.Now()
+	mock.ExpectQuery(`FROM message_attempts\s+WHERE message_id = \$1\s+ORDER BY id ASC`).
+		WithArgs(int64(7)).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "message_id", "attempted_at", "status_code", "error", "recoverable", "external_id", "duration_ms",
+		}).
+			AddRow(int64(1), int64(7), attemptedAt, nil, "upstream error: timeout", true, nil, int64(5000)).
+			AddRow(int64(2), int64(7), attemptedAt, int64(202), nil, false, "ext-7", int64(120)))
+
+	attempts, err := repo.ListAttempts(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(attempts))
+	}
+	if attempts[0].StatusCode != 0 || !attempts[0].Recoverable || attempts[0].Error != "upstream error: timeout" {
+		t.Errorf("unexpected first attempt: %+v", attempts[0])
+	}
+	if attempts[1].StatusCode != 202 || attempts[1].ExternalID != "ext-7" || attempts[1].DurationMS != 120 {
+		t.Errorf("unexpected second attempt: %+v", attempts[1])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_SearchMessages_Scheduled(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	sendAt := time.Now().Add(time.Hour)
+	mock.ExpectQuery(`WHERE send_at > \$1 AND id > \$2`).
+		WithArgs(sqlmock.AnyArg(), int64(0), 5).
+		WillReturnRows(sqlmock.NewRows(messageColumns).
+			AddRow(int64(3), "+123", "later", "pending", nil, nil, 0, time.Now(), "", nil, sendAt, nil, 2, nil, nil, nil, nil, nil, nil, nil, nil, 0))
+
+	scheduled := true
+	msgs, err := repo.SearchMessages(context.Background(), model.MessageFilter{Scheduled: &scheduled}, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(msgs) != 1 || !msgs[0].SendAt.Equal(sendAt) {
+		t.Errorf("unexpected messages: %+v", msgs)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestPostgresQueryRepository_CountByStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("failed to open sqlmock: %s", err)
+	}
+	defer db.Close()
+
+	repo := repository.NewPostgresQueryRepository(db)
+
+	createdFrom := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT status, count\(\*\)\s+FROM messages\s+WHERE created_at >= \$1\s+GROUP BY status`).
+		WithArgs(createdFrom).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).
+			AddRow("sent", int64(40)).
+			AddRow("expired", int64(2)))
+
+	counts, err := repo.CountByStatus(context.Background(), model.MessageFilter{CreatedFrom: createdFrom})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if counts[model.StatusSent] != 40 || counts[model.StatusExpired] != 2 || len(counts) != 2 {
+		t.Errorf("unexpected counts: %v", counts)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Errorf("there were unfulfilled expectations: %s", err)
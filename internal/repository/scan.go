@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+// messageColumns lists the columns read by scanMessage, in order
+const messageColumns = `id, phone_number, content, status, sent_time, external_id, attempt_count,
+       created_at, client_id, idempotency_key, send_at, expires_at,
+       priority, next_attempt_at, max_attempts, claimed_by, lease_until,
+       last_error, failure_reason, delivered_time, callback_url,
+       delivery_generation`
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanMessage reads a full message selected with messageColumns, mapping NULLs to zero values
+func scanMessage(row rowScanner) (model.Message, error) {
+	var (
+		m              model.Message
+		sentTime       sql.NullTime
+		externalID     sql.NullString
+		idempotencyKey sql.NullString
+		sendAt         sql.NullTime
+		expiresAt      sql.NullTime
+		nextAttemptAt  sql.NullTime
+		maxAttempts    sql.NullInt64
+		claimedBy      sql.NullString
+		leaseUntil     sql.NullTime
+		lastError      sql.NullString
+		failureReason  sql.NullString
+		deliveredTime  sql.NullTime
+		callbackURL    sql.NullString
+	)
+	err := row.Scan(
+		&m.ID, &m.PhoneNumber, &m.Content, &m.Status, &sentTime, &externalID, &m.AttemptCount,
+		&m.CreatedAt, &m.ClientID, &idempotencyKey, &sendAt, &expiresAt,
+		&m.Priority, &nextAttemptAt, &maxAttempts, &claimedBy, &leaseUntil,
+		&lastError, &failureReason, &deliveredTime, &callbackURL,
+		&m.DeliveryGeneration,
+	)
+	if err != nil {
+		return model.Message{}, err
+	}
+
+	m.SentTime = sentTime.Time
+	m.ExternalID = externalID.String
+	m.IdempotencyKey = idempotencyKey.String
+	m.SendAt = sendAt.Time
+	m.ExpiresAt = expiresAt.Time
+	m.NextAttemptAt = nextAttemptAt.Time
+	m.MaxAttempts = int(maxAttempts.Int64)
+	m.ClaimedBy = claimedBy.String
+	m.LeaseUntil = leaseUntil.Time
+	m.LastError = lastError.String
+	m.FailureReason = model.FailureReason(failureReason.String)
+	m.DeliveredTime = deliveredTime.Time
+	m.CallbackURL = callbackURL.String
+	return m, nil
+}
+
+// nullTime maps the zero time to NULL; timestamps are stored without time zone in UTC
+func nullTime(t time.Time) sql.NullTime {
+	if t.IsZero() {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+// nullInt maps zero to NULL, for optional overrides where zero means the default
+func nullInt(n int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(n), Valid: n != 0}
+}
+
+// nullString maps the empty string to NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -5,6 +5,8 @@ import (
 	"log"
 
 	"go.uber.org/fx"
+
+	"github.com/lazerion/outbox-relayer/internal/config"
 )
 
 // StartStopSchedulerHook starts the scheduler on Fx startup and stops on shutdown
@@ -23,7 +25,32 @@ func StartStopSchedulerHook(lc fx.Lifecycle, sched SchedulerInterface) {
 	})
 }
 
+// StartStopNotifyListenerHook listens for new message notifications while the app runs, if enabled
+func StartStopNotifyListenerHook(lc fx.Lifecycle, cfg *config.Config, sched SchedulerInterface) {
+	if !cfg.Schedule.Listen {
+		return
+	}
+
+	var listener *NotifyListener
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			log.Println("Starting notify listener...")
+			listener = NewNotifyListener(NewPostgresNotifier(cfg.Postgres.DSN()), sched)
+			listener.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			log.Println("Stopping notify listener...")
+			listener.Stop()
+			return nil
+		},
+	})
+}
+
 var ModuleWithLifeCycle = fx.Module(
 	"scheduler-lifecycle",
 	fx.Invoke(StartStopSchedulerHook),
+	fx.Invoke(StartStopNotifyListenerHook),
+	fx.Invoke(fx.Annotate(StartStopSchedulerHook, fx.ParamTags(``, `name:"reconciler"`))),
+	fx.Invoke(fx.Annotate(StartStopSchedulerHook, fx.ParamTags(``, `name:"callbacks"`))),
 )
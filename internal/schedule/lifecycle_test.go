@@ -20,6 +20,7 @@ type mockScheduler struct {
 func (m *mockScheduler) Start(ctx context.Context) { atomic.StoreInt32(&m.started, 1) }
 func (m *mockScheduler) Stop()                     { atomic.StoreInt32(&m.stopped, 1) }
 func (m *mockScheduler) IsRunning() bool           { return atomic.LoadInt32(&m.started) == 1 }
+func (m *mockScheduler) Trigger()                  {}
 
 func TestStartStopSchedulerHook(t *testing.T) {
 	mockSched := &mockScheduler{}
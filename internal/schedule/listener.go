@@ -0,0 +1,116 @@
+package schedule
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// NotifyChannel is the Postgres channel notified by the messages insert trigger
+const NotifyChannel = "messages_pending"
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	// pingInterval detects a silently dropped connection, which pq.Listener only notices when it is used
+	pingInterval = 90 * time.Second
+)
+
+// Notifier is the subset of pq.Listener used by NotifyListener
+type Notifier interface {
+	Listen(channel string) error
+	NotificationChannel() <-chan *pq.Notification
+	Ping() error
+	Close() error
+}
+
+// NotifyListener triggers the scheduler whenever a notification arrives on NotifyChannel.
+// Reconnecting after a connection loss is left to the Notifier; since notifications sent while disconnected are
+// lost, the scheduler is triggered once more after every reconnect.
+type NotifyListener struct {
+	notifier Notifier
+	sched    SchedulerInterface
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewNotifyListener(notifier Notifier, sched SchedulerInterface) *NotifyListener {
+	return &NotifyListener{notifier: notifier, sched: sched}
+}
+
+// NewPostgresNotifier opens a dedicated LISTEN connection that reconnects on its own with exponential backoff
+func NewPostgresNotifier(dsn string) Notifier {
+	return pq.NewListener(dsn, minReconnectInterval, maxReconnectInterval, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			log.Printf("notify listener disconnected: %v", err)
+		case pq.ListenerEventReconnected:
+			log.Println("notify listener reconnected")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Printf("notify listener connection attempt failed: %v", err)
+		}
+	})
+}
+
+// Start listens in the background until Stop is called
+func (l *NotifyListener) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.cancel = cancel
+
+	l.wg.Add(1)
+	go func() {
+		defer l.wg.Done()
+		l.listen(ctx)
+	}()
+}
+
+// Stop closes the connection and waits for the listener to exit
+func (l *NotifyListener) Stop() {
+	if l.cancel == nil {
+		return
+	}
+	l.cancel()
+	// unblocks a Listen still waiting for the first connection
+	if err := l.notifier.Close(); err != nil {
+		log.Printf("failed to close notify listener: %v", err)
+	}
+	l.wg.Wait()
+}
+
+func (l *NotifyListener) listen(ctx context.Context) {
+	// blocks until the first connection succeeds
+	if err := l.notifier.Listen(NotifyChannel); err != nil {
+		if ctx.Err() == nil {
+			log.Printf("failed to listen on %s, relying on polling: %v", NotifyChannel, err)
+		}
+		return
+	}
+	log.Printf("listening for notifications on %s", NotifyChannel)
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	notifications := l.notifier.NotificationChannel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case _, ok := <-notifications:
+			if !ok {
+				return
+			}
+			// a nil notification follows a reconnect, so it triggers a run as well
+			l.sched.Trigger()
+		case <-ticker.C:
+			go func() {
+				if err := l.notifier.Ping(); err != nil {
+					log.Printf("notify listener ping failed: %v", err)
+				}
+			}()
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package schedule_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/schedule"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeNotifier struct {
+	ch        chan *pq.Notification
+	listenErr error
+	channel   atomic.Value
+	closed    atomic.Bool
+}
+
+func newFakeNotifier() *fakeNotifier {
+	return &fakeNotifier{ch: make(chan *pq.Notification)}
+}
+
+func (f *fakeNotifier) Listen(channel string) error {
+	f.channel.Store(channel)
+	return f.listenErr
+}
+
+func (f *fakeNotifier) NotificationChannel() <-chan *pq.Notification { return f.ch }
+
+func (f *fakeNotifier) Ping() error { return nil }
+
+func (f *fakeNotifier) Close() error {
+	f.closed.Store(true)
+	return nil
+}
+
+type triggerScheduler struct {
+	mockScheduler
+	triggers atomic.Int32
+}
+
+func (m *triggerScheduler) Trigger() { m.triggers.Add(1) }
+
+func TestNotifyListener_TriggersOnNotification(t *testing.T) {
+	notifier := newFakeNotifier()
+	sched := &triggerScheduler{}
+	l := schedule.NewNotifyListener(notifier, sched)
+
+	l.Start()
+	notifier.ch <- &pq.Notification{Channel: schedule.NotifyChannel}
+	// a nil notification is sent after a reconnect
+	notifier.ch <- nil
+	l.Stop()
+
+	assert.Equal(t, schedule.NotifyChannel, notifier.channel.Load())
+	assert.Equal(t, int32(2), sched.triggers.Load())
+	assert.True(t, notifier.closed.Load())
+}
+
+func TestNotifyListener_ListenError(t *testing.T) {
+	notifier := newFakeNotifier()
+	notifier.listenErr = errors.New("connection refused")
+	sched := &triggerScheduler{}
+	l := schedule.NewNotifyListener(notifier, sched)
+
+	l.Start()
+	done := make(chan struct{})
+	go func() {
+		l.Stop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("listener did not stop")
+	}
+	assert.Zero(t, sched.triggers.Load())
+}
@@ -7,12 +7,46 @@ import (
 )
 
 func NewSchedulerProvider(job Job, cfg *config.Config) SchedulerInterface {
-	return NewScheduler(job, cfg.Schedule.Interval)
+	var opts []SchedulerOption
+	if cfg.Schedule.Debounce > 0 {
+		opts = append(opts, WithDebounce(cfg.Schedule.Debounce))
+	}
+	return NewScheduler(job, cfg.Schedule.Interval, opts...)
+}
+
+// NewReconcileSchedulerProvider schedules the reconciler job every reconciler.interval,
+// falling back to schedule.interval when it is not set
+func NewReconcileSchedulerProvider(job Job, cfg *config.Config) SchedulerInterface {
+	interval := cfg.Reconciler.Interval
+	if interval <= 0 {
+		interval = cfg.Schedule.Interval
+	}
+	return NewScheduler(job, interval)
+}
+
+// NewCallbackSchedulerProvider schedules the callback dispatcher every callbacks.interval,
+// falling back to schedule.interval when it is not set
+func NewCallbackSchedulerProvider(job Job, cfg *config.Config) SchedulerInterface {
+	interval := cfg.Callbacks.Interval
+	if interval <= 0 {
+		interval = cfg.Schedule.Interval
+	}
+	return NewScheduler(job, interval)
 }
 
 var Module = fx.Module(
 	"scheduler",
 	fx.Provide(
 		NewSchedulerProvider,
+		fx.Annotate(
+			NewReconcileSchedulerProvider,
+			fx.ParamTags(`name:"reconciler"`),
+			fx.ResultTags(`name:"reconciler"`),
+		),
+		fx.Annotate(
+			NewCallbackSchedulerProvider,
+			fx.ParamTags(`name:"callbacks"`),
+			fx.ResultTags(`name:"callbacks"`),
+		),
 	),
 )
@@ -15,11 +15,16 @@ type SchedulerInterface interface {
 	Start(parentCtx context.Context)
 	Stop()
 	IsRunning() bool
+	Trigger()
 }
 
 type Scheduler struct {
 	job      Job
 	interval time.Duration
+	debounce time.Duration
+	wake     chan struct{}
+	// rerun is set by a trigger arriving while the job runs, so the run picks up what triggered it
+	rerun bool
 
 	mu      sync.Mutex
 	running bool
@@ -29,11 +34,30 @@ type Scheduler struct {
 	wgMain  sync.WaitGroup
 }
 
-func NewScheduler(job Job, interval time.Duration) SchedulerInterface {
-	return &Scheduler{
+// DefaultDebounce is the window in which triggers are coalesced when none is configured
+const DefaultDebounce = 100 * time.Millisecond
+
+// SchedulerOption configures optional Scheduler behavior
+type SchedulerOption func(*Scheduler)
+
+// WithDebounce sets the window in which triggers are coalesced into a single run
+func WithDebounce(d time.Duration) SchedulerOption {
+	return func(s *Scheduler) {
+		s.debounce = d
+	}
+}
+
+func NewScheduler(job Job, interval time.Duration, opts ...SchedulerOption) SchedulerInterface {
+	s := &Scheduler{
 		job:      job,
 		interval: interval,
+		debounce: DefaultDebounce,
+		wake:     make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Start begins periodic execution of the job in a non-blocking way
@@ -56,6 +80,8 @@ func (s *Scheduler) Start(parentCtx context.Context) {
 
 		ticker := time.NewTicker(s.interval)
 		defer ticker.Stop()
+		// nil until a trigger arrives; the run starts once the debounce window has passed
+		var debounced <-chan time.Time
 
 		for {
 			select {
@@ -66,6 +92,13 @@ func (s *Scheduler) Start(parentCtx context.Context) {
 				return
 			case <-ticker.C:
 				s.runOnce(ctx)
+			case <-s.wake:
+				if debounced == nil {
+					debounced = time.After(s.debounce)
+				}
+			case <-debounced:
+				debounced = nil
+				s.runTriggered(ctx)
 			}
 		}
 	}()
@@ -83,6 +116,16 @@ func (s *Scheduler) Stop() {
 	s.wgMain.Wait()
 }
 
+// Trigger requests a run without waiting for the next tick. Triggers within the debounce window result in one run,
+// and a trigger arriving while the job runs makes it run once more right after.
+func (s *Scheduler) Trigger() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+		// a trigger is already pending
+	}
+}
+
 // IsRunning checks the state safely
 func (s *Scheduler) IsRunning() bool {
 	s.mu.Lock()
@@ -90,6 +133,18 @@ func (s *Scheduler) IsRunning() bool {
 	return s.ctx != nil
 }
 
+// runTriggered starts the job, or asks the running job to run again once it finishes
+func (s *Scheduler) runTriggered(ctx context.Context) {
+	s.mu.Lock()
+	if s.running {
+		s.rerun = true
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+	s.runOnce(ctx)
+}
+
 // runOnce ensures no overlapping job executions
 func (s *Scheduler) runOnce(ctx context.Context) {
 	s.mu.Lock()
@@ -103,15 +158,30 @@ func (s *Scheduler) runOnce(ctx context.Context) {
 
 	s.wgJob.Add(1)
 	go func() {
-		defer func() {
-			s.mu.Lock()
-			s.running = false
-			s.mu.Unlock()
-			s.wgJob.Done()
-		}()
-
-		if err := s.job.Run(ctx); err != nil {
-			log.Println("job error:", err)
+		defer s.wgJob.Done()
+
+		// runs again while it was triggered meanwhile, still as a single non-overlapping execution
+		for {
+			if err := s.job.Run(ctx); err != nil {
+				log.Println("job error:", err)
+			}
+			if !s.finish(ctx) {
+				return
+			}
 		}
 	}()
 }
+
+// finish marks the job as no longer running, unless a trigger arrived meanwhile; then it reports that the job
+// has to run again. Both happen under the lock so a concurrent trigger is never lost.
+func (s *Scheduler) finish(ctx context.Context) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rerun && ctx.Err() == nil {
+		s.rerun = false
+		return true
+	}
+	s.running = false
+	s.rerun = false
+	return false
+}
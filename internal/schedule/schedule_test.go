@@ -124,3 +124,35 @@ func TestScheduler_MultipleStart(t *testing.T) {
 	assert.GreaterOrEqual(t, count, int32(1), "scheduler should have run at least once")
 	assert.False(t, s.IsRunning())
 }
+
+func TestScheduler_TriggerDebounced(t *testing.T) {
+	job := &MockJob{}
+	s := schedule.NewScheduler(job, time.Hour, schedule.WithDebounce(20*time.Millisecond))
+
+	s.Start(context.Background())
+	defer s.Stop()
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&job.runCount), "scheduler should run once on start")
+
+	for range 5 {
+		s.Trigger()
+	}
+	time.Sleep(60 * time.Millisecond)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&job.runCount), "triggers within the debounce window should run the job once")
+}
+
+func TestScheduler_TriggerWhileRunning(t *testing.T) {
+	job := &MockJob{delay: 30 * time.Millisecond}
+	s := schedule.NewScheduler(job, time.Hour, schedule.WithDebounce(time.Millisecond))
+
+	s.Start(context.Background())
+	defer s.Stop()
+	time.Sleep(10 * time.Millisecond)
+
+	// the first run is still in progress, so the trigger makes it run once more afterwards
+	s.Trigger()
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&job.runCount))
+}
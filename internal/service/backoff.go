@@ -0,0 +1,65 @@
+package service
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// BackoffPolicy computes how long a message waits before it is retried after a recoverable failure.
+// The delay grows from Base by Multiplier per failed attempt up to MaxDelay; Jitter randomly shortens it
+// by up to that fraction so retries of a failed batch spread out. The zero value retries immediately.
+type BackoffPolicy struct {
+	Base       time.Duration
+	Multiplier float64
+	MaxDelay   time.Duration
+	Jitter     float64
+	// Rand returns a number in [0, 1) used for jitter; nil uses math/rand
+	Rand func() float64
+}
+
+// Validate rejects policies that would shrink the delay or jitter beyond the delay itself
+func (p BackoffPolicy) Validate() error {
+	if p.Base < 0 || p.MaxDelay < 0 {
+		return errors.New("backoff delays must not be negative")
+	}
+	if p.Multiplier != 0 && p.Multiplier < 1 {
+		return errors.New("backoff multiplier must be at least 1")
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return errors.New("backoff jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// Delay returns the wait after the given number of failed attempts, starting at 1
+func (p BackoffPolicy) Delay(attempts int) time.Duration {
+	if p.Base <= 0 || attempts < 1 {
+		return 0
+	}
+
+	multiplier := max(p.Multiplier, 1)
+	delay := float64(p.Base) * math.Pow(multiplier, float64(attempts-1))
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	// guards the conversion once the exponent overflows without a MaxDelay
+	if delay > math.MaxInt64 {
+		delay = math.MaxInt64
+	}
+
+	if p.Jitter > 0 {
+		rnd := p.Rand
+		if rnd == nil {
+			rnd = rand.Float64
+		}
+		delay -= delay * p.Jitter * rnd()
+	}
+	return time.Duration(delay)
+}
+
+// NextAttempt returns when a message that failed attempts times at now may be retried
+func (p BackoffPolicy) NextAttempt(now time.Time, attempts int) time.Time {
+	return now.Add(p.Delay(attempts))
+}
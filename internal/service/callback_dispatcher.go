@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/callback"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+	"github.com/lazerion/outbox-relayer/internal/schedule"
+)
+
+// CallbackDispatcher pushes the status changes queued in the callback outbox to the producers' callback URLs.
+// It runs on its own schedule with its own leases and retries, so a slow or failing producer never holds up
+// relaying messages.
+type CallbackDispatcher struct {
+	repo repository.CallbackRepository
+	// notifier is nil when notifications cannot be signed
+	notifier    callback.Notifier
+	batch       int
+	timeout     time.Duration
+	maxAttempts int
+	backoff     BackoffPolicy
+	concurrency int
+	now         func() time.Time
+	owner       string
+	lease       time.Duration
+}
+
+// callbackOutcome is what the dispatcher did with a claimed event
+type callbackOutcome string
+
+const (
+	callbackDelivered callbackOutcome = "delivered"
+	callbackRetried   callbackOutcome = "retried"
+	callbackFailed    callbackOutcome = "failed"
+)
+
+// DispatcherOption configures optional CallbackDispatcher behavior
+type DispatcherOption func(*CallbackDispatcher)
+
+// WithDispatcherBackoff sets the delay before a failed notification is tried again
+func WithDispatcherBackoff(policy BackoffPolicy) DispatcherOption {
+	return func(d *CallbackDispatcher) {
+		d.backoff = policy
+	}
+}
+
+// WithDispatcherConcurrency sets how many notifications of a batch are sent at the same time
+func WithDispatcherConcurrency(n int) DispatcherOption {
+	return func(d *CallbackDispatcher) {
+		d.concurrency = max(n, 1)
+	}
+}
+
+// WithDispatcherLease sets how long claimed events are reserved for the dispatcher
+func WithDispatcherLease(lease time.Duration) DispatcherOption {
+	return func(d *CallbackDispatcher) {
+		d.lease = lease
+	}
+}
+
+// WithDispatcherOwner sets the name stamped on claimed events, which must be unique per instance
+func WithDispatcherOwner(owner string) DispatcherOption {
+	return func(d *CallbackDispatcher) {
+		d.owner = owner
+	}
+}
+
+// WithDispatcherClock replaces time.Now, for tests
+func WithDispatcherClock(now func() time.Time) DispatcherOption {
+	return func(d *CallbackDispatcher) {
+		d.now = now
+	}
+}
+
+// NewCallbackDispatcher creates the dispatcher job. A notification failing maxAttempts times is given up on;
+// a non-positive maxAttempts retries forever.
+func NewCallbackDispatcher(repo repository.CallbackRepository, notifier callback.Notifier, batch int,
+	timeout time.Duration, maxAttempts int, opts ...DispatcherOption) schedule.Job {
+	d := &CallbackDispatcher{
+		repo:        repo,
+		notifier:    notifier,
+		batch:       batch,
+		timeout:     timeout,
+		maxAttempts: maxAttempts,
+		concurrency: 1,
+		now:         time.Now,
+		owner:       defaultOwner(),
+		lease:       DefaultLease,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run claims a batch of due events and notifies their producers. Delivered events are done; failed ones are
+// retried after the backoff until they run out of attempts. Only outcomes that could not be recorded are returned
+// as errors; those events are claimed again once their lease runs out.
+// Without a Notifier nothing is claimed, so the events stay queued until notifications can be signed.
+func (d *CallbackDispatcher) Run(ctx context.Context) error {
+	if d.notifier == nil {
+		return nil
+	}
+
+	events, err := d.repo.ClaimCallbacks(ctx, d.owner, d.lease, d.batch)
+	if err != nil {
+		return fmt.Errorf("claim callback events: %w", err)
+	}
+
+	work := make(chan model.CallbackEvent)
+	var (
+		wg                         sync.WaitGroup
+		mu                         sync.Mutex
+		delivered, retried, failed int
+		errs                       []error
+	)
+	for range min(d.concurrency, len(events)) {
+		wg.Go(func() {
+			for e := range work {
+				outcome, err := d.dispatch(ctx, e)
+				mu.Lock()
+				switch {
+				case err != nil:
+					errs = append(errs, fmt.Errorf("record %s outcome of callback event %d: %w", outcome, e.ID, err))
+				case outcome == callbackDelivered:
+					delivered++
+				case outcome == callbackRetried:
+					retried++
+				default:
+					failed++
+				}
+				mu.Unlock()
+			}
+		})
+	}
+	for _, e := range events {
+		work <- e
+	}
+	close(work)
+	wg.Wait()
+
+	if len(events) > 0 {
+		log.Printf("dispatched %d callback events: %d delivered, %d retried, %d failed, %d not recorded",
+			len(events), delivered, retried, failed, len(errs))
+	}
+	return errors.Join(errs...)
+}
+
+// dispatch sends a single claimed event and records the outcome in its own update
+func (d *CallbackDispatcher) dispatch(ctx context.Context, e model.CallbackEvent) (callbackOutcome, error) {
+	notifyCtx, cancel := context.WithTimeout(ctx, d.timeout)
+	err := d.notifier.Notify(notifyCtx, e.URL, callback.NewNotification(e))
+	cancel()
+
+	now := d.now()
+	if err == nil {
+		return callbackDelivered, d.repo.MarkCallbackDelivered(ctx, e.ID, d.owner, now)
+	}
+
+	attempts := e.AttemptCount + 1
+	if d.maxAttempts > 0 && attempts >= d.maxAttempts {
+		log.Printf("giving up on callback event %d of message ID %d after %d attempts: %v", e.ID, e.MessageID, attempts, err)
+		return callbackFailed, d.repo.MarkCallbackFailed(ctx, e.ID, d.owner, err.Error())
+	}
+	log.Printf("callback event %d of message ID %d failed, retrying: %v", e.ID, e.MessageID, err)
+	return callbackRetried, d.repo.RetryCallback(ctx, e.ID, d.owner, d.backoff.NextAttempt(now, attempts), err.Error())
+}
@@ -0,0 +1,133 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/callback"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCallbackRepository hands out outbox events and records how their delivery ended
+type MockCallbackRepository struct {
+	mu        sync.Mutex
+	Events    []model.CallbackEvent
+	Claims    int
+	Delivered []int64
+	Retries   map[int64]time.Time
+	Failed    map[int64]string
+	UpdateErr error
+}
+
+func (m *MockCallbackRepository) SetClientCallback(ctx context.Context, cb model.ClientCallback) (*model.ClientCallback, error) {
+	return &cb, nil
+}
+func (m *MockCallbackRepository) GetClientCallback(ctx context.Context, clientID string) (*model.ClientCallback, error) {
+	return nil, model.ErrCallbackNotFound
+}
+func (m *MockCallbackRepository) DeleteClientCallback(ctx context.Context, clientID string) error {
+	return nil
+}
+func (m *MockCallbackRepository) ClaimCallbacks(ctx context.Context, owner string, lease time.Duration, batchSize int) ([]model.CallbackEvent, error) {
+	m.Claims++
+	return m.Events, nil
+}
+func (m *MockCallbackRepository) MarkCallbackDelivered(ctx context.Context, id int64, owner string, deliveredAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Delivered = append(m.Delivered, id)
+	return m.UpdateErr
+}
+func (m *MockCallbackRepository) RetryCallback(ctx context.Context, id int64, owner string, nextAttemptAt time.Time, dispatchError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Retries == nil {
+		m.Retries = map[int64]time.Time{}
+	}
+	m.Retries[id] = nextAttemptAt
+	return m.UpdateErr
+}
+func (m *MockCallbackRepository) MarkCallbackFailed(ctx context.Context, id int64, owner string, dispatchError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.Failed == nil {
+		m.Failed = map[int64]string{}
+	}
+	m.Failed[id] = dispatchError
+	return m.UpdateErr
+}
+
+// MockNotifier fails notifications to URLs listed in Fail
+type MockNotifier struct {
+	mu   sync.Mutex
+	Fail map[string]error
+	Got  []callback.Notification
+}
+
+func (m *MockNotifier) Notify(ctx context.Context, url string, n callback.Notification) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Got = append(m.Got, n)
+	return m.Fail[url]
+}
+
+func TestCallbackDispatcher_Run(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	repo := &MockCallbackRepository{Events: []model.CallbackEvent{
+		{ID: 1, MessageID: 10, URL: "https://ok.example.com", Status: model.StatusSent},
+		{ID: 2, MessageID: 11, URL: "https://down.example.com", Status: model.StatusFailed, AttemptCount: 1},
+		{ID: 3, MessageID: 12, URL: "https://down.example.com", Status: model.StatusExpired, AttemptCount: 2},
+	}}
+	notifier := &MockNotifier{Fail: map[string]error{"https://down.example.com": errors.New("unexpected status code: 503")}}
+
+	d := service.NewCallbackDispatcher(repo, notifier, 10, time.Second, 3,
+		service.WithDispatcherBackoff(service.BackoffPolicy{Base: time.Minute, Multiplier: 2}),
+		service.WithDispatcherConcurrency(2),
+		service.WithDispatcherClock(func() time.Time { return now }))
+	require.NoError(t, d.Run(context.Background()))
+
+	require.Equal(t, []int64{1}, repo.Delivered)
+	// the second failure of event 2 waits twice the base delay
+	require.Equal(t, map[int64]time.Time{2: now.Add(2 * time.Minute)}, repo.Retries)
+	require.Equal(t, map[int64]string{3: "unexpected status code: 503"}, repo.Failed)
+	require.Len(t, notifier.Got, 3)
+}
+
+func TestCallbackDispatcher_Run_UpdateFails(t *testing.T) {
+	repo := &MockCallbackRepository{
+		Events:    []model.CallbackEvent{{ID: 1, MessageID: 10, URL: "https://ok.example.com"}},
+		UpdateErr: model.ErrLeaseLost,
+	}
+
+	d := service.NewCallbackDispatcher(repo, &MockNotifier{}, 10, time.Second, 3)
+	err := d.Run(context.Background())
+
+	require.ErrorIs(t, err, model.ErrLeaseLost)
+	require.ErrorContains(t, err, "record delivered outcome of callback event 1")
+}
+
+func TestCallbackDispatcher_Run_RetriesForeverWithoutMaxAttempts(t *testing.T) {
+	repo := &MockCallbackRepository{Events: []model.CallbackEvent{{ID: 1, URL: "https://down.example.com", AttemptCount: 50}}}
+	notifier := &MockNotifier{Fail: map[string]error{"https://down.example.com": errors.New("connection refused")}}
+
+	d := service.NewCallbackDispatcher(repo, notifier, 10, time.Second, 0)
+	require.NoError(t, d.Run(context.Background()))
+
+	require.Contains(t, repo.Retries, int64(1))
+	require.Empty(t, repo.Failed)
+}
+
+func TestCallbackDispatcher_Run_WithoutNotifier(t *testing.T) {
+	repo := &MockCallbackRepository{Events: []model.CallbackEvent{{ID: 1, URL: "https://ok.example.com"}}}
+
+	d := service.NewCallbackDispatcher(repo, nil, 10, time.Second, 3)
+	require.NoError(t, d.Run(context.Background()))
+
+	require.Zero(t, repo.Claims, "events stay queued until notifications can be signed")
+	require.Empty(t, repo.Delivered)
+}
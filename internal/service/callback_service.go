@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+)
+
+type CallbackServiceInterface interface {
+	SetClientCallback(ctx context.Context, cb model.ClientCallback) (*model.ClientCallback, error)
+	GetClientCallback(ctx context.Context, clientID string) (*model.ClientCallback, error)
+	DeleteClientCallback(ctx context.Context, clientID string) error
+}
+
+type CallbackService struct {
+	repo repository.CallbackRepository
+}
+
+func NewCallbackService(repo repository.CallbackRepository) CallbackServiceInterface {
+	return &CallbackService{repo: repo}
+}
+
+// SetClientCallback registers the URL receiving the status changes of a client's messages
+func (s *CallbackService) SetClientCallback(ctx context.Context, cb model.ClientCallback) (*model.ClientCallback, error) {
+	if err := cb.Validate(); err != nil {
+		return nil, err
+	}
+	saved, err := s.repo.SetClientCallback(ctx, cb)
+	if err != nil {
+		return nil, fmt.Errorf("set callback of client %q: %w", cb.ClientID, err)
+	}
+	return saved, nil
+}
+
+func (s *CallbackService) GetClientCallback(ctx context.Context, clientID string) (*model.ClientCallback, error) {
+	cb, err := s.repo.GetClientCallback(ctx, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("get callback of client %q: %w", clientID, err)
+	}
+	return cb, nil
+}
+
+func (s *CallbackService) DeleteClientCallback(ctx context.Context, clientID string) error {
+	if err := s.repo.DeleteClientCallback(ctx, clientID); err != nil {
+		return fmt.Errorf("delete callback of client %q: %w", clientID, err)
+	}
+	return nil
+}
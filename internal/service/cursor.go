@@ -0,0 +1,42 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+)
+
+// sentCursor is the opaque pagination token handed out by ListSentMessages.
+// Clients never parse it; the encoding may change as long as old tokens keep decoding.
+type sentCursor struct {
+	SentTime time.Time `json:"t"`
+	ID       int64     `json:"i"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+func (c sentCursor) encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func (c sentCursor) key() repository.SentKey {
+	return repository.SentKey{SentTime: c.SentTime, ID: c.ID}
+}
+
+func decodeSentCursor(token string) (sentCursor, error) {
+	invalid := &model.ValidationError{Field: "cursor", Reason: "malformed pagination cursor"}
+
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return sentCursor{}, invalid
+	}
+
+	var c sentCursor
+	if err := json.Unmarshal(raw, &c); err != nil || c.ID <= 0 {
+		return sentCursor{}, invalid
+	}
+	return c, nil
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+)
+
+type DeliveryServiceInterface interface {
+	RecordReceipt(ctx context.Context, receipt model.DeliveryReceipt) (*model.Message, error)
+}
+
+type DeliveryService struct {
+	repo  repository.MessageRepository
+	cache MessageCache
+	now   func() time.Time
+}
+
+// NewDeliveryService creates a delivery receipt service; a nil cache leaves cached messages untouched
+func NewDeliveryService(repo repository.MessageRepository, cache MessageCache) DeliveryServiceInterface {
+	return &DeliveryService{repo: repo, cache: cache, now: time.Now}
+}
+
+// RecordReceipt applies a gateway's delivery receipt to the message it accepted under receipt.ExternalID and
+// refreshes the cached copy, so lookups by external ID show the delivery state. The cache keeps the delivery state
+// when the sent event of the message is written after it. A receipt without a time is taken as delivered now.
+func (s *DeliveryService) RecordReceipt(ctx context.Context, receipt model.DeliveryReceipt) (*model.Message, error) {
+	if err := receipt.Validate(); err != nil {
+		return nil, err
+	}
+	if receipt.DeliveredAt.IsZero() {
+		receipt.DeliveredAt = s.now()
+	}
+
+	msg, err := s.repo.RecordDelivery(ctx, receipt)
+	if err != nil {
+		return nil, fmt.Errorf("record delivery receipt: %w", err)
+	}
+
+	if s.cache != nil {
+		if err := s.cache.CacheMessage(ctx, *msg); err != nil {
+			log.Printf("failed to cache delivery state of message ID %d: %v", msg.ID, err)
+		}
+	}
+	return msg, nil
+}
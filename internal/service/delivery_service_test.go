@@ -0,0 +1,63 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// MockDeliveryRepository applies receipts to the messages it holds by external ID
+type MockDeliveryRepository struct {
+	MockMessageRepository
+	Messages map[string]model.Message
+	Receipts []model.DeliveryReceipt
+}
+
+func (m *MockDeliveryRepository) RecordDelivery(ctx context.Context, receipt model.DeliveryReceipt) (*model.Message, error) {
+	m.Receipts = append(m.Receipts, receipt)
+	msg, ok := m.Messages[receipt.ExternalID]
+	if !ok {
+		return nil, model.ErrNotFound
+	}
+	msg.Status = receipt.Status
+	msg.DeliveredTime = receipt.DeliveredAt
+	return &msg, nil
+}
+
+func TestDeliveryService_RecordReceipt(t *testing.T) {
+	repo := &MockDeliveryRepository{Messages: map[string]model.Message{
+		"ext-1": {ID: 1, ExternalID: "ext-1", Status: model.StatusSent},
+	}}
+	cache := &MockMessageCache{Entries: map[string]model.Message{
+		"ext-1": {ID: 1, ExternalID: "ext-1", Status: model.StatusSent},
+	}}
+	svc := service.NewDeliveryService(repo, cache)
+	deliveredAt := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	msg, err := svc.RecordReceipt(context.Background(), model.DeliveryReceipt{
+		ExternalID: "ext-1", Status: model.StatusDelivered, DeliveredAt: deliveredAt,
+	})
+	require.NoError(t, err)
+	require.Equal(t, model.StatusDelivered, msg.Status)
+	require.Equal(t, model.StatusDelivered, cache.Entries["ext-1"].Status, "cached copy shows the delivery state")
+	require.Equal(t, deliveredAt, cache.Entries["ext-1"].DeliveredTime)
+
+	// receipts without a time are taken as delivered on arrival
+	_, err = svc.RecordReceipt(context.Background(), model.DeliveryReceipt{ExternalID: "ext-1", Status: model.StatusUndelivered})
+	require.NoError(t, err)
+	require.False(t, repo.Receipts[1].DeliveredAt.IsZero())
+
+	_, err = svc.RecordReceipt(context.Background(), model.DeliveryReceipt{ExternalID: "ext-2", Status: model.StatusDelivered})
+	require.True(t, errors.Is(err, model.ErrNotFound))
+	require.NotContains(t, cache.Entries, "ext-2")
+
+	var vErr *model.ValidationError
+	_, err = svc.RecordReceipt(context.Background(), model.DeliveryReceipt{ExternalID: "ext-1", Status: model.StatusSent})
+	require.True(t, errors.As(err, &vErr))
+	require.Len(t, repo.Receipts, 3, "invalid receipts never reach the repository")
+}
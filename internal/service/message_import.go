@@ -0,0 +1,235 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+)
+
+type ImportFormat string
+
+const (
+	ImportFormatNDJSON ImportFormat = "ndjson"
+	ImportFormatCSV    ImportFormat = "csv"
+)
+
+const (
+	ImportLineAccepted = "accepted"
+	ImportLineRejected = "rejected"
+)
+
+// maxImportLineSize bounds a single NDJSON line, far above what a valid message needs
+const maxImportLineSize = 64 * 1024
+
+// ImportLineResult reports the outcome of a single line of a bulk upload
+type ImportLineResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport summarizes a bulk upload
+type ImportReport struct {
+	Accepted int                `json:"accepted"`
+	Rejected int                `json:"rejected"`
+	Lines    []ImportLineResult `json:"lines"`
+}
+
+func (r *ImportReport) accept(line int) {
+	r.Accepted++
+	r.Lines = append(r.Lines, ImportLineResult{Line: line, Status: ImportLineAccepted})
+}
+
+func (r *ImportReport) reject(line int, err error) {
+	r.Rejected++
+	r.Lines = append(r.Lines, ImportLineResult{Line: line, Status: ImportLineRejected, Error: err.Error()})
+}
+
+// importRecord is the shape of a single uploaded message, shared by all formats.
+// It carries the same optional fields as an enqueue request, except for the idempotency key.
+type importRecord struct {
+	PhoneNumber string                `json:"phone_number"`
+	Content     string                `json:"content"`
+	SendAt      time.Time             `json:"send_at"`
+	ExpiresAt   time.Time             `json:"expires_at"`
+	Priority    model.MessagePriority `json:"priority"`
+	MaxAttempts int                   `json:"max_attempts"`
+	CallbackURL string                `json:"callback_url"`
+}
+
+func (r importRecord) toNewMessage(clientID string) model.NewMessage {
+	return model.NewMessage{
+		PhoneNumber: r.PhoneNumber,
+		Content:     r.Content,
+		ClientID:    clientID,
+		SendAt:      r.SendAt,
+		ExpiresAt:   r.ExpiresAt,
+		Priority:    r.Priority,
+		MaxAttempts: r.MaxAttempts,
+		CallbackURL: r.CallbackURL,
+	}
+}
+
+// lineError marks a problem confined to a single line; the import continues past it
+type lineError struct {
+	err error
+}
+
+func (e *lineError) Error() string { return e.err.Error() }
+func (e *lineError) Unwrap() error { return e.err }
+
+// importReader yields uploaded records one at a time without buffering the whole body
+type importReader interface {
+	// Next returns the next record with its line number, a *lineError for a malformed line,
+	// io.EOF at the end of input, or any other error when the upload cannot be read further.
+	Next() (int, importRecord, error)
+}
+
+func newImportReader(format ImportFormat, body io.Reader) (importReader, error) {
+	switch format {
+	case ImportFormatNDJSON:
+		sc := bufio.NewScanner(body)
+		sc.Buffer(make([]byte, 0, 4096), maxImportLineSize)
+		return &ndjsonReader{scanner: sc}, nil
+	case ImportFormatCSV:
+		return newCSVReader(body)
+	default:
+		return nil, &model.ValidationError{Field: "format", Reason: fmt.Sprintf("unsupported import format %q", format)}
+	}
+}
+
+type ndjsonReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonReader) Next() (int, importRecord, error) {
+	for r.scanner.Scan() {
+		r.line++
+		raw := strings.TrimSpace(r.scanner.Text())
+		if raw == "" {
+			continue
+		}
+
+		var rec importRecord
+		if err := json.Unmarshal([]byte(raw), &rec); err != nil {
+			return r.line, importRecord{}, &lineError{err: fmt.Errorf("invalid JSON: %w", err)}
+		}
+		return r.line, rec, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return r.line + 1, importRecord{}, &model.ValidationError{
+				Field:  "body",
+				Reason: fmt.Sprintf("line %d exceeds %d bytes", r.line+1, maxImportLineSize),
+			}
+		}
+		return r.line, importRecord{}, err
+	}
+	return r.line, importRecord{}, io.EOF
+}
+
+// csvColumns are the header names a CSV upload may use; all but phone_number and content are optional
+var csvColumns = []string{"phone_number", "content", "send_at", "expires_at", "priority", "max_attempts", "callback_url"}
+
+type csvReader struct {
+	reader *csv.Reader
+	// columns maps the known header names present in the upload to their position
+	columns map[string]int
+}
+
+// newCSVReader consumes the header row, which must name the phone_number and content columns
+func newCSVReader(body io.Reader) (*csvReader, error) {
+	reader := csv.NewReader(body)
+	reader.ReuseRecord = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &model.ValidationError{Field: "csv header", Reason: "missing header row"}
+	}
+	if err != nil {
+		return nil, &model.ValidationError{Field: "csv header", Reason: err.Error()}
+	}
+
+	r := &csvReader{reader: reader, columns: make(map[string]int)}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if slices.Contains(csvColumns, name) {
+			r.columns[name] = i
+		}
+	}
+	_, hasPhone := r.columns["phone_number"]
+	_, hasContent := r.columns["content"]
+	if !hasPhone || !hasContent {
+		return nil, &model.ValidationError{Field: "csv header", Reason: "must contain phone_number and content columns"}
+	}
+
+	return r, nil
+}
+
+func (r *csvReader) Next() (int, importRecord, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return parseErr.StartLine, importRecord{}, &lineError{err: err}
+		}
+		return 0, importRecord{}, err
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	rec, err := r.parse(record)
+	if err != nil {
+		return line, importRecord{}, &lineError{err: err}
+	}
+	return line, rec, nil
+}
+
+// parse maps a CSV row onto a record; empty optional cells leave the field unset
+func (r *csvReader) parse(record []string) (importRecord, error) {
+	field := func(name string) string {
+		if i, ok := r.columns[name]; ok {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	rec := importRecord{
+		PhoneNumber: record[r.columns["phone_number"]],
+		Content:     record[r.columns["content"]],
+		CallbackURL: field("callback_url"),
+	}
+	var err error
+	if v := field("send_at"); v != "" {
+		if rec.SendAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return importRecord{}, &model.ValidationError{Field: "send_at", Reason: "must be an RFC3339 time"}
+		}
+	}
+	if v := field("expires_at"); v != "" {
+		if rec.ExpiresAt, err = time.Parse(time.RFC3339, v); err != nil {
+			return importRecord{}, &model.ValidationError{Field: "expires_at", Reason: "must be an RFC3339 time"}
+		}
+	}
+	if v := field("priority"); v != "" {
+		if rec.Priority, err = model.ParsePriority(v); err != nil {
+			return importRecord{}, err
+		}
+	}
+	if v := field("max_attempts"); v != "" {
+		if rec.MaxAttempts, err = strconv.Atoi(v); err != nil {
+			return importRecord{}, &model.ValidationError{Field: "max_attempts", Reason: "must be a number"}
+		}
+	}
+	return rec, nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+)
+
+type MessageServiceInterface interface {
+	Enqueue(ctx context.Context, msg model.NewMessage) (*model.Message, bool, error)
+	Import(ctx context.Context, clientID string, format ImportFormat, body io.Reader) (*ImportReport, error)
+	Replay(ctx context.Context, req model.ReplayRequest) (*ReplayResult, error)
+	Cancel(ctx context.Context, id int64) (*model.Message, error)
+	Edit(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error)
+	CancelMatching(ctx context.Context, filter model.MessageFilter) (int64, error)
+}
+
+type MessageService struct {
+	repo           repository.MessageRepository
+	idempotencyTTL time.Duration
+}
+
+func NewMessageService(repo repository.MessageRepository, idempotencyTTL time.Duration) MessageServiceInterface {
+	return &MessageService{repo: repo, idempotencyTTL: idempotencyTTL}
+}
+
+// Enqueue stores a new pending message to be picked up by the relayer.
+// When the message carries an idempotency key that was already used by the same client, the originally
+// created message is returned and the second return value reports the replay.
+func (s *MessageService) Enqueue(ctx context.Context, msg model.NewMessage) (*model.Message, bool, error) {
+	if msg.IdempotencyKey == "" {
+		created, err := s.repo.CreateMessage(ctx, msg)
+		if err != nil {
+			return nil, false, fmt.Errorf("enqueue message: %w", err)
+		}
+		return created, false, nil
+	}
+
+	created, replayed, err := s.repo.CreateMessageIdempotent(ctx, msg, s.idempotencyTTL)
+	if err != nil {
+		return nil, false, fmt.Errorf("enqueue message: %w", err)
+	}
+	return created, replayed, nil
+}
+
+// Import streams an NDJSON or CSV upload into the messages table.
+// Every imported message belongs to clientID. Lines failing to parse or validate are reported as rejected, while the
+// remaining lines are copied in one transaction.
+func (s *MessageService) Import(ctx context.Context, clientID string, format ImportFormat, body io.Reader) (*ImportReport, error) {
+	src, err := newImportReader(format, body)
+	if err != nil {
+		return nil, fmt.Errorf("import messages: %w", err)
+	}
+
+	report := &ImportReport{Lines: []ImportLineResult{}}
+	msgs := func(yield func(model.NewMessage, error) bool) {
+		for {
+			line, rec, err := src.Next()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+
+			var lineErr *lineError
+			if errors.As(err, &lineErr) {
+				report.reject(line, err)
+				continue
+			}
+			if err != nil {
+				yield(model.NewMessage{}, err)
+				return
+			}
+
+			msg := rec.toNewMessage(clientID)
+			if err := msg.Validate(); err != nil {
+				report.reject(line, err)
+				continue
+			}
+			if !yield(msg, nil) {
+				return
+			}
+			report.accept(line)
+		}
+	}
+
+	if _, err := s.repo.CopyMessages(ctx, msgs); err != nil {
+		return nil, fmt.Errorf("import messages: %w", err)
+	}
+
+	return report, nil
+}
+
+// ReplayResult lists the messages moved back to pending by a replay
+type ReplayResult struct {
+	Replayed int             `json:"replayed"`
+	Messages []model.Message `json:"messages"`
+}
+
+// Replay moves failed messages back to pending so the relayer retries them from a fresh attempt count.
+// Messages that are not failed anymore when the replay runs are left untouched.
+func (s *MessageService) Replay(ctx context.Context, req model.ReplayRequest) (*ReplayResult, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	msgs, err := s.repo.ReplayMessages(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("replay messages: %w", err)
+	}
+
+	if msgs == nil {
+		msgs = []model.Message{}
+	}
+	return &ReplayResult{Replayed: len(msgs), Messages: msgs}, nil
+}
+
+// Cancel withdraws a pending message before the relayer picks it up
+func (s *MessageService) Cancel(ctx context.Context, id int64) (*model.Message, error) {
+	msg, err := s.repo.CancelMessage(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("cancel message %d: %w", id, err)
+	}
+	return msg, nil
+}
+
+// Edit changes the recipient or content of a pending message before the relayer picks it up
+func (s *MessageService) Edit(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error) {
+	if err := edit.Validate(); err != nil {
+		return nil, err
+	}
+
+	msg, err := s.repo.EditMessage(ctx, id, edit)
+	if err != nil {
+		return nil, fmt.Errorf("edit message %d: %w", id, err)
+	}
+	return msg, nil
+}
+
+// CancelMatching cancels all pending messages matching a non-empty filter and returns how many were cancelled
+func (s *MessageService) CancelMatching(ctx context.Context, filter model.MessageFilter) (int64, error) {
+	if filter.IsEmpty() {
+		return 0, &model.ValidationError{Field: "filter", Reason: "must not be empty"}
+	}
+	if len(filter.Statuses) > 0 {
+		return 0, &model.ValidationError{Field: "status", Reason: "only pending messages can be cancelled"}
+	}
+	if len(filter.FailureReasons) > 0 {
+		return 0, &model.ValidationError{Field: "failure_reason", Reason: "only pending messages can be cancelled"}
+	}
+	if err := filter.Validate(); err != nil {
+		return 0, err
+	}
+
+	n, err := s.repo.CancelMessages(ctx, filter)
+	if err != nil {
+		return 0, fmt.Errorf("cancel messages: %w", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,338 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+type MockWriteRepository struct {
+	MockMessageRepository
+
+	CreateMessageFunc           func(ctx context.Context, msg model.NewMessage) (*model.Message, error)
+	CreateMessageIdempotentFunc func(ctx context.Context, msg model.NewMessage, keyTTL time.Duration) (*model.Message, bool, error)
+	ReplayMessagesFunc          func(ctx context.Context, req model.ReplayRequest) ([]model.Message, error)
+	EditMessageFunc             func(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error)
+	CancelMessagesFunc          func(ctx context.Context, filter model.MessageFilter) (int64, error)
+	Copied                      []model.NewMessage
+}
+
+func (m *MockWriteRepository) EditMessage(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error) {
+	return m.EditMessageFunc(ctx, id, edit)
+}
+
+func (m *MockWriteRepository) CancelMessages(ctx context.Context, filter model.MessageFilter) (int64, error) {
+	return m.CancelMessagesFunc(ctx, filter)
+}
+
+func (m *MockWriteRepository) ReplayMessages(ctx context.Context, req model.ReplayRequest) ([]model.Message, error) {
+	return m.ReplayMessagesFunc(ctx, req)
+}
+
+func (m *MockWriteRepository) CreateMessageIdempotent(ctx context.Context, msg model.NewMessage, keyTTL time.Duration) (*model.Message, bool, error) {
+	return m.CreateMessageIdempotentFunc(ctx, msg, keyTTL)
+}
+
+func (m *MockWriteRepository) CreateMessage(ctx context.Context, msg model.NewMessage) (*model.Message, error) {
+	return m.CreateMessageFunc(ctx, msg)
+}
+
+func (m *MockWriteRepository) CopyMessages(ctx context.Context, msgs iter.Seq2[model.NewMessage, error]) (int64, error) {
+	for msg, err := range msgs {
+		if err != nil {
+			return 0, err
+		}
+		m.Copied = append(m.Copied, msg)
+	}
+	return int64(len(m.Copied)), nil
+}
+
+func TestMessageService_Enqueue(t *testing.T) {
+	repo := &MockWriteRepository{
+		CreateMessageFunc: func(ctx context.Context, msg model.NewMessage) (*model.Message, error) {
+			return &model.Message{ID: 1, PhoneNumber: msg.PhoneNumber, Content: msg.Content}, nil
+		},
+	}
+
+	svc := service.NewMessageService(repo, time.Hour)
+	msg, replayed, err := svc.Enqueue(context.Background(), model.NewMessage{PhoneNumber: "+90555", Content: "hi"})
+	require.NoError(t, err)
+	require.False(t, replayed)
+	require.Equal(t, int64(1), msg.ID)
+	require.Equal(t, "+90555", msg.PhoneNumber)
+}
+
+func TestMessageService_Enqueue_IdempotencyKey(t *testing.T) {
+	var gotTTL time.Duration
+	repo := &MockWriteRepository{
+		CreateMessageIdempotentFunc: func(ctx context.Context, msg model.NewMessage, keyTTL time.Duration) (*model.Message, bool, error) {
+			gotTTL = keyTTL
+			return &model.Message{ID: 5, IdempotencyKey: msg.IdempotencyKey}, true, nil
+		},
+	}
+
+	svc := service.NewMessageService(repo, 2*time.Hour)
+	msg, replayed, err := svc.Enqueue(context.Background(), model.NewMessage{
+		PhoneNumber:    "+90555",
+		Content:        "hi",
+		ClientID:       "billing",
+		IdempotencyKey: "abc",
+	})
+	require.NoError(t, err)
+	require.True(t, replayed)
+	require.Equal(t, int64(5), msg.ID)
+	require.Equal(t, 2*time.Hour, gotTTL)
+}
+
+func TestMessageService_Enqueue_ValidationError(t *testing.T) {
+	repo := &MockWriteRepository{
+		CreateMessageFunc: func(ctx context.Context, msg model.NewMessage) (*model.Message, error) {
+			return nil, &model.ValidationError{Field: "phone_number", Reason: "must not be empty"}
+		},
+	}
+
+	svc := service.NewMessageService(repo, time.Hour)
+	_, _, err := svc.Enqueue(context.Background(), model.NewMessage{})
+
+	var vErr *model.ValidationError
+	require.True(t, errors.As(err, &vErr), "validation error should be preserved through wrapping")
+	require.Equal(t, "phone_number", vErr.Field)
+}
+
+func TestMessageService_Import(t *testing.T) {
+	tests := []struct {
+		name         string
+		format       service.ImportFormat
+		body         string
+		wantCopied   int
+		wantRejected []int
+	}{
+		{
+			name:   "ndjson",
+			format: service.ImportFormatNDJSON,
+			body: `{"phone_number":"+111","content":"first"}
+
+{"phone_number":"abc","content":"bad phone"}
+{not json}
+{"phone_number":"+222","content":"second"}
+`,
+			wantCopied:   2,
+			wantRejected: []int{3, 4},
+		},
+		{
+			name:   "csv with reordered columns",
+			format: service.ImportFormatCSV,
+			body: `content,phone_number
+first,+111
+,+333
+"second, with comma",+222
+`,
+			wantCopied:   2,
+			wantRejected: []int{3},
+		},
+		{
+			name:   "csv with wrong field count",
+			format: service.ImportFormatCSV,
+			body: `phone_number,content
++111,first
++222
+`,
+			wantCopied:   1,
+			wantRejected: []int{3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockWriteRepository{}
+			svc := service.NewMessageService(repo, time.Hour)
+
+			report, err := svc.Import(context.Background(), "billing", tt.format, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			require.Len(t, repo.Copied, tt.wantCopied)
+			for _, msg := range repo.Copied {
+				require.Equal(t, "billing", msg.ClientID)
+			}
+			require.Equal(t, tt.wantCopied, report.Accepted)
+			require.Equal(t, len(tt.wantRejected), report.Rejected)
+
+			var rejected []int
+			for _, l := range report.Lines {
+				if l.Status == service.ImportLineRejected {
+					require.NotEmpty(t, l.Error)
+					rejected = append(rejected, l.Line)
+				}
+			}
+			require.Equal(t, tt.wantRejected, rejected)
+		})
+	}
+}
+
+func TestMessageService_Import_OptionalFields(t *testing.T) {
+	sendAt := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	want := model.NewMessage{
+		PhoneNumber: "+111",
+		Content:     "first",
+		ClientID:    "billing",
+		SendAt:      sendAt,
+		ExpiresAt:   sendAt.Add(time.Hour),
+		Priority:    model.PriorityHigh,
+		MaxAttempts: 3,
+		CallbackURL: "https://example.com/hook",
+	}
+
+	tests := []struct {
+		name         string
+		format       service.ImportFormat
+		body         string
+		wantRejected []int
+	}{
+		{
+			name:   "ndjson",
+			format: service.ImportFormatNDJSON,
+			body: `{"phone_number":"+111","content":"first","send_at":"2030-01-02T03:04:05Z","expires_at":"2030-01-02T04:04:05Z","priority":"high","max_attempts":3,"callback_url":"https://example.com/hook"}
+{"phone_number":"+111","content":"bad","priority":"urgent"}
+`,
+			wantRejected: []int{2},
+		},
+		{
+			name:   "csv",
+			format: service.ImportFormatCSV,
+			body: `phone_number,content,send_at,expires_at,priority,max_attempts,callback_url
++111,first,2030-01-02T03:04:05Z,2030-01-02T04:04:05Z,high,3,https://example.com/hook
++111,bad,tomorrow,,,,
++111,bad,,,,many,
+`,
+			wantRejected: []int{3, 4},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &MockWriteRepository{}
+			svc := service.NewMessageService(repo, time.Hour)
+
+			report, err := svc.Import(context.Background(), "billing", tt.format, strings.NewReader(tt.body))
+			require.NoError(t, err)
+			require.Equal(t, []model.NewMessage{want}, repo.Copied)
+
+			var rejected []int
+			for _, l := range report.Lines {
+				if l.Status == service.ImportLineRejected {
+					rejected = append(rejected, l.Line)
+				}
+			}
+			require.Equal(t, tt.wantRejected, rejected)
+		})
+	}
+}
+
+func TestMessageService_Import_MissingCSVColumns(t *testing.T) {
+	svc := service.NewMessageService(&MockWriteRepository{}, time.Hour)
+
+	_, err := svc.Import(context.Background(), "", service.ImportFormatCSV, strings.NewReader("phone,text\n+111,hi\n"))
+
+	var vErr *model.ValidationError
+	require.True(t, errors.As(err, &vErr))
+	require.Equal(t, "csv header", vErr.Field)
+}
+
+func TestMessageService_Replay(t *testing.T) {
+	var got model.ReplayRequest
+	repo := &MockWriteRepository{
+		ReplayMessagesFunc: func(ctx context.Context, req model.ReplayRequest) ([]model.Message, error) {
+			got = req
+			return []model.Message{{ID: 4, Status: model.StatusPending}}, nil
+		},
+	}
+
+	svc := service.NewMessageService(repo, time.Hour)
+	req := model.ReplayRequest{IDs: []int64{4, 5}, Content: "fixed", ReplayedBy: "ops"}
+	result, err := svc.Replay(context.Background(), req)
+	require.NoError(t, err)
+	require.Equal(t, 1, result.Replayed)
+	require.Equal(t, int64(4), result.Messages[0].ID)
+	require.Equal(t, req.IDs, got.IDs)
+	require.Equal(t, "ops", got.ReplayedBy)
+}
+
+func TestMessageService_Replay_Invalid(t *testing.T) {
+	repo := &MockWriteRepository{
+		ReplayMessagesFunc: func(ctx context.Context, req model.ReplayRequest) ([]model.Message, error) {
+			t.Fatal("repository must not be called for an invalid request")
+			return nil, nil
+		},
+	}
+
+	svc := service.NewMessageService(repo, time.Hour)
+	_, err := svc.Replay(context.Background(), model.ReplayRequest{ReplayedBy: "ops"})
+
+	var vErr *model.ValidationError
+	require.True(t, errors.As(err, &vErr))
+	require.Equal(t, "ids", vErr.Field)
+}
+
+func TestMessageService_Edit(t *testing.T) {
+	repo := &MockWriteRepository{
+		EditMessageFunc: func(ctx context.Context, id int64, edit model.MessageEdit) (*model.Message, error) {
+			if id == 2 {
+				return nil, model.ErrNotPending
+			}
+			return &model.Message{ID: id, Content: edit.Content, Status: model.StatusPending}, nil
+		},
+	}
+	svc := service.NewMessageService(repo, time.Hour)
+
+	msg, err := svc.Edit(context.Background(), 1, model.MessageEdit{Content: "updated"})
+	require.NoError(t, err)
+	require.Equal(t, "updated", msg.Content)
+
+	_, err = svc.Edit(context.Background(), 2, model.MessageEdit{Content: "updated"})
+	require.ErrorIs(t, err, model.ErrNotPending)
+
+	var vErr *model.ValidationError
+	_, err = svc.Edit(context.Background(), 1, model.MessageEdit{})
+	require.True(t, errors.As(err, &vErr))
+}
+
+func TestMessageService_CancelMatching(t *testing.T) {
+	var got model.MessageFilter
+	repo := &MockWriteRepository{
+		CancelMessagesFunc: func(ctx context.Context, filter model.MessageFilter) (int64, error) {
+			got = filter
+			return 3, nil
+		},
+	}
+	svc := service.NewMessageService(repo, time.Hour)
+
+	n, err := svc.CancelMatching(context.Background(), model.MessageFilter{PhoneNumber: "+90555"})
+	require.NoError(t, err)
+	require.Equal(t, int64(3), n)
+	require.Equal(t, "+90555", got.PhoneNumber)
+
+	tests := []struct {
+		name      string
+		filter    model.MessageFilter
+		wantField string
+	}{
+		{"empty filter", model.MessageFilter{}, "filter"},
+		{"status filter", model.MessageFilter{Statuses: []model.MessageStatus{model.StatusSent}}, "status"},
+		{"failure reason filter", model.MessageFilter{FailureReasons: []model.FailureReason{model.FailureMaxAttempts}}, "failure_reason"},
+		{"inverted ids", model.MessageFilter{MinID: 5, MaxID: 1}, "min_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.CancelMatching(context.Background(), tt.filter)
+			var vErr *model.ValidationError
+			require.True(t, errors.As(err, &vErr))
+			require.Equal(t, tt.wantField, vErr.Field)
+		})
+	}
+}
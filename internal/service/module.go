@@ -1,10 +1,15 @@
 package service
 
 import (
+	"fmt"
+	"slices"
+
 	"go.uber.org/fx"
 
+	"github.com/lazerion/outbox-relayer/internal/callback"
 	"github.com/lazerion/outbox-relayer/internal/config"
 	"github.com/lazerion/outbox-relayer/internal/gateway"
+	"github.com/lazerion/outbox-relayer/internal/model"
 	"github.com/lazerion/outbox-relayer/internal/repository"
 	"github.com/lazerion/outbox-relayer/internal/schedule"
 )
@@ -14,7 +19,46 @@ func NewRelayerServiceProvider(
 	sender gateway.Sender,
 	cfg *config.Config,
 	cacheCh chan SentMessageEvent,
-) schedule.Job {
+) (schedule.Job, error) {
+	weights, err := parsePriorityWeights(cfg.Relayer.PriorityWeights)
+	if err != nil {
+		return nil, err
+	}
+
+	classAttempts, err := parseMaxAttemptsByError(cfg.Relayer.MaxAttemptsByError)
+	if err != nil {
+		return nil, err
+	}
+
+	backoff := BackoffPolicy{
+		Base:       cfg.Relayer.Backoff.Base,
+		Multiplier: cfg.Relayer.Backoff.Multiplier,
+		MaxDelay:   cfg.Relayer.Backoff.MaxDelay,
+		Jitter:     cfg.Relayer.Backoff.Jitter,
+	}
+	if err := backoff.Validate(); err != nil {
+		return nil, fmt.Errorf("relayer.backoff: %w", err)
+	}
+
+	opts := []RelayerOption{
+		WithDefaultTTL(cfg.Relayer.DefaultTTL),
+		WithPriorityWeights(weights),
+		WithBackoff(backoff),
+		WithMaxAttemptsByError(classAttempts),
+		WithConcurrency(cfg.Relayer.Concurrency),
+	}
+	if cfg.Relayer.Drain {
+		opts = append(opts, WithDrain(cfg.Relayer.MaxBatchesPerRun))
+	}
+	lease := cfg.Relayer.Lease
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	if need := MinLease(cfg.Relayer.Batch, cfg.Relayer.Concurrency, cfg.Relayer.Timeout); lease < need {
+		return nil, fmt.Errorf("relayer.lease of %s does not cover sending a batch, it must be at least %s", lease, need)
+	}
+	opts = append(opts, WithLease(lease))
+
 	return NewRelayerService(
 		repo,
 		sender,
@@ -22,13 +66,137 @@ func NewRelayerServiceProvider(
 		cfg.Relayer.Timeout,
 		cfg.Relayer.MaxAttempts,
 		cacheCh,
+		opts...,
+	), nil
+}
+
+// NewReconcilerServiceProvider builds the reconciler job. It shares the relayer's lease and timeout, and claims
+// batches of relayer.batch unless reconciler.batch is set.
+func NewReconcilerServiceProvider(
+	repo repository.MessageRepository,
+	checker gateway.StatusChecker,
+	cfg *config.Config,
+	cacheCh chan SentMessageEvent,
+) schedule.Job {
+	batch := cfg.Reconciler.Batch
+	if batch <= 0 {
+		batch = cfg.Relayer.Batch
+	}
+
+	var opts []ReconcilerOption
+	if cfg.Relayer.Lease > 0 {
+		opts = append(opts, WithReconcilerLease(cfg.Relayer.Lease))
+	}
+	if cfg.Reconciler.MaxChecks > 0 {
+		opts = append(opts, WithMaxStatusChecks(cfg.Reconciler.MaxChecks))
+	}
+
+	return NewReconcilerService(
+		repo,
+		checker,
+		batch,
+		cfg.Relayer.Timeout,
+		cacheCh,
+		opts...,
 	)
 }
 
+// parseMaxAttemptsByError converts the configured limits keyed by error class name
+func parseMaxAttemptsByError(raw map[string]int) (map[gateway.ErrorClass]int, error) {
+	limits := make(map[gateway.ErrorClass]int, len(raw))
+	for name, n := range raw {
+		class := gateway.ErrorClass(name)
+		if !slices.Contains(gateway.ErrorClasses, class) {
+			return nil, fmt.Errorf("relayer.maxAttemptsByError: unknown error class %q", name)
+		}
+		if n < 1 {
+			return nil, fmt.Errorf("relayer.maxAttemptsByError: limit of %s must be at least 1", name)
+		}
+		limits[class] = n
+	}
+	return limits, nil
+}
+
+// parsePriorityWeights converts the configured weights keyed by priority name, falling back to DefaultPriorityWeights
+func parsePriorityWeights(raw map[string]int) (map[model.MessagePriority]int, error) {
+	if len(raw) == 0 {
+		return DefaultPriorityWeights, nil
+	}
+
+	weights := make(map[model.MessagePriority]int, len(raw))
+	for name, w := range raw {
+		p, err := model.ParsePriority(name)
+		if err != nil {
+			return nil, fmt.Errorf("relayer.priorityWeights: %w", err)
+		}
+		if w < 0 {
+			return nil, fmt.Errorf("relayer.priorityWeights: weight of %s must not be negative", p)
+		}
+		weights[p] = w
+	}
+	return weights, nil
+}
+
+// NewCallbackDispatcherProvider builds the callback dispatcher job from the callbacks configuration
+func NewCallbackDispatcherProvider(
+	repo repository.CallbackRepository,
+	notifier callback.Notifier,
+	cfg *config.Config,
+) (schedule.Job, error) {
+	backoff := BackoffPolicy{
+		Base:       cfg.Callbacks.Backoff.Base,
+		Multiplier: cfg.Callbacks.Backoff.Multiplier,
+		MaxDelay:   cfg.Callbacks.Backoff.MaxDelay,
+		Jitter:     cfg.Callbacks.Backoff.Jitter,
+	}
+	if err := backoff.Validate(); err != nil {
+		return nil, fmt.Errorf("callbacks.backoff: %w", err)
+	}
+
+	opts := []DispatcherOption{
+		WithDispatcherBackoff(backoff),
+		WithDispatcherConcurrency(cfg.Callbacks.Concurrency),
+	}
+	if cfg.Callbacks.Lease > 0 {
+		if cfg.Callbacks.Lease <= cfg.Callbacks.Timeout {
+			return nil, fmt.Errorf("callbacks.lease must be longer than callbacks.timeout")
+		}
+		opts = append(opts, WithDispatcherLease(cfg.Callbacks.Lease))
+	}
+
+	return NewCallbackDispatcher(
+		repo,
+		notifier,
+		cfg.Callbacks.Batch,
+		cfg.Callbacks.Timeout,
+		cfg.Callbacks.MaxAttempts,
+		opts...,
+	), nil
+}
+
 func NewQueryServiceProvider(
 	repo repository.QueryRepository,
+	cache MessageCache,
 ) QueryServiceInterface {
-	return NewQueryService(repo)
+	return NewQueryService(repo, cache)
+}
+
+func NewDeliveryServiceProvider(
+	repo repository.MessageRepository,
+	cache MessageCache,
+) DeliveryServiceInterface {
+	return NewDeliveryService(repo, cache)
+}
+
+func NewCallbackServiceProvider(repo repository.CallbackRepository) CallbackServiceInterface {
+	return NewCallbackService(repo)
+}
+
+func NewMessageServiceProvider(
+	repo repository.MessageRepository,
+	cfg *config.Config,
+) MessageServiceInterface {
+	return NewMessageService(repo, cfg.Idempotency.KeyTTL)
 }
 
 var Module = fx.Module(
@@ -39,5 +207,10 @@ var Module = fx.Module(
 	fx.Provide(
 		NewRelayerServiceProvider,
 		NewQueryServiceProvider,
+		NewMessageServiceProvider,
+		NewDeliveryServiceProvider,
+		NewCallbackServiceProvider,
+		fx.Annotate(NewReconcilerServiceProvider, fx.ResultTags(`name:"reconciler"`)),
+		fx.Annotate(NewCallbackDispatcherProvider, fx.ResultTags(`name:"callbacks"`)),
 	),
 )
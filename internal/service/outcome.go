@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Outcome is what the relayer did with a claimed message
+type Outcome string
+
+const (
+	OutcomeSent    Outcome = "sent"
+	OutcomeRetried Outcome = "retried"
+	OutcomeFailed  Outcome = "failed"
+	OutcomeExpired Outcome = "expired"
+	// OutcomeUnknown is a send the gateway may have accepted, left to the reconciler
+	OutcomeUnknown Outcome = "unknown"
+)
+
+// MessageOutcome reports how a claimed message was handled. Err is set when the outcome could not be recorded;
+// the message then stays leased and is claimed again once the lease runs out.
+type MessageOutcome struct {
+	MessageID int64
+	Outcome   Outcome
+	Err       error
+}
+
+// summarize counts the outcomes of a batch for logging, e.g. "3 sent, 1 retried, 1 not recorded"
+func summarize(outcomes []MessageOutcome) string {
+	counts := make(map[Outcome]int)
+	unrecorded := 0
+	for _, o := range outcomes {
+		if o.Err != nil {
+			unrecorded++
+			continue
+		}
+		counts[o.Outcome]++
+	}
+
+	var parts []string
+	for _, o := range []Outcome{OutcomeSent, OutcomeRetried, OutcomeUnknown, OutcomeFailed, OutcomeExpired} {
+		if counts[o] > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", counts[o], o))
+		}
+	}
+	if unrecorded > 0 {
+		parts = append(parts, fmt.Sprintf("%d not recorded", unrecorded))
+	}
+	return strings.Join(parts, ", ")
+}
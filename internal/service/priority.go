@@ -0,0 +1,53 @@
+package service
+
+import "github.com/lazerion/outbox-relayer/internal/model"
+
+// DefaultPriorityWeights gives high priority messages 60% of every batch, normal 30% and low 10%
+var DefaultPriorityWeights = map[model.MessagePriority]int{
+	model.PriorityHigh:   6,
+	model.PriorityNormal: 3,
+	model.PriorityLow:    1,
+}
+
+// priorityQuotas splits batches between priority levels by weight using smooth weighted round-robin.
+// The credit carried over between batches guarantees every level with a positive weight gets a slot
+// eventually, even when a single batch is smaller than the number of levels.
+// It is not safe for concurrent use; the scheduler never overlaps runs.
+type priorityQuotas struct {
+	weights map[model.MessagePriority]int
+	credit  map[model.MessagePriority]int
+}
+
+func newPriorityQuotas(weights map[model.MessagePriority]int) *priorityQuotas {
+	return &priorityQuotas{weights: weights, credit: make(map[model.MessagePriority]int)}
+}
+
+// next returns how many of the batch slots each priority level may claim first
+func (q *priorityQuotas) next(batch int) map[model.MessagePriority]int {
+	total := 0
+	for _, w := range q.weights {
+		total += max(w, 0)
+	}
+
+	quotas := make(map[model.MessagePriority]int, len(q.weights))
+	if total == 0 {
+		return quotas
+	}
+
+	for range batch {
+		var picked model.MessagePriority
+		for _, p := range model.Priorities {
+			w := max(q.weights[p], 0)
+			if w == 0 {
+				continue
+			}
+			q.credit[p] += w
+			if picked == 0 || q.credit[p] > q.credit[picked] {
+				picked = p
+			}
+		}
+		q.credit[picked] -= total
+		quotas[picked]++
+	}
+	return quotas
+}
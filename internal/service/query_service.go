@@ -2,7 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"math"
 	"time"
 
 	"github.com/lazerion/outbox-relayer/internal/model"
@@ -10,26 +13,75 @@ import (
 )
 
 type QueryServiceInterface interface {
-	ListSentMessages(ctx context.Context, after time.Time, limit int) (*SentMessagesResponse, error)
+	ListSentMessages(ctx context.Context, q SentMessagesQuery) (*SentMessagesResponse, error)
+	GetMessage(ctx context.Context, id int64) (*model.Message, error)
+	GetMessageByExternalID(ctx context.Context, externalID string) (*model.Message, error)
+	SearchMessages(ctx context.Context, filter model.MessageFilter, afterID int64, limit int) (*MessagesPage, error)
+	ListReplays(ctx context.Context, messageID int64) ([]model.MessageReplay, error)
+	ListAttempts(ctx context.Context, messageID int64) ([]model.MessageAttempt, error)
+	Stats(ctx context.Context, filter model.MessageFilter) (*MessageStats, error)
+}
+
+// ErrCacheMiss is returned by a MessageCache that holds no entry for the key
+var ErrCacheMiss = errors.New("cache miss")
+
+// MessageCache is the read-through cache for messages keyed by their gateway assigned ID
+type MessageCache interface {
+	GetMessage(ctx context.Context, externalID string) (*model.Message, error)
+	// CacheMessage must not replace an entry with one in a status the cached message cannot move to
+	CacheMessage(ctx context.Context, msg model.Message) error
 }
 
 type QueryService struct {
-	repo repository.QueryRepository
+	repo  repository.QueryRepository
+	cache MessageCache
+}
+
+// NewQueryService creates a query service; a nil cache disables read-through caching
+func NewQueryService(repo repository.QueryRepository, cache MessageCache) QueryServiceInterface {
+	return &QueryService{repo: repo, cache: cache}
 }
 
-func NewQueryService(repo repository.QueryRepository) QueryServiceInterface {
-	return &QueryService{repo: repo}
+// SentMessagesQuery selects a page of sent messages.
+// Cursor takes a token returned in a previous response; After is the legacy timestamp cursor.
+type SentMessagesQuery struct {
+	Cursor string
+	After  time.Time
+	Limit  int
 }
 
 // SentMessagesResponse Cursor-based pagination response
 type SentMessagesResponse struct {
 	Messages   []model.Message `json:"messages"`
-	NextCursor *time.Time      `json:"next_cursor,omitempty"`
+	NextCursor *string         `json:"next_cursor,omitempty"`
+	PrevCursor *string         `json:"prev_cursor,omitempty"`
+}
+
+// MessagesPage is a page of messages paginated by ID
+type MessagesPage struct {
+	Messages    []model.Message `json:"messages"`
+	NextAfterID *int64          `json:"next_after_id,omitempty"`
 }
 
-// ListSentMessages retrieves a page of sent messages after the given cursor
-func (s *QueryService) ListSentMessages(ctx context.Context, after time.Time, limit int) (*SentMessagesResponse, error) {
-	sentMessages, err := s.repo.ListSentMessages(ctx, after, limit)
+// ListSentMessages retrieves a page of sent messages ordered by (sent_time, id).
+// Without a cursor the page starts after q.After, or at the oldest sent message.
+func (s *QueryService) ListSentMessages(ctx context.Context, q SentMessagesQuery) (*SentMessagesResponse, error) {
+	var cur sentCursor
+	switch {
+	case q.Cursor != "" && !q.After.IsZero():
+		return nil, &model.ValidationError{Field: "cursor", Reason: "cannot be combined with 'after'"}
+	case q.Cursor != "":
+		c, err := decodeSentCursor(q.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		cur = c
+	case !q.After.IsZero():
+		// strictly after the timestamp, regardless of ID
+		cur = sentCursor{SentTime: q.After.UTC(), ID: math.MaxInt64}
+	}
+
+	sentMessages, err := s.repo.ListSentMessages(ctx, cur.key(), cur.Backward, q.Limit)
 	if err != nil {
 		return nil, fmt.Errorf("fetch sent messages: %w", err)
 	}
@@ -38,14 +90,147 @@ func (s *QueryService) ListSentMessages(ctx context.Context, after time.Time, li
 		sentMessages = []model.Message{}
 	}
 
-	var nextCursor *time.Time
-	if len(sentMessages) == limit {
-		ts := sentMessages[len(sentMessages)-1].SentTime
-		nextCursor = &ts
+	resp := &SentMessagesResponse{Messages: sentMessages}
+	if len(sentMessages) == 0 {
+		return resp, nil
+	}
+
+	first, last := sentMessages[0], sentMessages[len(sentMessages)-1]
+	full := len(sentMessages) == q.Limit
+	fromCursor := q.Cursor != "" || !q.After.IsZero()
+
+	// a full page may continue in the direction of travel; the way back is known to exist whenever a cursor was used
+	if (!cur.Backward && full) || (cur.Backward && fromCursor) {
+		next := sentCursor{SentTime: last.SentTime, ID: last.ID}.encode()
+		resp.NextCursor = &next
+	}
+	if (cur.Backward && full) || (!cur.Backward && fromCursor) {
+		prev := sentCursor{SentTime: first.SentTime, ID: first.ID, Backward: true}.encode()
+		resp.PrevCursor = &prev
+	}
+
+	return resp, nil
+}
+
+// GetMessage retrieves a single message by its ID
+func (s *QueryService) GetMessage(ctx context.Context, id int64) (*model.Message, error) {
+	msg, err := s.repo.GetMessage(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch message %d: %w", id, err)
+	}
+	return msg, nil
+}
+
+// GetMessageByExternalID reads through the cache and falls back to the database on a miss
+func (s *QueryService) GetMessageByExternalID(ctx context.Context, externalID string) (*model.Message, error) {
+	if s.cache != nil {
+		msg, err := s.cache.GetMessage(ctx, externalID)
+		if err == nil {
+			return msg, nil
+		}
+		if !errors.Is(err, ErrCacheMiss) {
+			log.Printf("cache lookup failed for external ID %s: %v", externalID, err)
+		}
+	}
+
+	msg, err := s.repo.GetMessageByExternalID(ctx, externalID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch message by external ID %s: %w", externalID, err)
+	}
+
+	if s.cache != nil {
+		if err := s.cache.CacheMessage(ctx, *msg); err != nil {
+			log.Printf("failed to cache message %s: %v", externalID, err)
+		}
+	}
+
+	return msg, nil
+}
+
+// SearchMessages retrieves a page of messages matching the filter with IDs greater than afterID
+func (s *QueryService) SearchMessages(ctx context.Context, filter model.MessageFilter, afterID int64, limit int) (*MessagesPage, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	msgs, err := s.repo.SearchMessages(ctx, filter, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search messages: %w", err)
+	}
+
+	if msgs == nil {
+		msgs = []model.Message{}
+	}
+
+	var next *int64
+	if len(msgs) == limit {
+		id := msgs[len(msgs)-1].ID
+		next = &id
 	}
 
-	return &SentMessagesResponse{
-		Messages:   sentMessages,
-		NextCursor: nextCursor,
+	return &MessagesPage{
+		Messages:    msgs,
+		NextAfterID: next,
 	}, nil
 }
+
+// ListReplays returns the replay history of a message, or model.ErrNotFound when the message does not exist
+func (s *QueryService) ListReplays(ctx context.Context, messageID int64) ([]model.MessageReplay, error) {
+	replays, err := s.repo.ListReplays(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch replays of message %d: %w", messageID, err)
+	}
+
+	if len(replays) == 0 {
+		// tell an unknown message apart from one that was never replayed
+		if _, err := s.repo.GetMessage(ctx, messageID); err != nil {
+			return nil, fmt.Errorf("fetch message %d: %w", messageID, err)
+		}
+		return []model.MessageReplay{}, nil
+	}
+	return replays, nil
+}
+
+// ListAttempts returns the send attempts of a message, or model.ErrNotFound when the message does not exist
+func (s *QueryService) ListAttempts(ctx context.Context, messageID int64) ([]model.MessageAttempt, error) {
+	attempts, err := s.repo.ListAttempts(ctx, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("fetch attempts of message %d: %w", messageID, err)
+	}
+
+	if len(attempts) == 0 {
+		// tell an unknown message apart from one that was never sent
+		if _, err := s.repo.GetMessage(ctx, messageID); err != nil {
+			return nil, fmt.Errorf("fetch message %d: %w", messageID, err)
+		}
+		return []model.MessageAttempt{}, nil
+	}
+	return attempts, nil
+}
+
+// MessageStats counts messages per status
+type MessageStats struct {
+	Total    int64                         `json:"total"`
+	ByStatus map[model.MessageStatus]int64 `json:"by_status"`
+}
+
+// Stats counts the messages matching the filter per status, e.g. to see how many expired in a time range
+func (s *QueryService) Stats(ctx context.Context, filter model.MessageFilter) (*MessageStats, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	counts, err := s.repo.CountByStatus(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("count messages: %w", err)
+	}
+
+	stats := &MessageStats{ByStatus: counts}
+	if stats.ByStatus == nil {
+		stats.ByStatus = map[model.MessageStatus]int64{}
+	}
+	for _, n := range stats.ByStatus {
+		stats.Total += n
+	}
+	return stats, nil
+}
@@ -3,39 +3,134 @@ package service_test
 import (
 	"context"
 	"errors"
+	"math"
 	"testing"
 	"time"
 
 	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
 	"github.com/lazerion/outbox-relayer/internal/service"
 )
 
 // Mock repository
 type MockMessageRepo struct {
 	Messages []model.Message
+	Replays  []model.MessageReplay
+	Attempts []model.MessageAttempt
 	Err      error
+
+	LookupCalls  int
+	LastFilter   model.MessageFilter
+	LastKey      repository.SentKey
+	LastBackward bool
+	Counts       map[model.MessageStatus]int64
 }
 
-func (m *MockMessageRepo) ListSentMessages(ctx context.Context, after time.Time, limit int) ([]model.Message, error) {
+func (m *MockMessageRepo) ListSentMessages(ctx context.Context, key repository.SentKey, backward bool, limit int) ([]model.Message, error) {
 	if m.Err != nil {
 		return nil, m.Err
 	}
+	m.LastKey, m.LastBackward = key, backward
 	return m.Messages, nil
 }
 
+func (m *MockMessageRepo) GetMessage(ctx context.Context, id int64) (*model.Message, error) {
+	m.LookupCalls++
+	for _, msg := range m.Messages {
+		if msg.ID == id {
+			return &msg, nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *MockMessageRepo) GetMessageByExternalID(ctx context.Context, externalID string) (*model.Message, error) {
+	m.LookupCalls++
+	for _, msg := range m.Messages {
+		if msg.ExternalID == externalID {
+			return &msg, nil
+		}
+	}
+	return nil, model.ErrNotFound
+}
+
+func (m *MockMessageRepo) SearchMessages(ctx context.Context, filter model.MessageFilter, afterID int64, limit int) ([]model.Message, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	m.LastFilter = filter
+	return m.Messages, nil
+}
+
+func (m *MockMessageRepo) ListReplays(ctx context.Context, messageID int64) ([]model.MessageReplay, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var replays []model.MessageReplay
+	for _, rp := range m.Replays {
+		if rp.MessageID == messageID {
+			replays = append(replays, rp)
+		}
+	}
+	return replays, nil
+}
+
+func (m *MockMessageRepo) ListAttempts(ctx context.Context, messageID int64) ([]model.MessageAttempt, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	var attempts []model.MessageAttempt
+	for _, a := range m.Attempts {
+		if a.MessageID == messageID {
+			attempts = append(attempts, a)
+		}
+	}
+	return attempts, nil
+}
+
+func (m *MockMessageRepo) CountByStatus(ctx context.Context, filter model.MessageFilter) (map[model.MessageStatus]int64, error) {
+	if m.Err != nil {
+		return nil, m.Err
+	}
+	m.LastFilter = filter
+	return m.Counts, nil
+}
+
+// Mock cache
+type MockMessageCache struct {
+	Entries map[string]model.Message
+	GetErr  error
+}
+
+func (c *MockMessageCache) GetMessage(ctx context.Context, externalID string) (*model.Message, error) {
+	if c.GetErr != nil {
+		return nil, c.GetErr
+	}
+	msg, ok := c.Entries[externalID]
+	if !ok {
+		return nil, service.ErrCacheMiss
+	}
+	return &msg, nil
+}
+
+func (c *MockMessageCache) CacheMessage(ctx context.Context, msg model.Message) error {
+	c.Entries[msg.ExternalID] = msg
+	return nil
+}
+
 func TestQueryService_ListSentMessages(t *testing.T) {
 	msgTime := time.Now()
 	mockRepo := &MockMessageRepo{
 		Messages: []model.Message{
 			{ID: 1, PhoneNumber: "+123", Content: "Hello", SentTime: msgTime},
-			{ID: 2, PhoneNumber: "+456", Content: "World", SentTime: msgTime.Add(time.Minute)},
+			{ID: 2, PhoneNumber: "+456", Content: "World", SentTime: msgTime},
 		},
 	}
 
-	svc := service.NewQueryService(mockRepo)
+	svc := service.NewQueryService(mockRepo, nil)
 	ctx := context.Background()
 
-	resp, err := svc.ListSentMessages(ctx, time.Time{}, 2)
+	resp, err := svc.ListSentMessages(ctx, service.SentMessagesQuery{Limit: 2})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -44,8 +139,85 @@ func TestQueryService_ListSentMessages(t *testing.T) {
 		t.Errorf("expected 2 messages, got %d", len(resp.Messages))
 	}
 
-	if resp.NextCursor == nil || !resp.NextCursor.Equal(mockRepo.Messages[1].SentTime) {
-		t.Errorf("expected NextCursor=%v, got %v", mockRepo.Messages[1].SentTime, resp.NextCursor)
+	if resp.NextCursor == nil {
+		t.Fatal("expected NextCursor for a full page")
+	}
+	if resp.PrevCursor != nil {
+		t.Errorf("expected no PrevCursor on the first page, got %v", *resp.PrevCursor)
+	}
+
+	// the cursor resumes after the last message, including its ID to break sent_time ties
+	_, err = svc.ListSentMessages(ctx, service.SentMessagesQuery{Cursor: *resp.NextCursor, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRepo.LastBackward || mockRepo.LastKey.ID != 2 || !mockRepo.LastKey.SentTime.Equal(msgTime) {
+		t.Errorf("unexpected key from NextCursor: %+v backward=%v", mockRepo.LastKey, mockRepo.LastBackward)
+	}
+}
+
+func TestQueryService_ListSentMessages_Backward(t *testing.T) {
+	msgTime := time.Now()
+	mockRepo := &MockMessageRepo{
+		Messages: []model.Message{
+			{ID: 5, SentTime: msgTime},
+			{ID: 6, SentTime: msgTime.Add(time.Second)},
+		},
+	}
+
+	svc := service.NewQueryService(mockRepo, nil)
+	ctx := context.Background()
+
+	// a page reached through a cursor can always go back
+	first, err := svc.ListSentMessages(ctx, service.SentMessagesQuery{After: msgTime.Add(-time.Hour), Limit: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if first.PrevCursor == nil {
+		t.Fatal("expected PrevCursor when paging from a cursor")
+	}
+
+	resp, err := svc.ListSentMessages(ctx, service.SentMessagesQuery{Cursor: *first.PrevCursor, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !mockRepo.LastBackward || mockRepo.LastKey.ID != 5 {
+		t.Errorf("unexpected key from PrevCursor: %+v backward=%v", mockRepo.LastKey, mockRepo.LastBackward)
+	}
+	if resp.NextCursor == nil || resp.PrevCursor == nil {
+		t.Errorf("expected both cursors on a full backward page, got next=%v prev=%v", resp.NextCursor, resp.PrevCursor)
+	}
+}
+
+func TestQueryService_ListSentMessages_LegacyAfter(t *testing.T) {
+	mockRepo := &MockMessageRepo{Messages: []model.Message{}}
+	svc := service.NewQueryService(mockRepo, nil)
+
+	after := time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC)
+	_, err := svc.ListSentMessages(context.Background(), service.SentMessagesQuery{After: after, Limit: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// every message at exactly `after` is skipped, matching the previous strict comparison
+	if !mockRepo.LastKey.SentTime.Equal(after) || mockRepo.LastKey.ID != math.MaxInt64 || mockRepo.LastBackward {
+		t.Errorf("unexpected key for legacy after: %+v", mockRepo.LastKey)
+	}
+}
+
+func TestQueryService_ListSentMessages_InvalidCursor(t *testing.T) {
+	svc := service.NewQueryService(&MockMessageRepo{}, nil)
+
+	for _, q := range []service.SentMessagesQuery{
+		{Cursor: "!!not-base64!!", Limit: 2},
+		{Cursor: "e30", Limit: 2}, // "{}"
+		{Cursor: "e30", After: time.Now(), Limit: 2},
+	} {
+		_, err := svc.ListSentMessages(context.Background(), q)
+		var vErr *model.ValidationError
+		if !errors.As(err, &vErr) || vErr.Field != "cursor" {
+			t.Errorf("expected cursor ValidationError for %+v, got %v", q, err)
+		}
 	}
 }
 
@@ -54,8 +226,8 @@ func TestQueryService_ListSentMessages_Error(t *testing.T) {
 		Err: errors.New("db error"),
 	}
 
-	svc := service.NewQueryService(mockRepo)
-	_, err := svc.ListSentMessages(context.Background(), time.Time{}, 2)
+	svc := service.NewQueryService(mockRepo, nil)
+	_, err := svc.ListSentMessages(context.Background(), service.SentMessagesQuery{Limit: 2})
 	if err == nil {
 		t.Fatal("expected error, got nil")
 	}
@@ -69,8 +241,8 @@ func TestQueryService_ListSentMessages_Empty(t *testing.T) {
 		Messages: []model.Message{},
 	}
 
-	svc := service.NewQueryService(mockRepo)
-	resp, err := svc.ListSentMessages(context.Background(), time.Time{}, 2)
+	svc := service.NewQueryService(mockRepo, nil)
+	resp, err := svc.ListSentMessages(context.Background(), service.SentMessagesQuery{Limit: 2})
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -83,3 +255,197 @@ func TestQueryService_ListSentMessages_Empty(t *testing.T) {
 		t.Errorf("expected NextCursor=nil, got %v", resp.NextCursor)
 	}
 }
+
+func TestQueryService_GetMessage(t *testing.T) {
+	mockRepo := &MockMessageRepo{
+		Messages: []model.Message{{ID: 7, Status: model.StatusPending}},
+	}
+	svc := service.NewQueryService(mockRepo, nil)
+
+	msg, err := svc.GetMessage(context.Background(), 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.ID != 7 {
+		t.Errorf("expected message 7, got %d", msg.ID)
+	}
+
+	_, err = svc.GetMessage(context.Background(), 8)
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestQueryService_ListReplays(t *testing.T) {
+	mockRepo := &MockMessageRepo{
+		Messages: []model.Message{{ID: 7}, {ID: 8}},
+		Replays:  []model.MessageReplay{{ID: 1, MessageID: 7, ReplayedBy: "ops"}},
+	}
+	svc := service.NewQueryService(mockRepo, nil)
+	ctx := context.Background()
+
+	replays, err := svc.ListReplays(ctx, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(replays) != 1 || replays[0].ReplayedBy != "ops" {
+		t.Errorf("unexpected replays: %+v", replays)
+	}
+
+	replays, err = svc.ListReplays(ctx, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if replays == nil || len(replays) != 0 {
+		t.Errorf("expected an empty non-nil list, got %#v", replays)
+	}
+
+	_, err = svc.ListReplays(ctx, 9)
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestQueryService_ListAttempts(t *testing.T) {
+	mockRepo := &MockMessageRepo{
+		Messages: []model.Message{{ID: 7}, {ID: 8}},
+		Attempts: []model.MessageAttempt{{ID: 1, MessageID: 7, StatusCode: 503, Recoverable: true}},
+	}
+	svc := service.NewQueryService(mockRepo, nil)
+	ctx := context.Background()
+
+	attempts, err := svc.ListAttempts(ctx, 7)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(attempts) != 1 || attempts[0].StatusCode != 503 {
+		t.Errorf("unexpected attempts: %+v", attempts)
+	}
+
+	attempts, err = svc.ListAttempts(ctx, 8)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts == nil || len(attempts) != 0 {
+		t.Errorf("expected an empty non-nil list, got %#v", attempts)
+	}
+
+	_, err = svc.ListAttempts(ctx, 9)
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestQueryService_GetMessageByExternalID_ReadThrough(t *testing.T) {
+	mockRepo := &MockMessageRepo{
+		Messages: []model.Message{{ID: 7, ExternalID: "ext-7", Status: model.StatusSent}},
+	}
+	mockCache := &MockMessageCache{Entries: map[string]model.Message{}}
+	svc := service.NewQueryService(mockRepo, mockCache)
+	ctx := context.Background()
+
+	// miss falls back to the repository and populates the cache
+	msg, err := svc.GetMessageByExternalID(ctx, "ext-7")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if msg.ID != 7 {
+		t.Errorf("expected message 7, got %d", msg.ID)
+	}
+	if _, ok := mockCache.Entries["ext-7"]; !ok {
+		t.Fatal("expected message to be cached after miss")
+	}
+
+	// hit is served without touching the repository
+	if _, err := svc.GetMessageByExternalID(ctx, "ext-7"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if mockRepo.LookupCalls != 1 {
+		t.Errorf("expected 1 repository lookup, got %d", mockRepo.LookupCalls)
+	}
+
+	_, err = svc.GetMessageByExternalID(ctx, "unknown")
+	if !errors.Is(err, model.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestQueryService_GetMessageByExternalID_CacheError(t *testing.T) {
+	mockRepo := &MockMessageRepo{
+		Messages: []model.Message{{ID: 7, ExternalID: "ext-7"}},
+	}
+	mockCache := &MockMessageCache{Entries: map[string]model.Message{}, GetErr: errors.New("redis down")}
+	svc := service.NewQueryService(mockRepo, mockCache)
+
+	msg, err := svc.GetMessageByExternalID(context.Background(), "ext-7")
+	if err != nil {
+		t.Fatalf("cache failures should fall back to the repository, got %v", err)
+	}
+	if msg.ID != 7 {
+		t.Errorf("expected message 7, got %d", msg.ID)
+	}
+}
+
+func TestQueryService_SearchMessages(t *testing.T) {
+	mockRepo := &MockMessageRepo{
+		Messages: []model.Message{{ID: 3}, {ID: 9}},
+	}
+	svc := service.NewQueryService(mockRepo, nil)
+
+	filter := model.MessageFilter{Statuses: []model.MessageStatus{model.StatusFailed}}
+	page, err := svc.SearchMessages(context.Background(), filter, 0, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(page.Messages) != 2 {
+		t.Errorf("expected 2 messages, got %d", len(page.Messages))
+	}
+	if page.NextAfterID == nil || *page.NextAfterID != 9 {
+		t.Errorf("expected NextAfterID=9, got %v", page.NextAfterID)
+	}
+	if len(mockRepo.LastFilter.Statuses) != 1 {
+		t.Errorf("filter not passed to repository: %+v", mockRepo.LastFilter)
+	}
+
+	page, err = svc.SearchMessages(context.Background(), filter, 0, 5)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if page.NextAfterID != nil {
+		t.Errorf("expected no next page, got %v", *page.NextAfterID)
+	}
+}
+
+func TestQueryService_SearchMessages_InvalidFilter(t *testing.T) {
+	svc := service.NewQueryService(&MockMessageRepo{}, nil)
+
+	_, err := svc.SearchMessages(context.Background(), model.MessageFilter{MinID: 5, MaxID: 1}, 0, 5)
+	var vErr *model.ValidationError
+	if !errors.As(err, &vErr) {
+		t.Fatalf("expected ValidationError, got %v", err)
+	}
+}
+
+func TestQueryService_Stats(t *testing.T) {
+	mockRepo := &MockMessageRepo{
+		Counts: map[model.MessageStatus]int64{model.StatusSent: 40, model.StatusExpired: 2},
+	}
+	svc := service.NewQueryService(mockRepo, nil)
+
+	stats, err := svc.Stats(context.Background(), model.MessageFilter{PhoneNumber: "+123"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if stats.Total != 42 || stats.ByStatus[model.StatusExpired] != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+	if mockRepo.LastFilter.PhoneNumber != "+123" {
+		t.Errorf("filter not passed to repository: %+v", mockRepo.LastFilter)
+	}
+
+	_, err = svc.Stats(context.Background(), model.MessageFilter{MinID: 3, MaxID: 1})
+	var vErr *model.ValidationError
+	if !errors.As(err, &vErr) {
+		t.Errorf("expected ValidationError, got %v", err)
+	}
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/gateway"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+	"github.com/lazerion/outbox-relayer/internal/schedule"
+)
+
+// ReconcilerService resolves messages whose send has an unknown outcome, e.g. because the gateway's response timed
+// out, by asking the gateway whether it accepted them before they are sent again
+type ReconcilerService struct {
+	repo repository.MessageRepository
+	// checker is nil when the gateway has no status lookup
+	checker gateway.StatusChecker
+	batch   int
+	timeout time.Duration
+	cacheCh chan SentMessageEvent
+	now     func() time.Time
+	owner   string
+	lease   time.Duration
+	// maxChecks is how many status lookups of a message may fail before it is given up on
+	maxChecks int
+}
+
+// DefaultMaxStatusChecks is how many status lookups of a message may fail when no limit is configured
+const DefaultMaxStatusChecks = 10
+
+// ReconcilerOption configures optional ReconcilerService behavior
+type ReconcilerOption func(*ReconcilerService)
+
+// WithReconcilerLease sets how long claimed messages are reserved for the reconciler. A message whose status
+// could not be looked up stays claimed until the lease runs out, which spaces out the lookups.
+func WithReconcilerLease(lease time.Duration) ReconcilerOption {
+	return func(s *ReconcilerService) {
+		s.lease = lease
+	}
+}
+
+// WithMaxStatusChecks sets how many status lookups of a message may fail before it is marked failed
+func WithMaxStatusChecks(n int) ReconcilerOption {
+	return func(s *ReconcilerService) {
+		s.maxChecks = n
+	}
+}
+
+// WithReconcilerOwner sets the name stamped on claimed messages, which must be unique per instance
+func WithReconcilerOwner(owner string) ReconcilerOption {
+	return func(s *ReconcilerService) {
+		s.owner = owner
+	}
+}
+
+func NewReconcilerService(repo repository.MessageRepository, checker gateway.StatusChecker, batch int,
+	timeout time.Duration, cacheCh chan SentMessageEvent, opts ...ReconcilerOption) schedule.Job {
+	s := &ReconcilerService{
+		repo:      repo,
+		checker:   checker,
+		batch:     batch,
+		timeout:   timeout,
+		cacheCh:   cacheCh,
+		now:       time.Now,
+		owner:     defaultOwner(),
+		lease:     DefaultLease,
+		maxChecks: DefaultMaxStatusChecks,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Run claims a batch of messages with an unknown outcome that are due to be checked and looks each up by its
+// delivery key. A message the gateway accepted is marked sent with the external ID it got; one the gateway never
+// saw is queued to be sent again right away. Without a StatusChecker every message is queued again and the
+// delivery key is left to keep the gateway from accepting it twice.
+func (s *ReconcilerService) Run(ctx context.Context) error {
+	msgs, err := s.repo.ClaimUnknown(ctx, s.owner, s.lease, s.batch)
+	if err != nil {
+		return fmt.Errorf("claim unknown messages: %w", err)
+	}
+
+	var errs []error
+	for _, m := range msgs {
+		if err := s.reconcile(ctx, m); err != nil {
+			log.Printf("failed to reconcile message ID %d: %v", m.ID, err)
+			errs = append(errs, fmt.Errorf("reconcile message %d: %w", m.ID, err))
+		}
+	}
+	if len(msgs) > 0 {
+		log.Printf("reconciled %d of %d messages with an unknown outcome", len(msgs)-len(errs), len(msgs))
+	}
+	return errors.Join(errs...)
+}
+
+// reconcile resolves a single claimed message. When the lookup fails the message is left claimed and unknown, or
+// marked failed once maxChecks lookups failed.
+func (s *ReconcilerService) reconcile(ctx context.Context, m model.Message) error {
+	if s.checker == nil {
+		log.Printf("gateway has no status lookup, queueing message ID %d to be sent again", m.ID)
+		return s.repo.RequeueUnknown(ctx, m.ID, s.owner)
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	status, err := s.checker.CheckStatus(checkCtx, gateway.DeliveryKey(m))
+	cancel()
+	if err != nil {
+		failed, ferr := s.repo.RecordFailedCheck(ctx, m.ID, s.owner, err.Error(), s.maxChecks)
+		if ferr != nil {
+			return errors.Join(fmt.Errorf("check status: %w", err), ferr)
+		}
+		if failed {
+			log.Printf("giving up on message ID %d after %d failed status lookups", m.ID, s.maxChecks)
+		}
+		return fmt.Errorf("check status: %w", err)
+	}
+
+	if !status.Found {
+		log.Printf("gateway never accepted message ID %d, queueing it to be sent again", m.ID)
+		return s.repo.RequeueUnknown(ctx, m.ID, s.owner)
+	}
+
+	log.Printf("gateway accepted message ID %d as %s, marking sent", m.ID, status.MessageID)
+	now := s.now().UTC()
+	if err := s.repo.ConfirmSent(ctx, m.ID, s.owner, status.MessageID, now); err != nil {
+		return err
+	}
+	publishSent(s.cacheCh, m, status.MessageID, now)
+	return nil
+}
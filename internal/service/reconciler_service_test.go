@@ -0,0 +1,131 @@
+package service_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/lazerion/outbox-relayer/internal/gateway/gatewaytest"
+	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/service"
+	"github.com/stretchr/testify/require"
+)
+
+// MockUnknownRepository hands out messages with an unknown outcome and records how they were resolved
+type MockUnknownRepository struct {
+	MockMessageRepository
+	UnknownMsgs []model.Message
+	Confirmed   map[int64]string
+	Requeued    []int64
+	// Checks counts failed status lookups per message, Failed the messages given up on
+	Checks map[int64]int
+	Failed []int64
+}
+
+func (m *MockUnknownRepository) ClaimUnknown(ctx context.Context, owner string, lease time.Duration, batchSize int) ([]model.Message, error) {
+	return m.UnknownMsgs, nil
+}
+func (m *MockUnknownRepository) ConfirmSent(ctx context.Context, id int64, owner string, externalID string, sentTime time.Time) error {
+	m.Owners = append(m.Owners, owner)
+	if m.Confirmed == nil {
+		m.Confirmed = map[int64]string{}
+	}
+	m.Confirmed[id] = externalID
+	return nil
+}
+func (m *MockUnknownRepository) RequeueUnknown(ctx context.Context, id int64, owner string) error {
+	m.Owners = append(m.Owners, owner)
+	m.Requeued = append(m.Requeued, id)
+	return nil
+}
+func (m *MockUnknownRepository) RecordFailedCheck(ctx context.Context, id int64, owner string, lastError string, maxChecks int) (bool, error) {
+	if m.Checks == nil {
+		m.Checks = map[int64]int{}
+	}
+	m.Checks[id]++
+	if m.Checks[id] < maxChecks {
+		return false, nil
+	}
+	m.Failed = append(m.Failed, id)
+	return true, nil
+}
+
+func TestReconcilerService_Run(t *testing.T) {
+	gw := gatewaytest.New()
+	gw.DropReplies(true)
+
+	// message 1 reached the gateway but its reply got lost
+	relayRepo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{{ID: 1, PhoneNumber: "+1"}}, nil
+		},
+	}
+	relayer := service.NewRelayerService(relayRepo, gw, 10, time.Second, 3, make(chan service.SentMessageEvent, 1))
+	require.NoError(t, relayer.Run(context.Background()))
+	require.Contains(t, relayRepo.Unknown, int64(1))
+	externalID, _ := gw.Accepted(model.Message{ID: 1})
+
+	// message 2 timed out before the gateway got it
+	repo := &MockUnknownRepository{UnknownMsgs: []model.Message{
+		{ID: 1, PhoneNumber: "+1", Status: model.StatusUnknown, ClaimedBy: "reconciler-1"},
+		{ID: 2, PhoneNumber: "+2", Status: model.StatusUnknown, ClaimedBy: "reconciler-1"},
+	}}
+	cacheCh := make(chan service.SentMessageEvent, 2)
+
+	reconciler := service.NewReconcilerService(repo, gw, 10, time.Second, cacheCh,
+		service.WithReconcilerOwner("reconciler-1"))
+	require.NoError(t, reconciler.Run(context.Background()))
+
+	require.Equal(t, map[int64]string{1: externalID}, repo.Confirmed)
+	require.Equal(t, []int64{2}, repo.Requeued)
+	require.Equal(t, []string{"reconciler-1", "reconciler-1"}, repo.Owners)
+	require.Equal(t, 1, gw.Sends(), "nothing is sent while reconciling")
+
+	evt := <-cacheCh
+	require.Equal(t, externalID, evt.MessageID)
+	require.Equal(t, model.StatusSent, evt.Message.Status)
+	require.Empty(t, evt.Message.ClaimedBy)
+}
+
+func TestReconcilerService_Run_StatusLookupFails(t *testing.T) {
+	gw := gatewaytest.New()
+	gw.FailStatus(errors.New("status endpoint unavailable"))
+	repo := &MockUnknownRepository{UnknownMsgs: []model.Message{{ID: 1, Status: model.StatusUnknown}}}
+
+	reconciler := service.NewReconcilerService(repo, gw, 10, time.Second, make(chan service.SentMessageEvent, 1))
+	err := reconciler.Run(context.Background())
+
+	require.ErrorContains(t, err, "reconcile message 1: check status: status endpoint unavailable")
+	// left unknown until its lease runs out
+	require.Empty(t, repo.Confirmed)
+	require.Empty(t, repo.Requeued)
+	require.Equal(t, map[int64]int{1: 1}, repo.Checks)
+	require.Empty(t, repo.Failed)
+}
+
+func TestReconcilerService_Run_GivesUpAfterMaxStatusChecks(t *testing.T) {
+	gw := gatewaytest.New()
+	gw.FailStatus(errors.New("status endpoint unavailable"))
+	repo := &MockUnknownRepository{UnknownMsgs: []model.Message{{ID: 1, Status: model.StatusUnknown}}}
+
+	reconciler := service.NewReconcilerService(repo, gw, 10, time.Second, make(chan service.SentMessageEvent, 1),
+		service.WithMaxStatusChecks(3))
+	for range 2 {
+		require.Error(t, reconciler.Run(context.Background()))
+		require.Empty(t, repo.Failed)
+	}
+	require.Error(t, reconciler.Run(context.Background()))
+
+	require.Equal(t, []int64{1}, repo.Failed)
+	require.Empty(t, repo.Requeued)
+}
+
+func TestReconcilerService_Run_WithoutStatusChecker(t *testing.T) {
+	repo := &MockUnknownRepository{UnknownMsgs: []model.Message{{ID: 1, Status: model.StatusUnknown}}}
+
+	reconciler := service.NewReconcilerService(repo, nil, 10, time.Second, make(chan service.SentMessageEvent, 1))
+	require.NoError(t, reconciler.Run(context.Background()))
+
+	require.Equal(t, []int64{1}, repo.Requeued)
+}
@@ -2,12 +2,16 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"os"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/lazerion/outbox-relayer/internal/gateway"
+	"github.com/lazerion/outbox-relayer/internal/model"
 	"github.com/lazerion/outbox-relayer/internal/repository"
 	"github.com/lazerion/outbox-relayer/internal/schedule"
 )
@@ -15,89 +19,402 @@ import (
 type SentMessageEvent struct {
 	MessageID string
 	SentAt    time.Time
+	// Message is the sent message as stored after the update
+	Message model.Message
 }
 
 type RelayerService struct {
-	repo        repository.MessageRepository
-	sender      gateway.Sender
-	batch       int
-	timeout     time.Duration
-	maxAttempts int
-	cacheCh     chan SentMessageEvent
+	repo          repository.MessageRepository
+	sender        gateway.Sender
+	batch         int
+	timeout       time.Duration
+	maxAttempts   int
+	classAttempts map[gateway.ErrorClass]int
+	cacheCh       chan SentMessageEvent
+	defaultTTL    time.Duration
+	quotas        *priorityQuotas
+	backoff       BackoffPolicy
+	now           func() time.Time
+	owner         string
+	lease         time.Duration
+	concurrency   int
+	// maxBatches caps the batches claimed per Run
+	maxBatches int
+	report     func(MessageOutcome)
+}
+
+// DefaultLease is how long a claimed batch is reserved for a relayer when no lease is configured
+const DefaultLease = 5 * time.Minute
+
+// leaseMargin is kept free at the end of a lease for recording the last outcome and for clock skew to the database
+const leaseMargin = 5 * time.Second
+
+// MinLease is the shortest lease that covers sending a full batch, given that up to concurrency messages are sent
+// at the same time and each send takes at most timeout
+func MinLease(batch, concurrency int, timeout time.Duration) time.Duration {
+	concurrency = max(concurrency, 1)
+	rounds := (max(batch, 1) + concurrency - 1) / concurrency
+	return time.Duration(rounds)*timeout + leaseMargin
+}
+
+// RelayerOption configures optional RelayerService behavior
+type RelayerOption func(*RelayerService)
+
+// WithPriorityWeights sets the share of each batch reserved for every priority level.
+// Levels with a zero weight only receive slots the other levels leave unused.
+func WithPriorityWeights(weights map[model.MessagePriority]int) RelayerOption {
+	return func(s *RelayerService) {
+		s.quotas = newPriorityQuotas(weights)
+	}
+}
+
+// WithBackoff delays the retry of messages failing with a recoverable error according to the policy
+func WithBackoff(policy BackoffPolicy) RelayerOption {
+	return func(s *RelayerService) {
+		s.backoff = policy
+	}
+}
+
+// WithMaxAttemptsByError replaces the default maximum attempts for recoverable errors of the given classes.
+// A max_attempts set on the message itself still takes precedence.
+func WithMaxAttemptsByError(limits map[gateway.ErrorClass]int) RelayerOption {
+	return func(s *RelayerService) {
+		s.classAttempts = limits
+	}
+}
+
+// WithLease sets how long claimed messages are reserved for this relayer. It should cover sending a whole batch,
+// see MinLease. A message is not sent once the lease has too little time left for it; such messages are claimed
+// again by the next run of any relayer after the lease ran out.
+func WithLease(lease time.Duration) RelayerOption {
+	return func(s *RelayerService) {
+		s.lease = lease
+	}
+}
+
+// WithOwner sets the name stamped on claimed messages, which must be unique per relayer instance
+func WithOwner(owner string) RelayerOption {
+	return func(s *RelayerService) {
+		s.owner = owner
+	}
+}
+
+// WithConcurrency sends up to n messages of a batch at the same time; messages to the same recipient are still
+// sent one after another in claim order
+func WithConcurrency(n int) RelayerOption {
+	return func(s *RelayerService) {
+		s.concurrency = max(n, 1)
+	}
+}
+
+// WithDrain keeps claiming batches back-to-back while they come back full, up to maxBatchesPerRun per Run.
+// When the cap is reached with work left, the rest waits for the next tick or trigger.
+func WithDrain(maxBatchesPerRun int) RelayerOption {
+	return func(s *RelayerService) {
+		s.maxBatches = max(maxBatchesPerRun, 1)
+	}
+}
+
+// WithOutcomeReporter passes the outcome of every claimed message to report once its batch is done.
+// report is called from the goroutine running the batch, one outcome at a time.
+func WithOutcomeReporter(report func(MessageOutcome)) RelayerOption {
+	return func(s *RelayerService) {
+		s.report = report
+	}
+}
+
+// WithClock replaces time.Now, e.g. with a fake clock in tests
+func WithClock(now func() time.Time) RelayerOption {
+	return func(s *RelayerService) {
+		s.now = now
+	}
+}
+
+// WithDefaultTTL expires messages without an explicit expires_at once they have been due for ttl
+func WithDefaultTTL(ttl time.Duration) RelayerOption {
+	return func(s *RelayerService) {
+		s.defaultTTL = ttl
+	}
 }
 
 func NewRelayerService(repo repository.MessageRepository, sender gateway.Sender, batch int, timeout time.Duration, maxAttempts int,
-	cacheCh chan SentMessageEvent) schedule.Job {
-	return &RelayerService{
+	cacheCh chan SentMessageEvent, opts ...RelayerOption) schedule.Job {
+	s := &RelayerService{
 		repo:        repo,
 		sender:      sender,
 		batch:       batch,
 		timeout:     timeout,
 		maxAttempts: maxAttempts,
 		cacheCh:     cacheCh,
+		quotas:      newPriorityQuotas(DefaultPriorityWeights),
+		now:         time.Now,
+		owner:       defaultOwner(),
+		lease:       DefaultLease,
+		concurrency: 1,
+		maxBatches:  1,
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
-// Run fetches pending messages and sends them with retry/attempt logic
-// Transactional safety is ensured by wrapping all pending message updates in a single database transaction (`tx`).
-// Each message is marked sent, failed, or attempt incremented atomically.
+// Run claims a batch of pending messages and sends them with retry/attempt logic.
+// The batch is leased to this relayer in a short transaction, so no database connection or row lock is held while
+// the gateway is called. Messages are sent by a bounded pool of workers, and each outcome is then recorded in its own
+// update, which only applies while the lease is held. An update that fails leaves the other outcomes of the batch in
+// place; the failures are returned once the batch is done and end the run.
+// Expired messages are moved out of the pending set first so they don't take up room in the batch.
+// In drain mode further batches are claimed while the previous one came back full, up to the cap of the run.
 func (s *RelayerService) Run(ctx context.Context) error {
-	msgs, tx, err := s.repo.FetchPendingTx(ctx, s.batch)
-	if err != nil {
-		return fmt.Errorf("fetch pending messages: %w", err)
+	if n, err := s.repo.ExpirePending(ctx, s.defaultTTL); err != nil {
+		log.Printf("failed to expire pending messages: %v", err)
+	} else if n > 0 {
+		log.Printf("expired %d pending messages", n)
 	}
 
-	if len(msgs) == 0 {
-		_ = tx.Rollback()
-		return nil
+	for range s.maxBatches {
+		claimed, err := s.runBatch(ctx)
+		if err != nil {
+			return err
+		}
+		// a short batch means the queue is empty for now
+		if claimed < s.batch || ctx.Err() != nil {
+			return nil
+		}
 	}
 
-	for _, m := range msgs {
-		if m.AttemptCount >= s.maxAttempts {
-			log.Printf("message ID %d exceeded max attempts (%d), marking as failed", m.ID, s.maxAttempts)
-			_ = s.repo.MarkAsFailedTx(ctx, tx, m.ID)
-			continue
-		}
+	return nil
+}
 
-		sendCtx, cancel := context.WithTimeout(ctx, s.timeout)
-		resp, err := s.sender.Send(sendCtx, m)
-		cancel()
+// runBatch claims and relays a single batch, returning how many messages were claimed and the outcomes that
+// could not be recorded
+func (s *RelayerService) runBatch(ctx context.Context) (int, error) {
+	// taken before claiming, so the lease held in the database never runs out before this deadline
+	deadline := s.now().Add(s.lease)
+	msgs, err := s.repo.ClaimPending(ctx, s.owner, s.lease, s.batch, s.quotas.next(s.batch))
+	if err != nil {
+		return 0, fmt.Errorf("claim pending messages: %w", err)
+	}
 
-		if err != nil {
-			if gateway.IsRecoverable(err) {
-				log.Printf("recoverable error sending message ID %d: %v", m.ID, err)
-				_ = s.repo.IncrementAttemptTx(ctx, tx, m.ID)
-			} else {
-				log.Printf("unrecoverable error sending message ID %d: %v", m.ID, err)
-				_ = s.repo.MarkAsFailedTx(ctx, tx, m.ID)
+	// messages to the same recipient form one unit of work so they are sent in claim order
+	groups := groupByRecipient(msgs)
+	work := make(chan []model.Message)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		outcomes = make([]MessageOutcome, 0, len(msgs))
+		deferred atomic.Int32
+	)
+	for range min(s.concurrency, len(groups)) {
+		wg.Go(func() {
+			for group := range work {
+				for _, m := range group {
+					// a send outlasting the lease could race another relayer sending the message again
+					if s.now().Add(s.timeout + leaseMargin).After(deadline) {
+						deferred.Add(1)
+						continue
+					}
+					o := s.relay(ctx, m)
+					mu.Lock()
+					outcomes = append(outcomes, o)
+					mu.Unlock()
+				}
 			}
-			continue
+		})
+	}
+	for _, group := range groups {
+		work <- group
+	}
+	close(work)
+	wg.Wait()
+
+	if len(outcomes) > 0 {
+		log.Printf("relayed batch of %d messages: %s", len(outcomes), summarize(outcomes))
+	}
+	if n := deferred.Load(); n > 0 {
+		log.Printf("lease ran short, left %d messages to be claimed again once it runs out", n)
+	}
+	var errs []error
+	for _, o := range outcomes {
+		if s.report != nil {
+			s.report(o)
 		}
+		if o.Err != nil {
+			errs = append(errs, fmt.Errorf("record %s outcome of message %d: %w", o.Outcome, o.MessageID, o.Err))
+		}
+	}
+	return len(msgs), errors.Join(errs...)
+}
 
-		switch strings.ToLower(resp.Message) {
-		case "accepted":
-			now := time.Now()
-			if err := s.repo.MarkAsSentTx(ctx, tx, m.ID, resp.MessageID, now); err != nil {
-				log.Printf("failed to mark message ID %d as sent: %v", m.ID, err)
-				continue
-			}
-			// Push to cache channel asynchronously, non-blocking
-			select {
-			case s.cacheCh <- SentMessageEvent{MessageID: resp.MessageID, SentAt: now}:
-			default:
-				log.Printf("cache channel full, skipping caching for message ID %d", m.ID)
-			}
+// relay sends a single claimed message and records the outcome in its own update, so a failing update only
+// affects this message
+func (s *RelayerService) relay(ctx context.Context, m model.Message) MessageOutcome {
+	if m.Expired(s.now(), s.defaultTTL) {
+		log.Printf("message ID %d expired before it could be sent", m.ID)
+		return s.recorded(m.ID, OutcomeExpired, s.repo.MarkAsExpired(ctx, m.ID, s.owner))
+	}
+
+	if limit := s.attemptCeiling(m); m.AttemptCount >= limit {
+		log.Printf("message ID %d exceeded max attempts (%d), marking as failed", m.ID, limit)
+		return s.recorded(m.ID, OutcomeFailed, s.repo.MarkAsFailed(ctx, m.ID, s.owner, model.FailureMaxAttempts, ""))
+	}
 
+	sendCtx, cancel := context.WithTimeout(ctx, s.timeout)
+	attemptedAt, start := s.now(), time.Now()
+	resp, err := s.sender.Send(sendCtx, m)
+	cancel()
+	s.recordAttempt(ctx, m.ID, attemptedAt, time.Since(start), resp, err)
+
+	if err != nil {
+		class := gateway.Classify(err)
+		attempts := m.AttemptCount + 1
+		switch {
+		case gateway.IsAmbiguous(err):
+			// sending it again could deliver it twice, so the reconciler asks the gateway first
+			checkAt := s.backoff.NextAttempt(s.now(), attempts)
+			log.Printf("outcome of sending message ID %d is unknown, checking with the gateway at %s: %v",
+				m.ID, checkAt.Format(time.RFC3339), err)
+			return s.recorded(m.ID, OutcomeUnknown, s.repo.MarkAsUnknown(ctx, m.ID, s.owner, checkAt, err.Error()))
+		case !gateway.IsRecoverable(err):
+			log.Printf("unrecoverable error sending message ID %d: %v", m.ID, err)
+			return s.recorded(m.ID, OutcomeFailed,
+				s.repo.MarkAsFailed(ctx, m.ID, s.owner, model.FailureUnrecoverable, err.Error()))
+		case attempts >= s.attemptLimit(m, class):
+			log.Printf("message ID %d reached max attempts (%d) for %s errors, marking as failed: %v",
+				m.ID, attempts, class, err)
+			return s.recorded(m.ID, OutcomeFailed,
+				s.repo.MarkAsFailed(ctx, m.ID, s.owner, model.FailureMaxAttempts, err.Error()))
 		default:
-			log.Printf("sender rejected message ID %d, marking failed: status=%s",
-				m.ID, resp.Message)
-			_ = s.repo.MarkAsFailedTx(ctx, tx, m.ID)
+			next := s.backoff.NextAttempt(s.now(), attempts)
+			log.Printf("recoverable %s error sending message ID %d, retrying at %s: %v",
+				class, m.ID, next.Format(time.RFC3339), err)
+			return s.recorded(m.ID, OutcomeRetried, s.repo.IncrementAttempt(ctx, m.ID, s.owner, next, err.Error()))
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("transaction commit failed: %w", err)
+	if !resp.Accepted() {
+		log.Printf("sender rejected message ID %d, marking failed: status=%s",
+			m.ID, resp.Message)
+		return s.recorded(m.ID, OutcomeFailed,
+			s.repo.MarkAsFailed(ctx, m.ID, s.owner, model.FailureUnexpectedResponse, unexpectedReply(resp)))
 	}
 
-	return nil
+	if resp.Duplicate {
+		log.Printf("gateway already accepted message ID %d as %s, marking sent", m.ID, resp.MessageID)
+	}
+	now := s.now().UTC()
+	if err := s.repo.MarkAsSent(ctx, m.ID, s.owner, resp.MessageID, now); err != nil {
+		return s.recorded(m.ID, OutcomeSent, err)
+	}
+	publishSent(s.cacheCh, m, resp.MessageID, now)
+	return s.recorded(m.ID, OutcomeSent, nil)
+}
+
+// publishSent pushes the claimed message, as stored once marked sent, to the cache channel without blocking
+func publishSent(cacheCh chan SentMessageEvent, m model.Message, externalID string, sentAt time.Time) {
+	sent := m
+	sent.Status = model.StatusSent
+	sent.ExternalID = externalID
+	sent.SentTime = sentAt
+	sent.ClaimedBy = ""
+	sent.LeaseUntil = time.Time{}
+
+	select {
+	case cacheCh <- SentMessageEvent{MessageID: externalID, SentAt: sentAt, Message: sent}:
+	default:
+		log.Printf("cache channel full, skipping caching for message ID %d", m.ID)
+	}
+}
+
+// recorded builds the outcome of a message, logging when its update failed
+func (s *RelayerService) recorded(id int64, outcome Outcome, err error) MessageOutcome {
+	if err != nil {
+		log.Printf("failed to mark message ID %d as %s: %v", id, outcome, err)
+	}
+	return MessageOutcome{MessageID: id, Outcome: outcome, Err: err}
+}
+
+// recordAttempt adds a send to the message's attempt history. Failing to record it is logged but doesn't change
+// how the outcome is handled.
+func (s *RelayerService) recordAttempt(ctx context.Context, id int64, attemptedAt time.Time, took time.Duration,
+	resp *gateway.SendResponse, sendErr error) {
+	attempt := model.MessageAttempt{
+		MessageID:   id,
+		AttemptedAt: attemptedAt,
+		DurationMS:  took.Milliseconds(),
+	}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+		attempt.Recoverable = gateway.IsRecoverable(sendErr)
+		var ue *gateway.UpstreamError
+		if errors.As(sendErr, &ue) {
+			attempt.StatusCode = ue.StatusCode
+		}
+	} else {
+		attempt.StatusCode = resp.StatusCode
+		attempt.ExternalID = resp.MessageID
+		if !resp.Accepted() {
+			attempt.Error = unexpectedReply(resp)
+		}
+	}
+
+	if err := s.repo.RecordAttempt(ctx, attempt); err != nil {
+		log.Printf("failed to record attempt for message ID %d: %v", id, err)
+	}
+}
+
+// unexpectedReply describes a gateway response other than "accepted"
+func unexpectedReply(resp *gateway.SendResponse) string {
+	return fmt.Sprintf("gateway replied %q", resp.Message)
+}
+
+// groupByRecipient splits the batch by phone number, keeping the order of first appearance and claim order within
+func groupByRecipient(msgs []model.Message) [][]model.Message {
+	index := make(map[string]int)
+	var groups [][]model.Message
+	for _, m := range msgs {
+		i, ok := index[m.PhoneNumber]
+		if !ok {
+			i = len(groups)
+			index[m.PhoneNumber] = i
+			groups = append(groups, nil)
+		}
+		groups[i] = append(groups[i], m)
+	}
+	return groups
+}
+
+// defaultOwner names this relayer instance after its host and process
+func defaultOwner() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "relayer"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
+
+// attemptLimit returns how many attempts a message may use when its latest attempt failed with the given class:
+// the message's own max_attempts, else the limit configured for the class, else the relayer default
+func (s *RelayerService) attemptLimit(m model.Message, class gateway.ErrorClass) int {
+	if m.MaxAttempts > 0 {
+		return m.MaxAttempts
+	}
+	if n, ok := s.classAttempts[class]; ok {
+		return n
+	}
+	return s.maxAttempts
+}
+
+// attemptCeiling returns the most attempts any error class allows the message,
+// so messages that cannot be retried under any class are failed without another send
+func (s *RelayerService) attemptCeiling(m model.Message) int {
+	if m.MaxAttempts > 0 {
+		return m.MaxAttempts
+	}
+	ceiling := s.maxAttempts
+	for _, n := range s.classAttempts {
+		ceiling = max(ceiling, n)
+	}
+	return ceiling
 }
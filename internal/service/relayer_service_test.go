@@ -2,40 +2,139 @@ package service_test
 
 import (
 	"context"
-	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
-	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lazerion/outbox-relayer/internal/config"
 	"github.com/lazerion/outbox-relayer/internal/gateway"
+	"github.com/lazerion/outbox-relayer/internal/gateway/gatewaytest"
 	"github.com/lazerion/outbox-relayer/internal/model"
+	"github.com/lazerion/outbox-relayer/internal/repository"
+	"github.com/lazerion/outbox-relayer/internal/schedule"
 	"github.com/lazerion/outbox-relayer/internal/service"
 	"github.com/stretchr/testify/require"
 )
 
 type MockMessageRepository struct {
-	FetchPendingTxFunc func(ctx context.Context, batchSize int) ([]model.Message, *sql.Tx, error)
+	// embedded so the mock only needs to implement what the relayer uses
+	repository.MessageRepository
+
+	// mu guards the recorded outcomes, which the relayer writes from several workers
+	mu               sync.Mutex
+	ClaimPendingFunc func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error)
+	Expired          []int64
+	Retries          map[int64]time.Time
+	Unknown          map[int64]time.Time
+	Sent             []int64
+	// Owners records the lease owner passed with every outcome
+	Owners   []string
+	Attempts []model.MessageAttempt
 }
 
-func (m *MockMessageRepository) FetchPendingTx(ctx context.Context, batchSize int) ([]model.Message, *sql.Tx, error) {
-	return m.FetchPendingTxFunc(ctx, batchSize)
+func (m *MockMessageRepository) ClaimPending(ctx context.Context, owner string, lease time.Duration, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+	return m.ClaimPendingFunc(ctx, batchSize, quotas)
+}
+func (m *MockMessageRepository) MarkAsSent(ctx context.Context, id int64, owner string, messageID string, sentAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Sent = append(m.Sent, id)
+	m.Owners = append(m.Owners, owner)
+	return nil
+}
+func (m *MockMessageRepository) MarkAsFailed(ctx context.Context, id int64, owner string, reason model.FailureReason, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Owners = append(m.Owners, owner)
+	return nil
+}
+func (m *MockMessageRepository) IncrementAttempt(ctx context.Context, id int64, owner string, nextAttemptAt time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Owners = append(m.Owners, owner)
+	if m.Retries == nil {
+		m.Retries = map[int64]time.Time{}
+	}
+	m.Retries[id] = nextAttemptAt
+	return nil
 }
-func (m *MockMessageRepository) MarkAsSentTx(ctx context.Context, tx *sql.Tx, id int64, messageID string, sentAt time.Time) error {
+func (m *MockMessageRepository) MarkAsUnknown(ctx context.Context, id int64, owner string, checkAt time.Time, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Owners = append(m.Owners, owner)
+	if m.Unknown == nil {
+		m.Unknown = map[int64]time.Time{}
+	}
+	m.Unknown[id] = checkAt
 	return nil
 }
-func (m *MockMessageRepository) MarkAsFailedTx(ctx context.Context, tx *sql.Tx, id int64) error {
+func (m *MockMessageRepository) MarkAsExpired(ctx context.Context, id int64, owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Expired = append(m.Expired, id)
+	m.Owners = append(m.Owners, owner)
 	return nil
 }
-func (m *MockMessageRepository) IncrementAttemptTx(ctx context.Context, tx *sql.Tx, id int64) error {
+func (m *MockMessageRepository) RecordAttempt(ctx context.Context, attempt model.MessageAttempt) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Attempts = append(m.Attempts, attempt)
 	return nil
 }
+func (m *MockMessageRepository) ExpirePending(ctx context.Context, defaultTTL time.Duration) (int64, error) {
+	return 0, nil
+}
 
-type mockSender struct{}
+// MockFailureRepository additionally records which messages were marked failed and why
+type MockFailureRepository struct {
+	MockMessageRepository
+	Failed     []int64
+	Reasons    map[int64]model.FailureReason
+	LastErrors map[int64]string
+}
+
+func (m *MockFailureRepository) MarkAsFailed(ctx context.Context, id int64, owner string, reason model.FailureReason, lastError string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Failed = append(m.Failed, id)
+	if m.Reasons == nil {
+		m.Reasons = map[int64]model.FailureReason{}
+		m.LastErrors = map[int64]string{}
+	}
+	m.Reasons[id] = reason
+	m.LastErrors[id] = lastError
+	return nil
+}
+
+// replySender answers every send with the given gateway message
+type replySender string
+
+func (r replySender) Send(ctx context.Context, msg model.Message) (*gateway.SendResponse, error) {
+	return &gateway.SendResponse{MessageID: fmt.Sprint(msg.ID), Message: string(r), StatusCode: 202}, nil
+}
+
+type mockSender struct {
+	mu   sync.Mutex
+	sent []int64
+	err  error
+}
 
 func (m *mockSender) Send(ctx context.Context, msg model.Message) (*gateway.SendResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return nil, m.err
+	}
+	m.sent = append(m.sent, msg.ID)
 	return &gateway.SendResponse{
-		MessageID: "1",
-		Message:   "accepted",
+		MessageID:  "1",
+		Message:    "accepted",
+		StatusCode: 202,
 	}, nil
 }
 
@@ -43,60 +142,45 @@ func TestRelayerService_Run(t *testing.T) {
 	tests := []struct {
 		name           string
 		pendingMsgs    []model.Message
-		expectCommit   bool
-		expectRollback bool
+		expectSent     []int64
 		expectCacheEvt bool
 	}{
 		{
 			name: "with pending message",
 			pendingMsgs: []model.Message{
-				{ID: 123, PhoneNumber: "+123456789", Content: "hello", AttemptCount: 0},
+				{ID: 123, PhoneNumber: "+123456789", Content: "hello", AttemptCount: 0, ClaimedBy: "relayer-1"},
 			},
-			expectCommit:   true,
-			expectRollback: false,
+			expectSent:     []int64{123},
 			expectCacheEvt: true,
 		},
 		{
 			name:           "no pending messages",
 			pendingMsgs:    []model.Message{},
-			expectCommit:   false,
-			expectRollback: true,
 			expectCacheEvt: false,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			db, mock, err := sqlmock.New()
-			require.NoError(t, err)
-			defer db.Close()
-
-			mock.ExpectBegin()
-			tx, err := db.Begin()
-			require.NoError(t, err)
-
-			if tt.expectCommit {
-				mock.ExpectCommit()
-			}
-			if tt.expectRollback {
-				mock.ExpectRollback()
-			}
-
 			repo := &MockMessageRepository{
-				FetchPendingTxFunc: func(ctx context.Context, batchSize int) ([]model.Message, *sql.Tx, error) {
-					return tt.pendingMsgs, tx, nil
+				ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+					return tt.pendingMsgs, nil
 				},
 			}
 			cacheChan := make(chan service.SentMessageEvent, 1)
-			relayer := service.NewRelayerService(repo, &mockSender{}, 10, time.Second, 3, cacheChan)
-			err = relayer.Run(context.Background())
+			relayer := service.NewRelayerService(repo, &mockSender{}, 10, time.Second, 3, cacheChan,
+				service.WithOwner("relayer-1"))
+			err := relayer.Run(context.Background())
 			require.NoError(t, err)
-			require.NoError(t, mock.ExpectationsWereMet())
+			require.Equal(t, tt.expectSent, repo.Sent)
 
 			if tt.expectCacheEvt {
 				select {
 				case evt := <-cacheChan:
 					require.Equal(t, "1", evt.MessageID)
+					require.Equal(t, model.StatusSent, evt.Message.Status)
+					require.Equal(t, "1", evt.Message.ExternalID)
+					require.Empty(t, evt.Message.ClaimedBy, "the lease is released once sent")
 				default:
 					t.Fatalf("expected cache event but none received")
 				}
@@ -111,3 +195,532 @@ func TestRelayerService_Run(t *testing.T) {
 		})
 	}
 }
+
+func TestRelayerService_Run_Expired(t *testing.T) {
+	now := time.Now()
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{
+				{ID: 1, ExpiresAt: now.Add(-time.Minute), CreatedAt: now.Add(-time.Hour)},
+				{ID: 2, CreatedAt: now.Add(-time.Hour)},
+				{ID: 3, CreatedAt: now},
+			}, nil
+		},
+	}
+	sender := &mockSender{}
+
+	relayer := service.NewRelayerService(repo, sender, 10, time.Second, 3, make(chan service.SentMessageEvent, 3),
+		service.WithDefaultTTL(30*time.Minute))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	// 1 is past its own expiry, 2 has been due for longer than the default TTL
+	require.Equal(t, []int64{1, 2}, repo.Expired)
+	require.Equal(t, []int64{3}, sender.sent)
+}
+
+func TestRelayerService_Run_PriorityQuotas(t *testing.T) {
+	totals := map[model.MessagePriority]int{}
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			sum := 0
+			for p, n := range quotas {
+				totals[p] += n
+				sum += n
+			}
+			require.Equal(t, batchSize, sum, "quotas should cover the whole batch")
+			return nil, nil
+		},
+	}
+
+	relayer := service.NewRelayerService(repo, &mockSender{}, 2, time.Second, 3, make(chan service.SentMessageEvent, 1),
+		service.WithPriorityWeights(map[model.MessagePriority]int{
+			model.PriorityHigh:   6,
+			model.PriorityNormal: 3,
+			model.PriorityLow:    1,
+		}))
+
+	// batches of 2 are smaller than the number of lanes, yet over 10 slots every lane gets its share
+	for range 5 {
+		require.NoError(t, relayer.Run(context.Background()))
+	}
+	require.Equal(t, map[model.MessagePriority]int{
+		model.PriorityHigh:   6,
+		model.PriorityNormal: 3,
+		model.PriorityLow:    1,
+	}, totals)
+}
+
+func TestRelayerService_Run_RecordsOutcomesUnderLease(t *testing.T) {
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{{ID: 1}, {ID: 2, ExpiresAt: time.Now().Add(-time.Minute)}}, nil
+		},
+	}
+
+	relayer := service.NewRelayerService(repo, &mockSender{}, 10, time.Second, 3, make(chan service.SentMessageEvent, 1),
+		service.WithOwner("relayer-1"))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	require.Equal(t, []string{"relayer-1", "relayer-1"}, repo.Owners)
+}
+
+func TestRelayerService_Run_RecordsAttempts(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	claim := func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+		return []model.Message{{ID: 1}, {ID: 2, ExpiresAt: now.Add(-time.Minute)}}, nil
+	}
+
+	repo := &MockMessageRepository{ClaimPendingFunc: claim}
+	relayer := service.NewRelayerService(repo, &mockSender{}, 10, time.Second, 3, make(chan service.SentMessageEvent, 1),
+		service.WithClock(func() time.Time { return now }))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	// the expired message is never sent, so it has no attempt
+	require.Len(t, repo.Attempts, 1)
+	require.Equal(t, int64(1), repo.Attempts[0].MessageID)
+	require.Equal(t, now, repo.Attempts[0].AttemptedAt)
+	require.Equal(t, 202, repo.Attempts[0].StatusCode)
+	require.Equal(t, "1", repo.Attempts[0].ExternalID)
+	require.Empty(t, repo.Attempts[0].Error)
+
+	repo = &MockMessageRepository{ClaimPendingFunc: claim}
+	sender := &mockSender{err: gateway.WrapUpstreamError(errors.New("unexpected status code: 503"), 503)}
+	relayer = service.NewRelayerService(repo, sender, 10, time.Second, 3, make(chan service.SentMessageEvent, 1),
+		service.WithClock(func() time.Time { return now }))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	require.Len(t, repo.Attempts, 1)
+	require.Equal(t, 503, repo.Attempts[0].StatusCode)
+	require.True(t, repo.Attempts[0].Recoverable)
+	require.Contains(t, repo.Attempts[0].Error, "unexpected status code: 503")
+	require.Empty(t, repo.Attempts[0].ExternalID)
+}
+
+// failingSentRepository fails to record the sent outcome of one message
+type failingSentRepository struct {
+	MockMessageRepository
+	failID int64
+}
+
+func (m *failingSentRepository) MarkAsSent(ctx context.Context, id int64, owner string, messageID string, sentAt time.Time) error {
+	if id == m.failID {
+		return errors.New("connection reset")
+	}
+	return m.MockMessageRepository.MarkAsSent(ctx, id, owner, messageID, sentAt)
+}
+
+func TestRelayerService_Run_IsolatesFailedUpdates(t *testing.T) {
+	repo := &failingSentRepository{
+		MockMessageRepository: MockMessageRepository{
+			ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+				return []model.Message{
+					{ID: 1, PhoneNumber: "+1"},
+					{ID: 2, PhoneNumber: "+2"},
+					{ID: 3, PhoneNumber: "+3", ExpiresAt: time.Now().Add(-time.Minute)},
+				}, nil
+			},
+		},
+		failID: 1,
+	}
+	var outcomes []service.MessageOutcome
+
+	relayer := service.NewRelayerService(repo, &mockSender{}, 10, time.Second, 3, make(chan service.SentMessageEvent, 10),
+		service.WithOutcomeReporter(func(o service.MessageOutcome) { outcomes = append(outcomes, o) }))
+	err := relayer.Run(context.Background())
+
+	require.ErrorContains(t, err, "record sent outcome of message 1: connection reset")
+	// the failed update does not undo the other outcomes of the batch
+	require.Equal(t, []int64{2}, repo.Sent)
+	require.Equal(t, []int64{3}, repo.Expired)
+
+	slices.SortFunc(outcomes, func(a, b service.MessageOutcome) int { return int(a.MessageID - b.MessageID) })
+	require.Len(t, outcomes, 3)
+	require.Equal(t, service.OutcomeSent, outcomes[0].Outcome)
+	require.Error(t, outcomes[0].Err)
+	require.Equal(t, service.MessageOutcome{MessageID: 2, Outcome: service.OutcomeSent}, outcomes[1])
+	require.Equal(t, service.MessageOutcome{MessageID: 3, Outcome: service.OutcomeExpired}, outcomes[2])
+}
+
+func TestRelayerService_Run_ClaimError(t *testing.T) {
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return nil, errors.New("connection reset")
+		},
+	}
+
+	relayer := service.NewRelayerService(repo, &mockSender{}, 10, time.Second, 3, make(chan service.SentMessageEvent, 1))
+	require.ErrorContains(t, relayer.Run(context.Background()), "claim pending messages")
+}
+
+func TestBackoffPolicy_Delay(t *testing.T) {
+	policy := service.BackoffPolicy{Base: time.Second, Multiplier: 2, MaxDelay: 10 * time.Second}
+
+	require.Equal(t, time.Second, policy.Delay(1))
+	require.Equal(t, 2*time.Second, policy.Delay(2))
+	require.Equal(t, 8*time.Second, policy.Delay(4))
+	require.Equal(t, 10*time.Second, policy.Delay(5), "capped at MaxDelay")
+	require.Equal(t, 10*time.Second, policy.Delay(1000), "no overflow past MaxDelay")
+
+	policy.Jitter = 0.5
+	policy.Rand = func() float64 { return 0.5 }
+	require.Equal(t, 3*time.Second, policy.Delay(3), "4s shortened by a quarter")
+
+	require.Zero(t, service.BackoffPolicy{}.Delay(3), "zero policy retries immediately")
+
+	require.Error(t, service.BackoffPolicy{Multiplier: 0.5}.Validate())
+	require.Error(t, service.BackoffPolicy{Jitter: 1.5}.Validate())
+	require.NoError(t, policy.Validate())
+}
+
+func TestRelayerService_Run_Backoff(t *testing.T) {
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{
+				{ID: 1, AttemptCount: 0},
+				{ID: 2, AttemptCount: 2},
+			}, nil
+		},
+	}
+	sender := &mockSender{err: &gateway.UpstreamError{StatusCode: 503, Recoverable: true}}
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	relayer := service.NewRelayerService(repo, sender, 10, time.Second, 5, make(chan service.SentMessageEvent, 1),
+		service.WithBackoff(service.BackoffPolicy{Base: 30 * time.Second, Multiplier: 2, MaxDelay: time.Hour}),
+		service.WithClock(func() time.Time { return now }))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	require.Equal(t, map[int64]time.Time{
+		1: now.Add(30 * time.Second),
+		2: now.Add(2 * time.Minute),
+	}, repo.Retries)
+}
+
+func TestRelayerService_Run_MaxAttempts(t *testing.T) {
+	repo := &MockFailureRepository{MockMessageRepository: MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{
+				{ID: 1, AttemptCount: 1},
+				// the message's own limit wins over the class limit
+				{ID: 2, AttemptCount: 1, MaxAttempts: 5},
+				// beyond every limit, so not sent at all
+				{ID: 3, AttemptCount: 10},
+			}, nil
+		},
+	}}
+	sender := &mockSender{err: gateway.WrapTransportError(context.DeadlineExceeded)}
+
+	relayer := service.NewRelayerService(repo, sender, 10, time.Second, 3, make(chan service.SentMessageEvent, 1),
+		service.WithMaxAttemptsByError(map[gateway.ErrorClass]int{
+			gateway.ErrorClassTimeout:     2,
+			gateway.ErrorClassRateLimited: 10,
+		}))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	// timeouts are limited to 2 attempts
+	require.Equal(t, []int64{1, 3}, repo.Failed)
+	require.Equal(t, []int64{2}, slices.Collect(maps.Keys(repo.Retries)))
+
+	sender.err = gateway.WrapUpstreamError(errors.New("unavailable"), 503)
+	repo.Failed, repo.Retries = nil, nil
+	require.NoError(t, relayer.Run(context.Background()))
+
+	// the default of 3 applies to a 5xx on the second attempt
+	require.Equal(t, []int64{3}, repo.Failed)
+	require.Contains(t, repo.Retries, int64(1))
+}
+
+// duplicateSender reports every message as already accepted by an earlier attempt
+type duplicateSender struct{}
+
+func (duplicateSender) Send(ctx context.Context, msg model.Message) (*gateway.SendResponse, error) {
+	return &gateway.SendResponse{MessageID: "orig-" + fmt.Sprint(msg.ID), Message: "duplicate", StatusCode: 409, Duplicate: true}, nil
+}
+
+func TestRelayerService_Run_Duplicate(t *testing.T) {
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{{ID: 7}}, nil
+		},
+	}
+	cacheCh := make(chan service.SentMessageEvent, 1)
+
+	relayer := service.NewRelayerService(repo, duplicateSender{}, 10, time.Second, 3, cacheCh)
+	require.NoError(t, relayer.Run(context.Background()))
+
+	require.Equal(t, []int64{7}, repo.Sent)
+	evt := <-cacheCh
+	require.Equal(t, "orig-7", evt.Message.ExternalID, "keeps the external ID of the original delivery")
+	require.Empty(t, repo.Attempts[0].Error)
+}
+
+func TestRelayerService_Run_UnknownOutcome(t *testing.T) {
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{{ID: 1, AttemptCount: 1}}, nil
+		},
+	}
+	gw := gatewaytest.New()
+	gw.DropReplies(true)
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var outcomes []service.MessageOutcome
+
+	relayer := service.NewRelayerService(repo, gw, 10, time.Second, 3, make(chan service.SentMessageEvent, 1),
+		service.WithBackoff(service.BackoffPolicy{Base: 30 * time.Second, Multiplier: 2, MaxDelay: time.Hour}),
+		service.WithClock(func() time.Time { return now }),
+		service.WithOutcomeReporter(func(o service.MessageOutcome) { outcomes = append(outcomes, o) }))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	// the gateway took the message, so it is not retried but checked once the retry would have been due
+	_, accepted := gw.Accepted(model.Message{ID: 1})
+	require.True(t, accepted)
+	require.Equal(t, map[int64]time.Time{1: now.Add(time.Minute)}, repo.Unknown)
+	require.Empty(t, repo.Retries)
+	require.Equal(t, []service.MessageOutcome{{MessageID: 1, Outcome: service.OutcomeUnknown}}, outcomes)
+	require.Contains(t, repo.Attempts[0].Error, "execute request")
+}
+
+func TestRelayerService_Run_ReplayedMessageIsSentAgain(t *testing.T) {
+	gw := gatewaytest.New()
+	relay := func(m model.Message) (*MockMessageRepository, chan service.SentMessageEvent) {
+		repo := &MockMessageRepository{
+			ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+				return []model.Message{m}, nil
+			},
+		}
+		cacheCh := make(chan service.SentMessageEvent, 1)
+		relayer := service.NewRelayerService(repo, gw, 10, time.Second, 3, cacheCh)
+		require.NoError(t, relayer.Run(context.Background()))
+		return repo, cacheCh
+	}
+
+	// the gateway accepted the first delivery
+	original := model.Message{ID: 1, PhoneNumber: "+1", Content: "wrong amount"}
+	_, cacheCh := relay(original)
+	require.Equal(t, "fake-1", (<-cacheCh).MessageID)
+
+	// a changed payload under the old key is a conflict, not a duplicate of the first delivery
+	repo, _ := relay(model.Message{ID: 1, PhoneNumber: "+1", Content: "fixed amount"})
+	require.Empty(t, repo.Sent, "must not be marked sent with the external ID of the old content")
+
+	// replay starts a new delivery generation, so the fixed content goes out under a new key
+	replayed := model.Message{ID: 1, PhoneNumber: "+1", Content: "fixed amount", DeliveryGeneration: 1}
+	repo, cacheCh = relay(replayed)
+	require.Equal(t, []int64{1}, repo.Sent)
+	evt := <-cacheCh
+	require.Equal(t, "fake-2", evt.MessageID)
+
+	sent := gw.Sent()
+	require.Equal(t, "fixed amount", sent[len(sent)-1].Content)
+	externalID, _ := gw.Accepted(replayed)
+	require.Equal(t, "fake-2", externalID)
+}
+
+func TestRelayerService_Run_FailureReasons(t *testing.T) {
+	repo := &MockFailureRepository{MockMessageRepository: MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return []model.Message{
+				{ID: 1, AttemptCount: 0},
+				{ID: 2, AttemptCount: 2, MaxAttempts: 3},
+				// already out of attempts, so failed without another send
+				{ID: 3, AttemptCount: 3},
+			}, nil
+		},
+	}}
+	sender := &mockSender{err: gateway.WrapUpstreamError(errors.New("unavailable"), 503)}
+
+	relayer := service.NewRelayerService(repo, sender, 10, time.Second, 3, make(chan service.SentMessageEvent, 1))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	require.Equal(t, map[int64]model.FailureReason{2: model.FailureMaxAttempts, 3: model.FailureMaxAttempts}, repo.Reasons)
+	require.Contains(t, repo.LastErrors[2], "status 503")
+	require.Empty(t, repo.LastErrors[3], "keeps the error of the previous attempt")
+
+	sender.err = gateway.WrapUpstreamError(errors.New("invalid recipient"), 400)
+	repo.Reasons = nil
+	require.NoError(t, relayer.Run(context.Background()))
+	require.Equal(t, model.FailureUnrecoverable, repo.Reasons[1])
+	require.Contains(t, repo.LastErrors[1], "invalid recipient")
+
+	relayer = service.NewRelayerService(repo, replySender("Queued"), 10, time.Second, 3, make(chan service.SentMessageEvent, 1))
+	repo.Reasons = nil
+	require.NoError(t, relayer.Run(context.Background()))
+	require.Equal(t, model.FailureUnexpectedResponse, repo.Reasons[1])
+	require.Equal(t, `gateway replied "Queued"`, repo.LastErrors[1])
+}
+
+// slowSender takes latency per send and tracks how many sends overlap
+type slowSender struct {
+	latency  time.Duration
+	inFlight atomic.Int32
+	peak     atomic.Int32
+
+	mu    sync.Mutex
+	order map[string][]int64
+}
+
+func (s *slowSender) Send(ctx context.Context, msg model.Message) (*gateway.SendResponse, error) {
+	n := s.inFlight.Add(1)
+	defer s.inFlight.Add(-1)
+	for {
+		peak := s.peak.Load()
+		if n <= peak || s.peak.CompareAndSwap(peak, n) {
+			break
+		}
+	}
+
+	s.mu.Lock()
+	s.order[msg.PhoneNumber] = append(s.order[msg.PhoneNumber], msg.ID)
+	s.mu.Unlock()
+
+	time.Sleep(s.latency)
+	return &gateway.SendResponse{MessageID: fmt.Sprint(msg.ID), Message: "Accepted"}, nil
+}
+
+// clockSender advances a fake clock by the gateway latency on every send
+type clockSender struct {
+	mockSender
+	now     *time.Time
+	latency time.Duration
+}
+
+func (c *clockSender) Send(ctx context.Context, msg model.Message) (*gateway.SendResponse, error) {
+	*c.now = c.now.Add(c.latency)
+	return c.mockSender.Send(ctx, msg)
+}
+
+func TestRelayerService_Run_StopsSendingWhenLeaseRunsShort(t *testing.T) {
+	// all messages go to one recipient, so they are sent one after another despite the concurrency
+	msgs := []model.Message{{ID: 1, PhoneNumber: "+1"}, {ID: 2, PhoneNumber: "+1"}, {ID: 3, PhoneNumber: "+1"}}
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return msgs, nil
+		},
+	}
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	sender := &clockSender{now: &now, latency: time.Second}
+
+	relayer := service.NewRelayerService(repo, sender, 3, time.Second, 3, make(chan service.SentMessageEvent, 10),
+		service.WithConcurrency(3),
+		service.WithLease(service.MinLease(3, 3, time.Second)+time.Second),
+		service.WithClock(func() time.Time { return now }))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	require.Equal(t, []int64{1, 2}, sender.sent, "a send must not start once it could outlast the lease")
+	require.Equal(t, []int64{1, 2}, repo.Sent)
+}
+
+func TestNewRelayerServiceProvider_Lease(t *testing.T) {
+	tests := []struct {
+		name    string
+		lease   time.Duration
+		wantErr bool
+	}{
+		{"default lease", 0, false},
+		{"covers the batch", 26 * time.Second, false},
+		{"shorter than the batch", 20 * time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			cfg.Relayer.Batch = 100
+			cfg.Relayer.Concurrency = 5
+			cfg.Relayer.Timeout = time.Second
+			cfg.Relayer.Lease = tt.lease
+
+			_, err := service.NewRelayerServiceProvider(&MockMessageRepository{}, &mockSender{}, cfg,
+				make(chan service.SentMessageEvent))
+			if tt.wantErr {
+				require.ErrorContains(t, err, "relayer.lease")
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestRelayerService_Run_Concurrency(t *testing.T) {
+	var msgs []model.Message
+	for i := range 12 {
+		msgs = append(msgs, model.Message{ID: int64(i + 1), PhoneNumber: fmt.Sprintf("+%d", i%4)})
+	}
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			return msgs, nil
+		},
+	}
+	sender := &slowSender{latency: 20 * time.Millisecond, order: map[string][]int64{}}
+
+	relayer := service.NewRelayerService(repo, sender, 12, time.Second, 3, make(chan service.SentMessageEvent, 12),
+		service.WithConcurrency(8))
+	require.NoError(t, relayer.Run(context.Background()))
+
+	require.Len(t, repo.Sent, 12)
+	// sends overlap, but only 4 recipients means no more than 4 at a time
+	require.Greater(t, sender.peak.Load(), int32(1))
+	require.LessOrEqual(t, sender.peak.Load(), int32(4))
+	require.Equal(t, map[string][]int64{
+		"+0": {1, 5, 9},
+		"+1": {2, 6, 10},
+		"+2": {3, 7, 11},
+		"+3": {4, 8, 12},
+	}, sender.order)
+}
+
+func TestRelayerService_Run_Drain(t *testing.T) {
+	full := []model.Message{{ID: 1, PhoneNumber: "+1"}, {ID: 2, PhoneNumber: "+2"}}
+	tests := []struct {
+		name       string
+		opts       []service.RelayerOption
+		batches    [][]model.Message
+		wantClaims int
+	}{
+		{"single batch without drain", nil, [][]model.Message{full, full}, 1},
+		{"stops at a short batch", []service.RelayerOption{service.WithDrain(5)}, [][]model.Message{full, full[:1]}, 2},
+		{"stops at an empty queue", []service.RelayerOption{service.WithDrain(5)}, [][]model.Message{full, nil}, 2},
+		{"stops at the cap", []service.RelayerOption{service.WithDrain(3)}, [][]model.Message{full, full, full, full}, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := 0
+			repo := &MockMessageRepository{
+				ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+					claims++
+					return tt.batches[claims-1], nil
+				},
+			}
+
+			relayer := service.NewRelayerService(repo, &mockSender{}, 2, time.Second, 3, make(chan service.SentMessageEvent, 10),
+				tt.opts...)
+			require.NoError(t, relayer.Run(context.Background()))
+
+			require.Equal(t, tt.wantClaims, claims)
+		})
+	}
+}
+
+func TestRelayerService_Drain_ScheduledRunStopsAtCap(t *testing.T) {
+	full := []model.Message{{ID: 1, PhoneNumber: "+1"}, {ID: 2, PhoneNumber: "+2"}}
+	var claims atomic.Int32
+	repo := &MockMessageRepository{
+		ClaimPendingFunc: func(ctx context.Context, batchSize int, quotas map[model.MessagePriority]int) ([]model.Message, error) {
+			claims.Add(1)
+			return full, nil
+		},
+	}
+	relayer := service.NewRelayerService(repo, &mockSender{}, 2, time.Second, 3, make(chan service.SentMessageEvent, 100),
+		service.WithDrain(3))
+	s := schedule.NewScheduler(relayer, time.Hour, schedule.WithDebounce(time.Millisecond))
+
+	s.Start(context.Background())
+	defer s.Stop()
+	require.Eventually(t, func() bool { return claims.Load() == 3 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(3), claims.Load(), "a run should stop at the cap and wait for the next tick")
+
+	s.Trigger()
+	require.Eventually(t, func() bool { return claims.Load() == 6 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, int32(6), claims.Load(), "a triggered run should be bounded by the cap as well")
+}
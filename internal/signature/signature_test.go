@@ -0,0 +1,40 @@
+package signature_test
+
+import (
+	"testing"
+
+	"github.com/lazerion/outbox-relayer/internal/signature"
+)
+
+func TestSignVerify(t *testing.T) {
+	body := []byte(`{"messageId":"ext-1","status":"delivered"}`)
+	sig := signature.Sign("secret", body)
+
+	// HMAC-SHA256 is deterministic and hex encoded
+	if len(sig) != 64 || sig != signature.Sign("secret", body) {
+		t.Fatalf("unexpected signature %q", sig)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		sig    string
+		want   bool
+	}{
+		{"valid", "secret", body, sig, true},
+		{"other secret", "other", body, sig, false},
+		{"tampered body", "secret", []byte(`{"messageId":"ext-2","status":"delivered"}`), sig, false},
+		{"not hex", "secret", body, "zz", false},
+		{"missing signature", "secret", body, "", false},
+		{"empty secret", "", body, signature.Sign("", body), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := signature.Verify(tt.secret, tt.body, tt.sig); got != tt.want {
+				t.Errorf("Verify() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/lazerion/outbox-relayer/internal/cache"
+	"github.com/lazerion/outbox-relayer/internal/callback"
 	"github.com/lazerion/outbox-relayer/internal/config"
 	"github.com/lazerion/outbox-relayer/internal/gateway"
 	"github.com/lazerion/outbox-relayer/internal/infra"
@@ -99,6 +100,7 @@ func TestRelayerIntegration(t *testing.T) {
 		infra.Module,
 		repository.Module,
 		gateway.Module,
+		callback.Module,
 		service.Module,
 		schedule.Module,
 		schedule.ModuleWithLifeCycle,